@@ -36,7 +36,10 @@ type user struct {
 // We store the client instance within the application
 // such that we can communicate with it as we see fit
 func newApp(caAddr string) (*application, error) {
-	c, err := ifrit.NewClient(caAddr, nil)
+	conf := ifrit.DefaultConfig()
+	conf.CaAddr = caAddr
+
+	c, err := ifrit.NewClient(conf)
 	if err != nil {
 		return nil, err
 	}
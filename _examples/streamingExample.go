@@ -39,7 +39,12 @@ func main() {
 }
 
 func NewApp() (*App, error) {
-	client, err := ifrit.NewClient()
+	conf, err := ifrit.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ifrit.NewClient(conf)
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +52,7 @@ func NewApp() (*App, error) {
 	go client.Start()
 	client.RegisterStreamHandler(streamHandler)
 
-	master, err := ifrit.NewClient()
+	master, err := ifrit.NewClient(conf)
 	if err != nil {
 		return nil, err
 	}
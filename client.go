@@ -1,21 +1,80 @@
 package ifrit
 
 import (
+	"context"
+	"crypto/x509"
 	"crypto/x509/pkix"
 	"errors"
+	"net"
+	"strings"
 
 	log "github.com/inconshreveable/log15"
 
 	"github.com/joonnna/ifrit/comm"
 	"github.com/joonnna/ifrit/core"
+	"github.com/joonnna/ifrit/core/discovery"
+	"github.com/joonnna/ifrit/fault"
 	"github.com/joonnna/ifrit/netutil"
-	"github.com/spf13/viper"
 )
 
 type Client struct {
 	node *core.Node
 }
 
+// Member describes a participant of the network, see Member and AllMembers.
+type Member = core.Member
+
+// Request carries incoming application data together with the identity of its sender,
+// see RegisterMsgHandlerV2 and RegisterGossipHandlerV2.
+type Request = core.Request
+
+// GossipEntry is the most recent value a member has set for a gossip key, see SetGossipKey.
+type GossipEntry = core.GossipEntry
+
+// Rumor is a message broadcast to the entire network, see Broadcast.
+type Rumor = core.Rumor
+
+// ReliableMessage is a message delivered through reliable broadcast, see ReliableBroadcast.
+type ReliableMessage = core.ReliableMessage
+
+// Publication is a message published to a topic, see Publish.
+type Publication = core.Publication
+
+// Envelope is content signed by a member, see SignEnvelope and VerifyEnvelope.
+type Envelope = core.Envelope
+
+// Behavior decides how a client gossips, monitors and rebuts accusations, see SetBehavior.
+type Behavior = core.Behavior
+
+// Event describes a single membership change, see Subscribe.
+type Event = discovery.Event
+
+// EventType identifies the kind of membership change an event describes.
+type EventType = discovery.EventType
+
+const (
+	// Peer was added to the live view.
+	PeerJoined = discovery.PeerJoined
+
+	// Peer was accused on the ring given by Event.RingNum.
+	PeerAccused = discovery.PeerAccused
+
+	// Peer rebutted all accusations against it.
+	PeerRebutted = discovery.PeerRebutted
+
+	// Peer was removed from the live view after the removal timeout expired.
+	PeerRemoved = discovery.PeerRemoved
+
+	// The note of this client was re-issued to rebut an accusation.
+	NoteReissued = discovery.NoteReissued
+
+	// Peer shut down gracefully and was removed from the live view.
+	PeerLeft = discovery.PeerLeft
+
+	// The leader of Event.Group changed, see Leader.
+	LeaderChanged = discovery.LeaderChanged
+)
+
 /*
 type MessageHandler interface {
 	Incoming([]byte) ([]byte, error)
@@ -28,49 +87,178 @@ type GossipHandler interface {
 */
 
 var (
-	errNoData      = errors.New("Supplied data is of length 0")
-	errNoCaAddress = errors.New("Config does not contain address of CA")
+	errNoData        = errors.New("Supplied data is of length 0")
+	errNoTopic       = errors.New("Supplied topic is empty")
+	errReservedTopic = errors.New("Topics prefixed with " + core.InternalTopicPrefix + " are reserved")
+	errReservedKey   = errors.New("Gossip keys prefixed with " + core.InternalTopicPrefix + " are reserved")
+	errNoCaAddress   = errors.New("Config does not contain address of CA")
+
+	errNoIdentityAddrs = errors.New("Stored certificate does not contain the addresses of the identity")
 )
 
-// Creates and returns a new ifrit client instance.
-func NewClient() (*Client, error) {
-	err := readConfig()
-	if err != nil {
-		return nil, err
+// Errors returned by SendToContext and SendToIdContext, compare with errors.Is.
+var (
+	// No observed peer has the specified id.
+	ErrUnknownPeer = core.ErrUnknownPeer
+
+	// The destination could not be reached.
+	ErrTransport = core.ErrTransport
+
+	// The context deadline expired before a response was received.
+	ErrDeadlineExceeded = core.ErrDeadlineExceeded
+
+	// The message handler of the destination returned an error,
+	// the wrapped message contains the remote error.
+	ErrRemoteHandler = core.ErrRemoteHandler
+
+	// The destination has no message handler registered for the topic, see SendToTopic.
+	ErrUnknownTopic = core.ErrUnknownTopic
+
+	// Returned by Multicast when fewer destinations than required responded successfully.
+	ErrInsufficientResponses = core.ErrInsufficientResponses
+
+	// Set on multicast results of destinations abandoned after the required responses arrived.
+	ErrNotCompleted = core.ErrNotCompleted
+)
+
+// Returned by Leader if no member currently grants a lease for the group.
+var ErrNoLeader = core.ErrNoLeader
+
+// Lease grants leadership over a group until it expires, see Leader.
+type Lease = core.Lease
+
+// MulticastResult holds the response or error of a single multicast destination.
+type MulticastResult = core.MulticastResult
+
+// MulticastOption configures when a multicast completes.
+type MulticastOption func(numDests int) int
+
+// Completes the multicast after the first k successful responses.
+func WithMinSuccesses(k int) MulticastOption {
+	return func(numDests int) int {
+		return k
 	}
+}
 
-	udpConn, udpAddr, err := netutil.ListenUdp()
-	if err != nil {
-		return nil, err
+// Completes the multicast after a majority of the destinations responded successfully.
+func WithQuorum() MulticastOption {
+	return func(numDests int) int {
+		return (numDests / 2) + 1
 	}
+}
 
-	l, err := netutil.GetListener()
-	if err != nil {
-		return nil, err
+// Creates and returns a new ifrit client instance configured by conf.
+// If conf is nil, the default config is used, see DefaultConfig.
+// Use LoadConfig to populate the config from file and environment variables.
+func NewClient(conf *Config) (*Client, error) {
+	var cu *comm.CryptoUnit
+	var store *comm.IdentityStore
+	var l net.Listener
+	var udpConn *net.UDPConn
+	var epoch uint64
+	var err error
+
+	if conf == nil {
+		conf = DefaultConfig()
 	}
 
-	log.Debug("addrs", "rpc", l.Addr().String(), "udp", udpAddr)
+	if conf.IdentityDir != "" {
+		store = comm.NewIdentityStore(conf.IdentityDir)
+	}
 
-	pk := pkix.Name{
-		Locality: []string{l.Addr().String(), udpAddr},
+	if store != nil && conf.ResumeIdentity && store.Exists() {
+		cu, epoch, err = store.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		// Peers know us by the addresses in our certificate, reclaim them.
+		addrs := cu.Certificate().Subject.Locality
+		if len(addrs) < 2 {
+			return nil, errNoIdentityAddrs
+		}
+
+		l, err = netutil.ListenOnAddr(addrs[0])
+		if err != nil {
+			return nil, err
+		}
+
+		udpConn, err = netutil.ListenUdpOnAddr(addrs[1])
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+
+		// Peers treat the more recent note as a rebuttal.
+		epoch++
+
+		log.Debug("Resumed identity", "rpc", addrs[0], "udp", addrs[1], "epoch", epoch)
+	} else {
+		var udpAddr string
+
+		udpConn, udpAddr, err = netutil.ListenUdp()
+		if err != nil {
+			return nil, err
+		}
+
+		l, err = netutil.GetListener()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Debug("addrs", "rpc", l.Addr().String(), "udp", udpAddr)
+
+		pk := pkix.Name{
+			Locality: []string{l.Addr().String(), udpAddr},
+		}
+
+		cu, err = comm.NewCu(pk, conf.CaAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		if store != nil {
+			if err := store.Save(cu); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	cu, err := comm.NewCu(pk, viper.GetString("ca_addr"))
+	c, err := comm.NewComm(cu.Certificate(), cu.CaCertificate(), cu.Priv(), l, conf.UseCompression)
 	if err != nil {
 		return nil, err
 	}
 
-	c, err := comm.NewComm(cu.Certificate(), cu.CaCertificate(), cu.Priv(), l)
+	udpServer, err := comm.NewUdpServer(cu, udpConn, conf.PingTimeout)
 	if err != nil {
 		return nil, err
 	}
 
-	udpServer, err := comm.NewUdpServer(cu, udpConn)
-	if err != nil {
-		return nil, err
+	var transport fault.Transport = c
+	var pinger fault.Pinger = udpServer
+
+	if conf.Faults != nil {
+		addrs := cu.Certificate().Subject.Locality
+
+		name := conf.FaultName
+		if name == "" {
+			name = addrs[0]
+		}
+
+		conf.Faults.AddNode(name, addrs...)
+
+		transport = conf.Faults.Transport(name, c)
+		pinger = conf.Faults.Pinger(name, udpServer)
 	}
 
-	n, err := core.NewNode(c, udpServer, cu, cu)
+	nodeConf := conf.nodeConfig()
+	nodeConf.Epoch = epoch
+
+	if store != nil {
+		nodeConf.SaveEpoch = store.SaveEpoch
+	}
+
+	n, err := core.NewNode(transport, pinger, cu, cu, nodeConf)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +274,8 @@ func (c *Client) Start() {
 }
 
 // Stops client operations.
+// Before shutting down, a signed leaving note is sent to all ring neighbours,
+// members remove the client from their live view without waiting for the failure detector.
 // The client cannot be used after callling Close.
 func (c *Client) Stop() {
 	c.node.Stop()
@@ -96,6 +286,62 @@ func (c *Client) Members() []string {
 	return c.node.LiveMembers()
 }
 
+// Returns the descriptor of the member with the given Ifrit id.
+// Returns ErrUnknownPeer if the member is not in the full view.
+func (c *Client) Member(id string) (*Member, error) {
+	return c.node.Member(id)
+}
+
+// Returns descriptors of all members in the full view,
+// including members not currently believed to be alive (see Member.Live).
+func (c *Client) AllMembers() []*Member {
+	return c.node.AllMembers()
+}
+
+// Returns descriptors of all members in the live view.
+func (c *Client) LiveMembers() []*Member {
+	return c.node.LiveMemberInfo()
+}
+
+// Returns the addresses of the n live members succeeding key on the first ring, this client included.
+// The first address is the primary owner of the key, members sharing the same live view agree on the owners.
+func (c *Client) Lookup(key []byte, n int) ([]string, error) {
+	return c.node.Lookup(key, n, 1)
+}
+
+// Same as Lookup, but on the given ring (1 to the number of rings).
+// Different rings place keys independently, which allows spreading load across several placements.
+func (c *Client) LookupRing(key []byte, n int, ringNum uint32) ([]string, error) {
+	return c.node.Lookup(key, n, ringNum)
+}
+
+// Invokes handler with the new owners each time membership changes move the given key,
+// that is whenever the result of LookupRing(key, n, ringNum) changes.
+// Returns a function cancelling the watch.
+func (c *Client) WatchOwnership(key []byte, n int, ringNum uint32, handler func(owners []string)) (func(), error) {
+	return c.node.WatchOwnership(key, n, ringNum, handler)
+}
+
+// Returns the current leader of group together with the expiry of its lease.
+// The leader is the first member succeeding the group on ring 1 that is not accused, so every
+// member with the same live view picks the same leader without running an election.
+// The leader grants signed, time-bounded leases, a new leader only grants them after waiting
+// for a full lease duration, see Config.LeaseDuration.
+// Once called for a group, its lease is renewed in the background and revoked when the leader is
+// accused, removed or leaves, each change is emitted as a LeaderChanged event, see Subscribe.
+// Returns ErrNoLeader if no lease could be acquired, e.g while a new leader waits for old leases to expire.
+func (c *Client) Leader(group string) (*Lease, error) {
+	return c.node.Leader(group)
+}
+
+// Returns a channel receiving all subsequent membership events and a function cancelling the subscription.
+// Events are emitted as soon as the local view changes, the caller must drain the channel
+// as events are dropped when its buffer is full.
+// The channel is closed when the subscription is cancelled or the client stops.
+func (c *Client) Subscribe() (<-chan Event, func()) {
+	return c.node.Subscribe()
+}
+
 // Returns ifrit's internal ID generated by the trusted CA
 func (c *Client) Id() string {
 	return c.node.Id()
@@ -120,6 +366,47 @@ func (c *Client) VerifySignature(r, s, content []byte, id string) bool {
 	return c.node.Verify(r, s, content, id)
 }
 
+// Signs the provided content and bundles it with the certificate of this client and the current time.
+// The returned envelope can be stored and verified later with VerifyEnvelope,
+// without the client or the network being available.
+func (c *Client) SignEnvelope(content []byte) ([]byte, error) {
+	return c.node.SignEnvelope(content)
+}
+
+// Returns the suspicion level of the members monitored by the client, indexed by their ifrit id.
+// The level grows the longer a member fails to answer pings compared to its usual response pattern,
+// see Config.PhiThreshold. Members are omitted until enough of their pongs have arrived.
+func (c *Client) Suspicions() map[string]float64 {
+	return c.node.Suspicions()
+}
+
+// Replaces the behavior of the client with b, nil restores the correct behavior.
+// Used to test applications against misbehaving members, see the adversaries of the core package.
+func (c *Client) SetBehavior(b Behavior) {
+	c.node.SetBehavior(b)
+}
+
+// Returns the certificate of the CA that issued the certificates of the network,
+// nil if the network runs without a CA.
+func (c *Client) CaCertificate() *x509.Certificate {
+	return c.node.CaCertificate()
+}
+
+// Verifies an envelope created by SignEnvelope and returns its content.
+// The certificate within the envelope has to be issued by caCert and valid at the time of signing,
+// the signer is not required to be a member of the network.
+// Returns an error if caCert is nil, networks without a CA use VerifySelfSignedEnvelope.
+func VerifyEnvelope(envelope []byte, caCert *x509.Certificate) (*Envelope, error) {
+	return core.VerifyEnvelope(envelope, caCert)
+}
+
+// Verifies an envelope created by SignEnvelope in a network without a CA and returns its content.
+// The envelope has to carry the given certificate of the expected signer, obtained while
+// the signer was a member, e.g. from Member.Certificate.
+func VerifySelfSignedEnvelope(envelope []byte, signer *x509.Certificate) (*Envelope, error) {
+	return core.VerifySelfSignedEnvelope(envelope, signer)
+}
+
 // Sends the given data to the given destination.
 // The caller must ensure that the given data is not modified after calling this function.
 // The returned channel will be populated with the response.
@@ -148,6 +435,50 @@ func (c *Client) SendToId(destId []byte, data []byte) (chan []byte, error) {
 	return ch, err
 }
 
+// Sends the given data to the given destination and blocks until the response arrives.
+// The deadline and cancellation of ctx applies to the entire exchange.
+// Errors can be compared to ErrTransport, ErrDeadlineExceeded and ErrRemoteHandler
+// through errors.Is, cancellation returns context.Canceled.
+// The caller must ensure that the given data is not modified until the function returns.
+func (c *Client) SendToContext(ctx context.Context, dest string, data []byte) ([]byte, error) {
+	return c.node.SendMessageContext(ctx, dest, data)
+}
+
+// Same as SendToContext, but destination is now the Ifrit id of the receiver.
+// Returns ErrUnknownPeer if no observed peer has the specified destination id.
+func (c *Client) SendToIdContext(ctx context.Context, destId []byte, data []byte) ([]byte, error) {
+	addr, err := c.node.IdToAddr(destId)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.node.SendMessageContext(ctx, addr, data)
+}
+
+// Same as SendToContext, but the message is delivered to the handler registered under topic at the destination.
+// Returns ErrUnknownTopic if the destination has no handler registered for the topic.
+// The empty topic refers to the handler registered through RegisterMsgHandler.
+func (c *Client) SendToTopic(ctx context.Context, dest, topic string, data []byte) ([]byte, error) {
+	return c.node.SendTopicMessageContext(ctx, dest, topic, data)
+}
+
+// Sends the given data to all destinations and returns one result per destination, in the same order as dests.
+// Without options, Multicast waits for all destinations to respond or fail.
+// With WithMinSuccesses or WithQuorum, Multicast returns once enough destinations responded successfully,
+// the results of abandoned destinations carry ErrNotCompleted.
+// ErrInsufficientResponses is returned if the required number of successful responses was not reached,
+// the results are populated regardless.
+// The caller must ensure that the given data is not modified until the function returns.
+func (c *Client) Multicast(ctx context.Context, dests []string, data []byte, opts ...MulticastOption) ([]*MulticastResult, error) {
+	need := 0
+
+	for _, opt := range opts {
+		need = opt(len(dests))
+	}
+
+	return c.node.Multicast(ctx, dests, data, need)
+}
+
 // Returns a pair of channels used for bi-directional streams, given the destination. The first channel
 // is the input stream to the server and the second stream is the reply stream from the server. 
 // To close the stream, close the input channel. The reply stream is open as long as the server sends messages
@@ -168,6 +499,19 @@ func (c *Client) OpenStream(dest string) (chan []byte, chan []byte) {
 // The caller must close the reply channel to signal that the stream is closing.
 // See the note in OpenStream().
 func (c *Client) RegisterStreamHandler(streamHandler func(chan []byte, chan []byte)) {
+	if streamHandler == nil {
+		c.node.SetStreamHandler(nil)
+		return
+	}
+
+	c.node.SetStreamHandler(func(_ *Request, input, reply chan []byte) {
+		streamHandler(input, reply)
+	})
+}
+
+// Same as RegisterStreamHandler, but the callback also receives the identity of the peer that opened the stream.
+// The Data field of the request is always nil.
+func (c *Client) RegisterStreamHandlerV2(streamHandler func(*Request, chan []byte, chan []byte)) {
 	c.node.SetStreamHandler(streamHandler)
 }
 
@@ -178,6 +522,13 @@ func (c *Client) RegisterStreamHandler(streamHandler func(chan []byte, chan []by
 // All responses will be received on the sending side through a channel,
 // see SendTo documentation for details.
 func (c *Client) RegisterMsgHandler(msgHandler func([]byte) ([]byte, error)) {
+	c.node.SetMsgHandler(dataOnly(msgHandler))
+}
+
+// Same as RegisterMsgHandler, but the callback receives the message together with the sender's
+// id, address, certificate and the context of the incoming rpc.
+// Replaces any handler registered through RegisterMsgHandler.
+func (c *Client) RegisterMsgHandlerV2(msgHandler func(*Request) ([]byte, error)) {
 	c.node.SetMsgHandler(msgHandler)
 }
 
@@ -186,6 +537,12 @@ func (c *Client) RegisterMsgHandler(msgHandler func([]byte) ([]byte, error)) {
 // The returned byte slice will be sent back as the response.
 // If the callback returns a non-nil error, it will be sent back as the response instead.
 func (c *Client) RegisterGossipHandler(gossipHandler func([]byte) ([]byte, error)) {
+	c.node.SetGossipHandler(dataOnly(gossipHandler))
+}
+
+// Same as RegisterGossipHandler, but the callback receives the gossip together with the identity of the gossip partner.
+// Replaces any handler registered through RegisterGossipHandler.
+func (c *Client) RegisterGossipHandlerV2(gossipHandler func(*Request) ([]byte, error)) {
 	c.node.SetGossipHandler(gossipHandler)
 }
 
@@ -194,6 +551,20 @@ func (c *Client) RegisterGossipHandler(gossipHandler func([]byte) ([]byte, error
 // All responses originates from a gossip handler invocation.
 // If the ResponseHandler is not registered or nil, responses will be discarded.
 func (c *Client) RegisterResponseHandler(responseHandler func([]byte)) {
+	if responseHandler == nil {
+		c.node.SetResponseHandler(nil)
+		return
+	}
+
+	c.node.SetResponseHandler(func(r *Request) {
+		responseHandler(r.Data)
+	})
+}
+
+// Same as RegisterResponseHandler, but the callback receives the response together with the identity of the
+// gossip partner that generated it. The context of the request is never cancelled.
+// Replaces any handler registered through RegisterResponseHandler.
+func (c *Client) RegisterResponseHandlerV2(responseHandler func(*Request)) {
 	c.node.SetResponseHandler(responseHandler)
 }
 
@@ -211,32 +582,170 @@ func (c *Client) SetGossipContent(data []byte) error {
 	return nil
 }
 
-func readConfig() error {
-	viper.SetConfigName("ifrit_config")
-	viper.AddConfigPath("/var/tmp")
-	viper.AddConfigPath(".")
+// Registers the given function as the message handler for topic, see SendToTopic.
+// Handlers of different topics are independent, several libraries can share one client
+// by registering under their own topics. A nil handler removes the registration.
+// Topics prefixed with "ifrit." are reserved for internal use and can not be registered.
+func (c *Client) RegisterMsgHandlerFor(topic string, msgHandler func(*Request) ([]byte, error)) error {
+	if reservedTopic(topic) {
+		return errReservedTopic
+	}
 
-	viper.SetConfigType("yaml")
+	c.node.SetTopicMsgHandler(topic, msgHandler)
 
-	err := viper.ReadInConfig()
-	if err != nil {
-		return err
+	return nil
+}
+
+// Registers the given function as the gossip handler for topic.
+// Invoked each time ifrit receives gossip content set through SetGossipContentFor with the same topic.
+// Gossip of topics without a registered handler is discarded.
+func (c *Client) RegisterGossipHandlerFor(topic string, gossipHandler func(*Request) ([]byte, error)) error {
+	if reservedTopic(topic) {
+		return errReservedTopic
+	}
+
+	c.node.SetTopicGossipHandler(topic, gossipHandler)
+
+	return nil
+}
+
+// Registers the given function as the gossip response handler for topic.
+// Invoked with the responses generated by the gossip handlers registered under the same topic.
+func (c *Client) RegisterResponseHandlerFor(topic string, responseHandler func(*Request)) error {
+	if reservedTopic(topic) {
+		return errReservedTopic
 	}
 
-	// Behavior variables
-	viper.SetDefault("gossip_interval", 10)
-	viper.SetDefault("monitor_interval", 10)
-	viper.SetDefault("view_update_interval", 10)
-	viper.SetDefault("ping_limit", 3)
-	viper.SetDefault("pings_per_interval", 3)
-	viper.SetDefault("removal_timeout", 60)
-	viper.SetDefault("max_concurrent_messages", 5)
-	viper.SetDefault("use_compression", true)
+	c.node.SetTopicResponseHandler(topic, responseHandler)
+
+	return nil
+}
+
+// Same as SetGossipContent, but the content is only delivered to gossip handlers registered under topic.
+// Content of different topics is exchanged independently of each other.
+// Nil data stops gossiping the topic.
+func (c *Client) SetGossipContentFor(topic string, data []byte) error {
+	if topic == "" {
+		return errNoTopic
+	}
 
-	// Visualizer specific
-	viper.SetDefault("viz_update_interval", 10)
+	if reservedTopic(topic) {
+		return errReservedTopic
+	}
 
-	viper.SafeWriteConfig()
+	c.node.SetTopicGossipContent(topic, data)
 
 	return nil
 }
+
+// Sets the value of key in the keyed gossip store, each call increments the version of the key.
+// Every member eventually receives the most recent value of each key set by every other member.
+// Unlike SetGossipContent, keys are exchanged independently and only when their version changed,
+// so several subsystems can share the store without overwriting each other.
+// The values are signed, relaying members can not modify them.
+// Keys prefixed with "ifrit." are reserved for internal use and can not be set.
+func (c *Client) SetGossipKey(key string, value []byte) error {
+	if reservedTopic(key) {
+		return errReservedKey
+	}
+
+	return c.node.SetGossipKey(key, value)
+}
+
+// Returns the most recent value of key set by each member, including this client.
+func (c *Client) GossipEntries(key string) []*GossipEntry {
+	return c.node.GossipEntries(key)
+}
+
+// Registers the given function as the change handler of key.
+// Invoked each time a more recent value of key set by another member is received.
+// A nil handler removes the registration.
+func (c *Client) RegisterGossipKeyHandler(key string, handler func(*GossipEntry)) {
+	c.node.SetGossipKeyHandler(key, handler)
+}
+
+// Broadcasts the given data to every member of the network.
+// The data is signed and forwarded epidemically along the gossip partners of each member,
+// members discard duplicates and stop forwarding after a bounded number of hops.
+// The broadcast handler of every member, including this client, is invoked exactly once.
+// Receivers can check the signature of the rumor with VerifySignature(rumor.R, rumor.S, rumor.Signed, rumor.Origin).
+// The caller must ensure that the given data is not modified after calling this function.
+func (c *Client) Broadcast(data []byte) error {
+	return c.node.Broadcast(data, 0)
+}
+
+// Registers the given function as the broadcast handler.
+// Invoked once for each broadcast, see Broadcast.
+// If the handler is not registered or nil, broadcasts are still forwarded but not delivered.
+func (c *Client) RegisterBroadcastHandler(handler func(*Rumor)) {
+	c.node.SetBroadcastHandler(handler)
+}
+
+// Reliably broadcasts the given data to every member of the network using Bracha's protocol.
+// Each member echoes and signs the content it received from the origin, content is only delivered
+// once enough members vouched for it. Members are counted in the full view, crashed members included,
+// and messages are sent to the full view until each member acknowledged them.
+// As long as fewer than a third of the members are faulty,
+// either every correct member delivers the same content or none does, even if the origin equivocates.
+// The reliable broadcast handler of every member, including this client, is invoked at most once per broadcast.
+// The caller must ensure that the given data is not modified after calling this function.
+func (c *Client) ReliableBroadcast(data []byte) error {
+	return c.node.ReliableBroadcast(data)
+}
+
+// Registers the given function as the reliable broadcast handler, see ReliableBroadcast.
+func (c *Client) RegisterReliableBroadcastHandler(handler func(*ReliableMessage)) {
+	c.node.SetReliableBroadcastHandler(handler)
+}
+
+// Publishes the given data to every member subscribed to topic, see SubscribeTopic.
+// Members advertise their subscriptions through gossip, the publication is signed and forwarded
+// along the rings from subscriber to subscriber, skipping members without interest in the topic.
+// Duplicates are discarded, the handler of each subscriber, including this client, is invoked once.
+// Publications that take longer than ten minutes to reach a subscriber are discarded as well.
+// The caller must ensure that the given data is not modified after calling this function.
+func (c *Client) Publish(topic string, data []byte) error {
+	if topic == "" {
+		return errNoTopic
+	}
+
+	if reservedTopic(topic) {
+		return errReservedTopic
+	}
+
+	if len(data) == 0 {
+		return errNoData
+	}
+
+	return c.node.Publish(topic, data)
+}
+
+// Subscribes to topic, the given function is invoked once for each publication to it.
+// A nil handler cancels the subscription.
+// Other members route publications to this client once they received its subscription through gossip.
+func (c *Client) SubscribeTopic(topic string, handler func(*Publication)) error {
+	if topic == "" {
+		return errNoTopic
+	}
+
+	if reservedTopic(topic) {
+		return errReservedTopic
+	}
+
+	return c.node.SubscribeTopic(topic, handler)
+}
+
+func reservedTopic(topic string) bool {
+	return strings.HasPrefix(topic, core.InternalTopicPrefix)
+}
+
+// Adapts a handler that only needs the received data.
+func dataOnly(handler func([]byte) ([]byte, error)) func(*Request) ([]byte, error) {
+	if handler == nil {
+		return nil
+	}
+
+	return func(r *Request) ([]byte, error) {
+		return handler(r.Data)
+	}
+}
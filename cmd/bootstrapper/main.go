@@ -29,7 +29,14 @@ func createClients(requestChan chan interface{}, exitChan chan bool) {
 				addrs = append(addrs, clients[idx])
 				log.Info(addrs[0])
 			}
-			c, err := ifrit.NewClient()
+			conf, err := ifrit.LoadConfig()
+			if err != nil {
+				log.Error(err.Error())
+				continue
+			}
+			conf.EntryAddrs = addrs
+
+			c, err := ifrit.NewClient(conf)
 			if err != nil {
 				log.Error(err.Error())
 				continue
@@ -40,7 +40,12 @@ func main() {
 
 	r.SetHandler(h)
 
-	c, err := ifrit.NewClient()
+	conf, err := ifrit.LoadConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := ifrit.NewClient(conf)
 	if err != nil {
 		panic(err)
 	}
@@ -9,7 +9,6 @@ import (
 
 	pb "github.com/joonnna/ifrit/protobuf"
 	log "github.com/inconshreveable/log15"
-	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -33,7 +32,7 @@ type conn struct {
 	cc *grpc.ClientConn
 }
 
-func newClient(config *tls.Config) (*gRPCClient, error) {
+func newClient(config *tls.Config, useCompression bool) (*gRPCClient, error) {
 	var dialOptions []grpc.DialOption
 
 	if config == nil {
@@ -45,7 +44,7 @@ func newClient(config *tls.Config) (*gRPCClient, error) {
 	dialOptions = append(dialOptions, grpc.WithTransportCredentials(creds))
 	dialOptions = append(dialOptions, grpc.WithBackoffMaxDelay(time.Minute*1))
 
-	if compress := viper.GetBool("use_compression"); compress {
+	if useCompression {
 		dialOptions = append(dialOptions,
 			grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
 	}
@@ -70,13 +69,13 @@ func (c *gRPCClient) Gossip(addr string, args *pb.State) (*pb.StateResponse, err
 	return r, nil
 }
 
-func (c *gRPCClient) Send(addr string, args *pb.Msg) (*pb.MsgResponse, error) {
+func (c *gRPCClient) Send(ctx context.Context, addr string, args *pb.Msg) (*pb.MsgResponse, error) {
 	conn, err := c.connection(addr)
 	if err != nil {
 		return nil, err
 	}
 
-	r, err := conn.Messenger(context.Background(), args)
+	r, err := conn.Messenger(ctx, args)
 	if err != nil {
 		return nil, err
 	}
@@ -20,7 +20,7 @@ type Comm struct {
 	*gRPCClient
 }
 
-func NewComm(cert, caCert *x509.Certificate, priv *ecdsa.PrivateKey, l net.Listener) (*Comm, error) {
+func NewComm(cert, caCert *x509.Certificate, priv *ecdsa.PrivateKey, l net.Listener, useCompression bool) (*Comm, error) {
 	if cert == nil {
 		return nil, errNilCert
 	}
@@ -38,7 +38,7 @@ func NewComm(cert, caCert *x509.Certificate, priv *ecdsa.PrivateKey, l net.Liste
 
 	clientConf := clientConfig(cert, caCert, priv)
 
-	client, err := newClient(clientConf)
+	client, err := newClient(clientConf, useCompression)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +56,6 @@ type certSet struct {
 
 func NewCu(identity pkix.Name, caAddr string) (*CryptoUnit, error) {
 	var certs *certSet
-	var extValue []byte
 
 	if addrs := len(identity.Locality); addrs < 2 {
 		return nil, errNoAddrs
@@ -91,18 +90,11 @@ func NewCu(identity pkix.Name, caAddr string) (*CryptoUnit, error) {
 		}
 	}
 
-	for _, e := range certs.ownCert.Extensions {
-		if e.Id.Equal(asn1.ObjectIdentifier{2, 5, 13, 37}) {
-			extValue = e.Value
-		}
-	}
-
-	if extValue == nil {
-		return nil, errNoRingNum
+	numRings, err := certRings(certs.ownCert)
+	if err != nil {
+		return nil, err
 	}
 
-	numRings := binary.LittleEndian.Uint32(extValue[0:])
-
 	return &CryptoUnit{
 		ca:         certs.caCert,
 		self:       certs.ownCert,
@@ -115,6 +107,23 @@ func NewCu(identity pkix.Name, caAddr string) (*CryptoUnit, error) {
 	}, nil
 }
 
+// Returns the number of rings embedded in the certificate.
+func certRings(cert *x509.Certificate) (uint32, error) {
+	var extValue []byte
+
+	for _, e := range cert.Extensions {
+		if e.Id.Equal(asn1.ObjectIdentifier{2, 5, 13, 37}) {
+			extValue = e.Value
+		}
+	}
+
+	if len(extValue) < 4 {
+		return 0, errNoRingNum
+	}
+
+	return binary.LittleEndian.Uint32(extValue[0:]), nil
+}
+
 func (cu *CryptoUnit) Trusted() bool {
 	return cu.trusted
 }
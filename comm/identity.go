@@ -0,0 +1,207 @@
+package comm
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	keyFile      = "key.pem"
+	certFile     = "cert.pem"
+	caCertFile   = "ca.pem"
+	contactsFile = "contacts.pem"
+	epochFile    = "epoch"
+)
+
+var (
+	errNoKeyBlock  = errors.New("No private key found in identity store")
+	errNoCertBlock = errors.New("No certificate found in identity store")
+)
+
+// IdentityStore persists the private key, certificates and note epoch of a node,
+// allowing it to rejoin the network under the same id after a restart.
+type IdentityStore struct {
+	dir string
+}
+
+// Returns a store writing to and reading from the given directory,
+// the directory is created on the first save.
+func NewIdentityStore(dir string) *IdentityStore {
+	return &IdentityStore{
+		dir: dir,
+	}
+}
+
+// Returns true if the store holds a previously saved identity.
+func (is *IdentityStore) Exists() bool {
+	for _, f := range []string{keyFile, certFile} {
+		if _, err := os.Stat(filepath.Join(is.dir, f)); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Writes the key and certificates of the given crypto unit to disk.
+func (is *IdentityStore) Save(cu *CryptoUnit) error {
+	if err := os.MkdirAll(is.dir, 0700); err != nil {
+		return err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(cu.priv)
+	if err != nil {
+		return err
+	}
+
+	err = is.write(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}))
+	if err != nil {
+		return err
+	}
+
+	if err := is.write(certFile, encodeCerts(cu.self)); err != nil {
+		return err
+	}
+
+	if cu.ca != nil {
+		if err := is.write(caCertFile, encodeCerts(cu.ca)); err != nil {
+			return err
+		}
+	}
+
+	return is.write(contactsFile, encodeCerts(cu.knownCerts...))
+}
+
+// Reads a previously saved identity and returns the crypto unit together
+// with the most recent note epoch saved, zero if none was saved.
+func (is *IdentityStore) Load() (*CryptoUnit, uint64, error) {
+	keyBytes, err := ioutil.ReadFile(filepath.Join(is.dir, keyFile))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, 0, errNoKeyBlock
+	}
+
+	priv, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	certs, err := is.readCerts(certFile)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(certs) == 0 {
+		return nil, 0, errNoCertBlock
+	}
+
+	numRings, err := certRings(certs[0])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cu := &CryptoUnit{
+		self:     certs[0],
+		priv:     priv,
+		numRings: numRings,
+		pk:       certs[0].Subject,
+	}
+
+	if cas, err := is.readCerts(caCertFile); err == nil && len(cas) > 0 {
+		cu.ca = cas[0]
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+
+	cu.knownCerts, err = is.readCerts(contactsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+
+	epoch, err := is.Epoch()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return cu, epoch, nil
+}
+
+// Returns the most recent note epoch saved, zero if none was saved.
+func (is *IdentityStore) Epoch() (uint64, error) {
+	b, err := ioutil.ReadFile(filepath.Join(is.dir, epochFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// Saves the note epoch, invoked each time the local note is re-issued.
+func (is *IdentityStore) SaveEpoch(epoch uint64) error {
+	if err := os.MkdirAll(is.dir, 0700); err != nil {
+		return err
+	}
+
+	return is.write(epochFile, []byte(strconv.FormatUint(epoch, 10)))
+}
+
+// Writes to a temporary file first, a crash never leaves a partially written file behind.
+func (is *IdentityStore) write(name string, data []byte) error {
+	path := filepath.Join(is.dir, name)
+	tmp := path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (is *IdentityStore) readCerts(name string) ([]*x509.Certificate, error) {
+	var ret []*x509.Certificate
+
+	b, err := ioutil.ReadFile(filepath.Join(is.dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var block *pem.Block
+
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, c)
+	}
+
+	return ret, nil
+}
+
+func encodeCerts(certs ...*x509.Certificate) []byte {
+	var ret []byte
+
+	for _, c := range certs {
+		ret = append(ret, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+
+	return ret
+}
@@ -0,0 +1,43 @@
+package comm
+
+import (
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	require.NoError(t, err, "Failed to create directory.")
+	defer os.RemoveAll(dir)
+
+	store := NewIdentityStore(dir)
+	require.False(t, store.Exists(), "Empty store should not hold an identity.")
+
+	cu, err := NewCu(pkix.Name{Locality: []string{"127.0.0.1:8000", "127.0.0.1:8001"}}, "")
+	require.NoError(t, err, "Failed to create crypto unit.")
+
+	require.NoError(t, store.Save(cu), "Failed to save identity.")
+	require.True(t, store.Exists(), "Store should hold an identity after saving.")
+
+	loaded, epoch, err := store.Load()
+	require.NoError(t, err, "Failed to load identity.")
+	require.Equal(t, uint64(0), epoch, "No epoch was saved.")
+	require.Equal(t, cu.Certificate().Raw, loaded.Certificate().Raw, "Loaded certificate differs.")
+	require.Equal(t, cu.Priv().D, loaded.Priv().D, "Loaded private key differs.")
+	require.Equal(t, cu.NumRings(), loaded.NumRings(), "Loaded number of rings differs.")
+	require.Nil(t, loaded.CaCertificate(), "Self-signed identity should have no ca certificate.")
+
+	r, s, err := loaded.Sign([]byte("data"))
+	require.NoError(t, err, "Failed to sign with loaded key.")
+	require.True(t, cu.Verify([]byte("data"), r, s, &cu.Priv().PublicKey), "Signature of loaded key should verify.")
+
+	require.NoError(t, store.SaveEpoch(7), "Failed to save epoch.")
+
+	_, epoch, err = store.Load()
+	require.NoError(t, err, "Failed to load identity.")
+	require.Equal(t, uint64(7), epoch, "Invalid epoch loaded.")
+}
@@ -9,10 +9,15 @@ import (
 	pb "github.com/joonnna/ifrit/protobuf"
 )
 
+const defaultPingTimeout = time.Second * 5
+
 type UDPServer struct {
 	conn *net.UDPConn
 	addr string
 
+	// How long Ping waits for a pong.
+	timeout time.Duration
+
 	exitChan  chan bool
 	pauseChan chan time.Duration
 
@@ -23,9 +28,15 @@ type pongSigner interface {
 	Sign([]byte) ([]byte, []byte, error)
 }
 
-func NewUdpServer(ps pongSigner, conn *net.UDPConn) (*UDPServer, error) {
+// Creates a ping service on conn, pings wait for pongs for at most timeout, 5 seconds if zero.
+func NewUdpServer(ps pongSigner, conn *net.UDPConn, timeout time.Duration) (*UDPServer, error) {
+	if timeout == 0 {
+		timeout = defaultPingTimeout
+	}
+
 	return &UDPServer{
 		conn:       conn,
+		timeout:    timeout,
 		exitChan:   make(chan bool, 1),
 		pauseChan:  make(chan time.Duration, 1),
 		pongSigner: ps,
@@ -43,7 +54,7 @@ func (us *UDPServer) Ping(addr string, p *pb.Ping) (*pb.Pong, error) {
 		return nil, err
 	}
 	defer c.Close()
-	c.SetDeadline(time.Now().Add(time.Second * 5))
+	c.SetDeadline(time.Now().Add(us.timeout))
 
 	data, err := proto.Marshal(p)
 	if err != nil {
@@ -0,0 +1,219 @@
+package ifrit
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joonnna/ifrit/core"
+	"github.com/joonnna/ifrit/fault"
+	"github.com/spf13/viper"
+)
+
+// Config holds all parameters of an ifrit client.
+// Each client owns its config, several clients with
+// different configurations can run within the same process.
+type Config struct {
+	// Address (ip:port) of the certificate authority.
+	// If empty, a self-signed certificate is generated and
+	// the client joins the network through EntryAddrs.
+	CaAddr string
+
+	// Addresses (ip:port) of existing participants,
+	// only used when CaAddr is empty.
+	EntryAddrs []string
+
+	// How often the client gossips with a neighbouring peer.
+	GossipInterval time.Duration
+
+	// How often the client monitors other peers.
+	MonitorInterval time.Duration
+
+	// How often the client checks for expired accusations.
+	ViewUpdateInterval time.Duration
+
+	// How many failed pings before a peer is considered dead.
+	PingLimit uint32
+
+	// How many peers are monitored each monitor interval.
+	PingsPerInterval int
+
+	// How many other peers are asked to ping a peer that failed PingLimit consecutive pings.
+	// The peer is only considered dead if all of them fail as well, zero disables indirect probes.
+	IndirectProbes int
+
+	// Suspicion level at which peers failing to answer pings are considered dead, see Client.Suspicions.
+	// A level of 1 corresponds to a 10% chance of wrongly suspecting the peer, 2 to 1%, etc.
+	// Zero considers peers dead after PingLimit consecutive failed pings,
+	// as does any threshold until enough pongs have arrived from the peer.
+	PhiThreshold float64
+
+	// How long the client waits for a pong.
+	PingTimeout time.Duration
+
+	// How long the client waits after discovering an unresponsive
+	// peer before removing it from its live view.
+	RemovalTimeout time.Duration
+
+	// The maximum concurrent outgoing messages through the messaging service.
+	MaxConcurrentMessages uint32
+
+	// Whether gossip and messages should be gzip compressed.
+	UseCompression bool
+
+	// Directory where the private key, certificates and note epoch of the client are stored.
+	// If empty, nothing is stored and the client gets a new identity on every start.
+	IdentityDir string
+
+	// How long a leader's lease on a group lasts, leases are renewed after a third of it.
+	// A new leader waits for this long before granting leases.
+	LeaseDuration time.Duration
+
+	// Whether to resume the identity stored in IdentityDir instead of creating a new one.
+	// A resumed client rejoins under the same id and addresses with a more recent note,
+	// peers treat it as a rebuttal rather than a new member.
+	ResumeIdentity bool
+
+	// Injects network faults into the outgoing calls and pings of the client, see the fault package.
+	// Not read from the config file, nil disables fault injection.
+	Faults *fault.Injector
+
+	// Name of the client within Faults, defaults to the rpc address of the client.
+	FaultName string
+
+	// Visualizer specific
+	UseViz            bool
+	VizAddr           string
+	VizUpdateInterval time.Duration
+}
+
+// Returns a config populated with the default values.
+func DefaultConfig() *Config {
+	return &Config{
+		GossipInterval:        time.Second * 10,
+		MonitorInterval:       time.Second * 10,
+		ViewUpdateInterval:    time.Second * 10,
+		PingLimit:             3,
+		PingsPerInterval:      3,
+		IndirectProbes:        3,
+		PhiThreshold:          8,
+		PingTimeout:           time.Second * 5,
+		RemovalTimeout:        time.Second * 60,
+		MaxConcurrentMessages: 5,
+		UseCompression:        true,
+		LeaseDuration:         time.Second * 10,
+		VizUpdateInterval:     time.Second * 10,
+	}
+}
+
+// Reads the config file "ifrit_config.yml" from the given paths,
+// defaults to the current working directory and /var/tmp.
+// Environment variables prefixed with IFRIT_ (e.g IFRIT_CA_ADDR) override the file.
+// Missing variables are populated with their default values.
+// If no config file exists, one is generated containing the default values.
+// All intervals and timeouts in the file are given in seconds.
+func LoadConfig(paths ...string) (*Config, error) {
+	def := DefaultConfig()
+
+	v := viper.New()
+
+	v.SetConfigName("ifrit_config")
+	v.SetConfigType("yaml")
+
+	if len(paths) == 0 {
+		paths = []string{"/var/tmp", "."}
+	}
+
+	for _, p := range paths {
+		v.AddConfigPath(p)
+	}
+
+	v.SetEnvPrefix("ifrit")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// Behavior variables
+	v.SetDefault("ca_addr", "")
+	v.SetDefault("entry_addrs", []string{})
+	v.SetDefault("gossip_interval", seconds(def.GossipInterval))
+	v.SetDefault("monitor_interval", seconds(def.MonitorInterval))
+	v.SetDefault("view_update_interval", seconds(def.ViewUpdateInterval))
+	v.SetDefault("ping_limit", def.PingLimit)
+	v.SetDefault("pings_per_interval", def.PingsPerInterval)
+	v.SetDefault("indirect_probes", def.IndirectProbes)
+	v.SetDefault("phi_threshold", def.PhiThreshold)
+	v.SetDefault("ping_timeout", def.PingTimeout.String())
+	v.SetDefault("removal_timeout", seconds(def.RemovalTimeout))
+	v.SetDefault("max_concurrent_messages", def.MaxConcurrentMessages)
+	v.SetDefault("use_compression", def.UseCompression)
+	v.SetDefault("identity_dir", def.IdentityDir)
+	v.SetDefault("resume_identity", def.ResumeIdentity)
+	v.SetDefault("lease_duration", seconds(def.LeaseDuration))
+
+	// Visualizer specific
+	v.SetDefault("use_viz", def.UseViz)
+	v.SetDefault("viz_addr", def.VizAddr)
+	v.SetDefault("viz_update_interval", seconds(def.VizUpdateInterval))
+
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, err
+		}
+		v.SafeWriteConfig()
+	}
+
+	return &Config{
+		CaAddr:                v.GetString("ca_addr"),
+		EntryAddrs:            v.GetStringSlice("entry_addrs"),
+		GossipInterval:        time.Second * time.Duration(v.GetInt("gossip_interval")),
+		MonitorInterval:       time.Second * time.Duration(v.GetInt("monitor_interval")),
+		ViewUpdateInterval:    time.Second * time.Duration(v.GetInt("view_update_interval")),
+		PingLimit:             v.GetUint32("ping_limit"),
+		PingsPerInterval:      v.GetInt("pings_per_interval"),
+		IndirectProbes:        v.GetInt("indirect_probes"),
+		PhiThreshold:          v.GetFloat64("phi_threshold"),
+		PingTimeout:           duration(v, "ping_timeout"),
+		RemovalTimeout:        time.Second * time.Duration(v.GetInt("removal_timeout")),
+		MaxConcurrentMessages: v.GetUint32("max_concurrent_messages"),
+		UseCompression:        v.GetBool("use_compression"),
+		IdentityDir:           v.GetString("identity_dir"),
+		ResumeIdentity:        v.GetBool("resume_identity"),
+		LeaseDuration:         time.Second * time.Duration(v.GetInt("lease_duration")),
+		UseViz:                v.GetBool("use_viz"),
+		VizAddr:               v.GetString("viz_addr"),
+		VizUpdateInterval:     time.Second * time.Duration(v.GetInt("viz_update_interval")),
+	}, nil
+}
+
+func (c *Config) nodeConfig() *core.Config {
+	return &core.Config{
+		GossipInterval:        c.GossipInterval,
+		MonitorInterval:       c.MonitorInterval,
+		ViewUpdateInterval:    c.ViewUpdateInterval,
+		PingLimit:             c.PingLimit,
+		PingsPerInterval:      c.PingsPerInterval,
+		IndirectProbes:        c.IndirectProbes,
+		PhiThreshold:          c.PhiThreshold,
+		PingTimeout:           c.PingTimeout,
+		RemovalTimeout:        c.RemovalTimeout,
+		MaxConcurrentMessages: c.MaxConcurrentMessages,
+		EntryAddrs:            c.EntryAddrs,
+		LeaseDuration:         c.LeaseDuration,
+		UseViz:                c.UseViz,
+		VizAddr:               c.VizAddr,
+		VizUpdateInterval:     c.VizUpdateInterval,
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(d / time.Second)
+}
+
+// Reads a duration such as "500ms", plain numbers are read as seconds like the other intervals.
+func duration(v *viper.Viper, key string) time.Duration {
+	if secs, err := strconv.Atoi(v.GetString(key)); err == nil {
+		return time.Second * time.Duration(secs)
+	}
+
+	return v.GetDuration(key)
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+)
+
+var errInvalidFloodSize = errors.New("Number of flooded certificates must be positive")
+
+// The adversaries below misbehave in a single way and otherwise behave correctly,
+// they let applications be tested against a hostile minority of the network.
+
+type accusationSpam struct {
+	correct
+}
+
+type equivocation struct {
+	correct
+
+	epoch uint64
+	mutex sync.Mutex
+}
+
+type withholding struct {
+	correct
+
+	victims map[string]bool
+}
+
+type certificateFlood struct {
+	correct
+
+	certs []*pb.Certificate
+}
+
+type noRebuttal struct {
+	correct
+}
+
+// Returns a behavior accusing every live peer on every ring each monitor interval, without probing them.
+// Correct nodes only accept accusations from the ring predecessor of the accused, who rebuts them.
+func AccusationSpam() Behavior {
+	return accusationSpam{}
+}
+
+// Returns a behavior gossiping a new epoch of its note each gossip interval, signed in two versions:
+// partners alternately receive a note announcing that the node leaves and one that it stays.
+func EquivocatingNotes() Behavior {
+	return &equivocation{}
+}
+
+// Returns a behavior withholding all gossip from the nodes with the given addresses.
+// It neither gossips with them nor includes anything in replies to their gossip.
+// Without addresses, gossip is withheld from everyone.
+func WithholdGossip(addrs ...string) Behavior {
+	victims := make(map[string]bool)
+
+	for _, addr := range addrs {
+		victims[addr] = true
+	}
+
+	return &withholding{victims: victims}
+}
+
+// Returns a behavior including count self-signed certificates of non-existing nodes in all
+// replies to gossip. Networks with a CA reject them, networks without one add them to their full view.
+func CertificateFlood(count int) (Behavior, error) {
+	if count <= 0 {
+		return nil, errInvalidFloodSize
+	}
+
+	f := &certificateFlood{}
+
+	for i := 0; i < count; i++ {
+		raw, err := selfSignedCertificate(i)
+		if err != nil {
+			return nil, err
+		}
+
+		f.certs = append(f.certs, &pb.Certificate{Raw: raw})
+	}
+
+	return f, nil
+}
+
+// Returns a behavior ignoring accusations, the node is removed once accused by its ring predecessor.
+func NoRebuttal() Behavior {
+	return noRebuttal{}
+}
+
+func (sa accusationSpam) Monitor(n *Node) {
+	var ringNum uint32
+
+	for _, p := range n.view.Live() {
+		for ringNum = 1; ringNum <= n.view.NumRings(); ringNum++ {
+			n.accuse(p, ringNum)
+		}
+	}
+}
+
+func (e *equivocation) Gossip(n *Node) {
+	local := n.self.Note()
+
+	e.mutex.Lock()
+	if e.epoch < local.Epoch() {
+		e.epoch = local.Epoch()
+	}
+	e.epoch++
+	epoch := e.epoch
+	e.mutex.Unlock()
+
+	for i, p := range n.view.GossipPartners() {
+		note, err := n.signNote(epoch, local.Mask(), i%2 == 0)
+		if err != nil {
+			log.Error(err.Error())
+			continue
+		}
+
+		msg := n.collectGossipContent()
+		msg.OwnNote = note
+
+		n.gossipWith([]*discovery.Peer{p}, msg)
+	}
+}
+
+func (w *withholding) Gossip(n *Node) {
+	n.gossipWith(w.partners(n), n.collectGossipContent())
+}
+
+func (w *withholding) Rebuttal(n *Node, epoch uint64, ringNum uint32) {
+	if rebut := n.view.ShouldRebuttal(epoch, ringNum); !rebut {
+		return
+	}
+
+	n.persistEpoch()
+	n.sendNote(w.partners(n), n.self.Note().ToPbMsg())
+}
+
+func (w *withholding) Reply(n *Node, id string, reply *pb.StateResponse) {
+	if w.withholds(n.view.Peer(id)) {
+		reply.Reset()
+	}
+}
+
+func (w *withholding) partners(n *Node) []*discovery.Peer {
+	var ret []*discovery.Peer
+
+	for _, p := range n.view.GossipPartners() {
+		if !w.withholds(p) {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret
+}
+
+func (w *withholding) withholds(p *discovery.Peer) bool {
+	if len(w.victims) == 0 {
+		return true
+	}
+
+	return p != nil && w.victims[p.Addr]
+}
+
+func (f *certificateFlood) Reply(n *Node, id string, reply *pb.StateResponse) {
+	reply.Certificates = append(reply.Certificates, f.certs...)
+}
+
+func (nr noRebuttal) Rebuttal(n *Node, epoch uint64, ringNum uint32) {
+	log.Debug("Refusing to rebut accusation", "epoch", epoch, "ringNum", ringNum)
+}
+
+// Signs a note of the local peer, without replacing the current local note.
+func (n *Node) signNote(epoch uint64, mask uint32, leaving bool) (*pb.Note, error) {
+	note := &pb.Note{
+		Epoch:   epoch,
+		Mask:    mask,
+		Id:      []byte(n.self.Id),
+		Leaving: leaving,
+	}
+
+	b, err := proto.Marshal(note)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := n.cs.Sign(b)
+	if err != nil {
+		return nil, err
+	}
+
+	note.Signature = &pb.Signature{
+		R: r,
+		S: s,
+	}
+
+	return note, nil
+}
+
+// Creates a certificate of a non-existing node, with a random id and unreachable addresses.
+func selfSignedCertificate(serial int) ([]byte, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	addr := fmt.Sprintf("192.0.2.1:%d", serial+1)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(serial + 1)),
+		SubjectKeyId: id,
+		Subject:      pkix.Name{Locality: []string{addr, addr}},
+		NotBefore:    time.Now().AddDate(-1, 0, 0),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	return x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+}
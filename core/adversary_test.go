@@ -0,0 +1,69 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *HandlerTestSuite) TestNoRebuttal() {
+	node := suite.n
+
+	_, prev := node.view.MyRingNeighbours(1)
+	epoch := node.self.Note().Epoch()
+
+	node.SetBehavior(NoRebuttal())
+
+	acc := discovery.NewAccusation(epoch, node.self.Id, prev.Id, 1, suite.privMap[prev.Id])
+	require.NoError(suite.T(), node.evalAccusation(acc, prev, node.self), "Valid accusation was rejected.")
+	require.Equal(suite.T(), epoch, node.self.Note().Epoch(), "Note should not be re-issued.")
+
+	node.SetBehavior(nil)
+
+	acc = discovery.NewAccusation(epoch, node.self.Id, prev.Id, 1, suite.privMap[prev.Id])
+	require.NoError(suite.T(), node.evalAccusation(acc, prev, node.self), "Valid accusation was rejected.")
+	require.Equal(suite.T(), epoch+1, node.self.Note().Epoch(), "Note should be re-issued.")
+}
+
+func (suite *HandlerTestSuite) TestEquivocatingNotes() {
+	node := suite.n
+
+	epoch := node.self.Note().Epoch() + 1
+
+	leaving, err := node.signNote(epoch, node.self.Note().Mask(), true)
+	require.NoError(suite.T(), err, "Failed to sign note.")
+
+	staying, err := node.signNote(epoch, node.self.Note().Mask(), false)
+	require.NoError(suite.T(), err, "Failed to sign note.")
+
+	for _, note := range []*pb.Note{leaving, staying} {
+		sign := note.GetSignature()
+		note.Signature = nil
+
+		b, err := proto.Marshal(note)
+		require.NoError(suite.T(), err, "Failed to marshal note.")
+		require.True(suite.T(), node.cs.Verify(b, sign.GetR(), sign.GetS(), node.self.PublicKey()), "Invalid note signature.")
+	}
+
+	require.Equal(suite.T(), epoch-1, node.self.Note().Epoch(), "Local note should not be replaced.")
+}
+
+func (suite *HandlerTestSuite) TestCertificateFlood() {
+	node := suite.n
+
+	_, err := CertificateFlood(0)
+	require.Equal(suite.T(), errInvalidFloodSize, err, "Empty flood should be rejected.")
+
+	flood, err := CertificateFlood(10)
+	require.NoError(suite.T(), err, "Failed to create flood.")
+
+	reply := &pb.StateResponse{}
+	flood.Reply(node, "", reply)
+	require.Len(suite.T(), reply.GetCertificates(), 10, "Invalid number of flooded certificates.")
+
+	// Without a CA the certificates are indistinguishable from those of real nodes.
+	prevSize := len(node.view.Full())
+	node.mergeCertificates(reply.GetCertificates())
+	require.Len(suite.T(), node.view.Full(), prevSize+10, "Flooded certificates should be added to full view.")
+}
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	pb "github.com/joonnna/ifrit/protobuf"
+)
+
+const (
+	// Bound on the clock difference between members, rumors stamped further in the future are rejected.
+	maxClockSkew = time.Second * 30
+)
+
+var (
+	errSeenRumor     = errors.New("Rumor already seen")
+	errNoRumorOrigin = errors.New("Origin of rumor not found in full view")
+	errExpiredRumor  = errors.New("Rumor is older than the duplicate detection window")
+	errFutureRumor   = errors.New("Rumor is stamped in the future")
+)
+
+// Rumor is a message broadcast to the entire network, see Broadcast.
+type Rumor struct {
+	// Ifrit id of the member that broadcast the message.
+	Origin string
+
+	Content []byte
+
+	// Bytes signed by the origin, covering the origin, content and a sequence number.
+	Signed []byte
+
+	// Signature of the origin over Signed.
+	R []byte
+	S []byte
+}
+
+type activeRumor struct {
+	msg *pb.Rumor
+
+	// Gossip rounds left before the rumor is no longer forwarded.
+	rounds uint32
+}
+
+// Rumors currently forwarded and the ids of all rumors seen recently.
+type rumorBuffer struct {
+	active map[string]*activeRumor
+	seen   map[string]time.Time
+
+	// Sequence number of the last local rumor.
+	seq uint64
+
+	handler func(*Rumor)
+
+	mutex sync.RWMutex
+}
+
+func newRumorBuffer() *rumorBuffer {
+	return &rumorBuffer{
+		active: make(map[string]*activeRumor),
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Broadcasts the given data to all members of the network.
+// The data is signed together with a timestamp sequence number and piggybacked on gossip,
+// each member forwards it to its gossip partners on every ring before discarding it.
+// Members reject rumors older than their forwarding lifetime, clocks are assumed to be within maxClockSkew.
+// The rumor travels at most ttl hops, a ttl of zero is replaced by a bound derived
+// from the size of the live view which suffices to reach all members.
+// The broadcast handler of every member is invoked exactly once, the local
+// handler is invoked before Broadcast returns.
+func (n *Node) Broadcast(data []byte, ttl uint32) error {
+	if len(data) == 0 {
+		return errNoData
+	}
+
+	if ttl == 0 {
+		// Worst case distance between two members on a single ring.
+		ttl = uint32(len(n.view.Live())+1)/2 + 1
+	}
+
+	msg := &pb.Rumor{
+		Origin:  []byte(n.self.Id),
+		Content: data,
+		Seq:     n.nextRumorSeq(),
+		Hops:    ttl,
+	}
+
+	signed, err := rumorBytes(msg)
+	if err != nil {
+		return err
+	}
+
+	r, s, err := n.cs.Sign(signed)
+	if err != nil {
+		return err
+	}
+
+	msg.Ttl = ttl
+	msg.Signature = &pb.Signature{
+		R: r,
+		S: s,
+	}
+
+	n.addRumor(rumorId(signed), msg)
+
+	n.deliverRumor(msg)
+
+	return nil
+}
+
+// Registers the handler invoked once for each received broadcast, a nil handler removes it.
+func (n *Node) SetBroadcastHandler(handler func(*Rumor)) {
+	n.rumors.mutex.Lock()
+	defer n.rumors.mutex.Unlock()
+
+	n.rumors.handler = handler
+}
+
+// Returns the rumors to forward this gossip round.
+// Rumors are forwarded for as many rounds as there are rings,
+// gossip partners are picked from a new ring each round.
+func (n *Node) collectRumors() []*pb.Rumor {
+	var ret []*pb.Rumor
+
+	now := time.Now()
+
+	n.rumors.mutex.Lock()
+	defer n.rumors.mutex.Unlock()
+
+	for id, r := range n.rumors.active {
+		ret = append(ret, r.msg)
+
+		r.rounds--
+		if r.rounds == 0 {
+			delete(n.rumors.active, id)
+		}
+	}
+
+	for id, expire := range n.rumors.seen {
+		if now.After(expire) {
+			delete(n.rumors.seen, id)
+		}
+	}
+
+	return ret
+}
+
+func (n *Node) mergeRumors(rumors []*pb.Rumor) {
+	for _, r := range rumors {
+		if err := n.evalRumor(r); err != nil && err != errSeenRumor {
+			log.Debug(err.Error())
+		}
+	}
+}
+
+func (n *Node) evalRumor(r *pb.Rumor) error {
+	signed, err := rumorBytes(r)
+	if err != nil {
+		return err
+	}
+
+	id := rumorId(signed)
+
+	n.rumors.mutex.RLock()
+	_, seen := n.rumors.seen[id]
+	n.rumors.mutex.RUnlock()
+
+	if seen {
+		return errSeenRumor
+	}
+
+	// Rumors are only remembered until they expire, older copies could otherwise be delivered again.
+	now := time.Now()
+	if now.After(n.rumorExpiry(r)) {
+		return errExpiredRumor
+	}
+
+	if time.Unix(0, int64(r.GetSeq())).After(now.Add(maxClockSkew)) {
+		return errFutureRumor
+	}
+
+	sign := r.GetSignature()
+	if sign == nil {
+		return errInvalidSignature
+	}
+
+	origin := string(r.GetOrigin())
+
+	p := n.view.Peer(origin)
+	if p == nil {
+		return errNoRumorOrigin
+	}
+
+	if valid := n.cs.Verify(signed, sign.GetR(), sign.GetS(), p.PublicKey()); !valid {
+		return errInvalidSignature
+	}
+
+	// The ttl is not covered by the signature, it only bounds forwarding.
+	// Copies forwarded for longer than the signed hops allow are rejected as expired.
+	fwd := &pb.Rumor{
+		Origin:    r.GetOrigin(),
+		Content:   r.GetContent(),
+		Seq:       r.GetSeq(),
+		Hops:      r.GetHops(),
+		Signature: sign,
+	}
+
+	if ttl := r.GetTtl(); ttl > 1 {
+		fwd.Ttl = ttl - 1
+	}
+
+	if !n.addRumor(id, fwd) {
+		return errSeenRumor
+	}
+
+	n.deliverRumor(fwd)
+
+	return nil
+}
+
+// Marks the rumor as seen and starts forwarding it if its ttl allows it.
+// Returns false if the rumor was already seen.
+func (n *Node) addRumor(id string, r *pb.Rumor) bool {
+	rounds := n.view.NumRings()
+	expiry := n.rumorExpiry(r)
+
+	n.rumors.mutex.Lock()
+	defer n.rumors.mutex.Unlock()
+
+	if _, seen := n.rumors.seen[id]; seen {
+		return false
+	}
+
+	n.rumors.seen[id] = expiry
+
+	if r.GetTtl() > 0 {
+		n.rumors.active[id] = &activeRumor{
+			msg:    r,
+			rounds: rounds,
+		}
+	}
+
+	return true
+}
+
+func (n *Node) deliverRumor(r *pb.Rumor) {
+	n.rumors.mutex.RLock()
+	handler := n.rumors.handler
+	n.rumors.mutex.RUnlock()
+
+	if handler == nil {
+		return
+	}
+
+	signed, err := rumorBytes(r)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	sign := r.GetSignature()
+
+	handler(&Rumor{
+		Origin:  string(r.GetOrigin()),
+		Content: r.GetContent(),
+		Signed:  signed,
+		R:       sign.GetR(),
+		S:       sign.GetS(),
+	})
+}
+
+// Returns a sequence number greater than that of all previous local rumors.
+// Sequence numbers are timestamps, they keep increasing across restarts.
+func (n *Node) nextRumorSeq() uint64 {
+	n.rumors.mutex.Lock()
+	defer n.rumors.mutex.Unlock()
+
+	seq := uint64(time.Now().UnixNano())
+	if seq <= n.rumors.seq {
+		seq = n.rumors.seq + 1
+	}
+
+	n.rumors.seq = seq
+
+	return seq
+}
+
+// Returns the time after which no copy of the rumor is forwarded anymore.
+// Copies can keep arriving until every forwarding member has exhausted
+// its rounds, no copy survives more than hops hops of rounds each.
+func (n *Node) rumorExpiry(r *pb.Rumor) time.Time {
+	keep := time.Duration((r.GetHops()+1)*n.view.NumRings()) * n.getGossipTimeout()
+
+	return time.Unix(0, int64(r.GetSeq())).Add(keep + maxClockSkew)
+}
+
+// Returns the bytes signed by the origin of the rumor, ttl and signature are excluded.
+func rumorBytes(r *pb.Rumor) ([]byte, error) {
+	return proto.Marshal(&pb.Rumor{
+		Origin:  r.GetOrigin(),
+		Content: r.GetContent(),
+		Seq:     r.GetSeq(),
+		Hops:    r.GetHops(),
+	})
+}
+
+// Identifies a rumor by a hash of its signed bytes, the signature itself is malleable.
+// Broadcasting the same content twice yields two distinct rumors, as their sequence numbers differ.
+func rumorId(signed []byte) string {
+	return string(hashContent(signed))
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"time"
+
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *HandlerTestSuite) TestBroadcast() {
+	var delivered []*Rumor
+
+	node := suite.n
+	numRings := node.view.NumRings()
+
+	node.SetBroadcastHandler(func(r *Rumor) {
+		delivered = append(delivered, r)
+	})
+
+	require.Equal(suite.T(), errNoData, node.Broadcast(nil, 0), "Empty broadcast should fail.")
+
+	require.NoError(suite.T(), node.Broadcast([]byte("local"), 3), "Failed to broadcast.")
+	require.Len(suite.T(), delivered, 1, "Local handler was not invoked.")
+	require.Equal(suite.T(), node.self.Id, delivered[0].Origin, "Invalid origin.")
+	require.True(suite.T(), node.Verify(delivered[0].R, delivered[0].S, delivered[0].Signed, node.self.Id),
+		"Signature of own rumor should verify.")
+
+	// Forwarded once per ring.
+	for i := uint32(0); i < numRings; i++ {
+		rumors := node.collectRumors()
+		require.Len(suite.T(), rumors, 1, "Rumor should be forwarded for each ring.")
+		require.Equal(suite.T(), uint32(3), rumors[0].GetTtl(), "Originator should not decrement ttl.")
+	}
+	require.Empty(suite.T(), node.collectRumors(), "Rumor should no longer be forwarded.")
+}
+
+func (suite *HandlerTestSuite) TestEvalRumor() {
+	var delivered []*Rumor
+
+	node := suite.n
+	p := node.view.Full()[0]
+
+	node.SetBroadcastHandler(func(r *Rumor) {
+		delivered = append(delivered, r)
+	})
+
+	r := suite.signedRumor(p.Id, []byte("remote"), 2)
+
+	require.NoError(suite.T(), node.evalRumor(r), "Valid rumor was rejected.")
+	require.Equal(suite.T(), errSeenRumor, node.evalRumor(r), "Duplicate rumor should be discarded.")
+	require.Len(suite.T(), delivered, 1, "Handler should be invoked exactly once.")
+	require.Equal(suite.T(), p.Id, delivered[0].Origin, "Invalid origin.")
+	require.True(suite.T(), node.Verify(delivered[0].R, delivered[0].S, delivered[0].Signed, p.Id),
+		"Signature of received rumor should verify.")
+
+	rumors := node.collectRumors()
+	require.Len(suite.T(), rumors, 1, "Received rumor should be forwarded.")
+	require.Equal(suite.T(), uint32(1), rumors[0].GetTtl(), "Ttl should be decremented.")
+
+	last := suite.signedRumor(p.Id, []byte("last hop"), 1)
+	require.NoError(suite.T(), node.evalRumor(last), "Valid rumor was rejected.")
+	require.Len(suite.T(), delivered, 2, "Rumor on its last hop should be delivered.")
+	for _, fwd := range node.collectRumors() {
+		require.NotEqual(suite.T(), []byte("last hop"), fwd.GetContent(), "Rumor on its last hop should not be forwarded.")
+	}
+
+	tampered := suite.signedRumor(p.Id, []byte("content"), 2)
+	tampered.Content = []byte("tampered")
+	require.Equal(suite.T(), errInvalidSignature, node.evalRumor(tampered), "Tampered rumor should be rejected.")
+
+	unknown := suite.signedRumor(p.Id, []byte("content"), 2)
+	unknown.Origin = genId()
+	require.Equal(suite.T(), errNoRumorOrigin, node.evalRumor(unknown), "Rumor from unknown origin should be rejected.")
+
+	tampered = suite.signedRumor(p.Id, []byte("content"), 2)
+	tampered.Seq++
+	require.Equal(suite.T(), errInvalidSignature, node.evalRumor(tampered), "Rumor with tampered seq should be rejected.")
+
+	require.Len(suite.T(), delivered, 2, "Handler invoked for rejected rumors.")
+}
+
+func (suite *HandlerTestSuite) TestRumorReplay() {
+	var delivered []*Rumor
+
+	node := suite.n
+	p := node.view.Full()[0]
+
+	node.SetBroadcastHandler(func(r *Rumor) {
+		delivered = append(delivered, r)
+	})
+
+	r := suite.signedRumor(p.Id, []byte("content"), 2)
+	require.NoError(suite.T(), node.evalRumor(r), "Valid rumor was rejected.")
+
+	// A second signature over the same rumor does not make it a new one.
+	resigned := suite.signRumor(p.Id, &pb.Rumor{Origin: r.Origin, Content: r.Content, Seq: r.Seq, Hops: r.Hops, Ttl: 2})
+	require.NotEqual(suite.T(), r.GetSignature().GetR(), resigned.GetSignature().GetR(), "Signatures should differ.")
+	require.Equal(suite.T(), errSeenRumor, node.evalRumor(resigned), "Re-signed rumor should be discarded.")
+
+	// Same content broadcast twice yields distinct rumors.
+	again := suite.signedRumor(p.Id, []byte("content"), 2)
+	require.NoError(suite.T(), node.evalRumor(again), "Rebroadcast content was rejected.")
+
+	old := suite.signRumor(p.Id, &pb.Rumor{
+		Origin:  []byte(p.Id),
+		Content: []byte("content"),
+		Seq:     uint64(time.Now().Add(-time.Hour).UnixNano()),
+		Hops:    2,
+		Ttl:     2,
+	})
+	require.Equal(suite.T(), errExpiredRumor, node.evalRumor(old), "Rumor older than its lifetime should be rejected.")
+
+	future := suite.signRumor(p.Id, &pb.Rumor{
+		Origin:  []byte(p.Id),
+		Content: []byte("content"),
+		Seq:     uint64(time.Now().Add(time.Hour).UnixNano()),
+		Hops:    2,
+		Ttl:     2,
+	})
+	require.Equal(suite.T(), errFutureRumor, node.evalRumor(future), "Rumor from the future should be rejected.")
+
+	require.Len(suite.T(), delivered, 2, "Replayed rumors should not be delivered.")
+}
+
+func (suite *HandlerTestSuite) signedRumor(origin string, content []byte, ttl uint32) *pb.Rumor {
+	return suite.signRumor(origin, &pb.Rumor{
+		Origin:  []byte(origin),
+		Content: content,
+		Seq:     suite.n.nextRumorSeq(),
+		Hops:    ttl,
+		Ttl:     ttl,
+	})
+}
+
+func (suite *HandlerTestSuite) signRumor(origin string, msg *pb.Rumor) *pb.Rumor {
+	signed, err := rumorBytes(msg)
+	require.NoError(suite.T(), err, "Failed to marshal rumor.")
+
+	cs := &cryptoStub{priv: suite.privMap[origin]}
+	r, s, err := cs.Sign(signed)
+	require.NoError(suite.T(), err, "Failed to sign rumor.")
+
+	msg.Signature = &pb.Signature{R: r, S: s}
+
+	return msg
+}
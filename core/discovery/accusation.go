@@ -29,6 +29,10 @@ func (a Accusation) IsMoreRecent(other uint64) bool {
 	return a.epoch < other
 }
 
+func (a Accusation) RingNum() uint32 {
+	return a.ringNum
+}
+
 func (a Accusation) IsAccuser(id string) bool {
 	return a.accuser == id
 }
@@ -0,0 +1,28 @@
+package discovery
+
+import (
+	"time"
+)
+
+// Clock provides the time used by timers and periodic loops.
+// Simulations replace it with a virtual clock, see SetClock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct {
+}
+
+// Returns the clock backed by the time package.
+func RealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
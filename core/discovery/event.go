@@ -0,0 +1,147 @@
+package discovery
+
+import (
+	"sync"
+
+	log "github.com/inconshreveable/log15"
+)
+
+const (
+	eventBufferSize = 100
+)
+
+// EventType identifies the kind of membership change an event describes.
+type EventType uint8
+
+const (
+	// Peer was added to the live view.
+	PeerJoined EventType = iota + 1
+
+	// Peer was accused on the ring given by RingNum.
+	PeerAccused
+
+	// Peer rebutted all accusations against it by issuing a more recent note.
+	PeerRebutted
+
+	// Peer was removed from the live view, typically after the removal timeout expired.
+	PeerRemoved
+
+	// The local note was re-issued to rebut an accusation on the ring given by RingNum.
+	NoteReissued
+
+	// Peer announced that it is leaving the network and was removed from the live view.
+	PeerLeft
+
+	// The leader of the group given by Group changed, Id and Addr are empty if the group has no leader.
+	LeaderChanged
+)
+
+func (t EventType) String() string {
+	switch t {
+	case PeerJoined:
+		return "PeerJoined"
+	case PeerAccused:
+		return "PeerAccused"
+	case PeerRebutted:
+		return "PeerRebutted"
+	case PeerRemoved:
+		return "PeerRemoved"
+	case NoteReissued:
+		return "NoteReissued"
+	case PeerLeft:
+		return "PeerLeft"
+	case LeaderChanged:
+		return "LeaderChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event describes a single membership change.
+type Event struct {
+	Type EventType
+
+	// Ifrit id and address of the peer the event concerns,
+	// for NoteReissued this is the local peer.
+	Id   string
+	Addr string
+
+	// Only set for PeerAccused and NoteReissued.
+	RingNum uint32
+
+	// Epoch of the note the event concerns, zero if unknown.
+	Epoch uint64
+
+	// Only set for LeaderChanged.
+	Group string
+}
+
+type eventBus struct {
+	subs   map[uint64]chan Event
+	nextId uint64
+	closed bool
+	mutex  sync.RWMutex
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs: make(map[uint64]chan Event),
+	}
+}
+
+func (eb *eventBus) subscribe() (<-chan Event, func()) {
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+
+	ch := make(chan Event, eventBufferSize)
+
+	if eb.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := eb.nextId
+	eb.nextId++
+
+	eb.subs[id] = ch
+
+	return ch, func() {
+		eb.unsubscribe(id)
+	}
+}
+
+func (eb *eventBus) unsubscribe(id uint64) {
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+
+	if ch, ok := eb.subs[id]; ok {
+		close(ch)
+		delete(eb.subs, id)
+	}
+}
+
+// Never blocks, events are dropped for subscribers with a full buffer.
+func (eb *eventBus) publish(e Event) {
+	eb.mutex.RLock()
+	defer eb.mutex.RUnlock()
+
+	for _, ch := range eb.subs {
+		select {
+		case ch <- e:
+		default:
+			log.Debug("Subscriber buffer full, dropping event", "type", e.Type)
+		}
+	}
+}
+
+func (eb *eventBus) close() {
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+
+	eb.closed = true
+
+	for id, ch := range eb.subs {
+		close(ch)
+		delete(eb.subs, id)
+	}
+}
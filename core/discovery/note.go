@@ -12,6 +12,10 @@ type Note struct {
 	epoch uint64
 	mask  uint32
 	id    string
+
+	// Set on the final note of a peer leaving the network.
+	leaving bool
+
 	*signature
 }
 
@@ -27,6 +31,19 @@ func (n Note) IsRingDisabled(ringNum, numRings uint32) bool {
 	return !hasBit(n.mask, uint32(idx))
 }
 
+func (n *Note) Epoch() uint64 {
+	return n.epoch
+}
+
+func (n *Note) Mask() uint32 {
+	return n.mask
+}
+
+// Returns true if the note announces that its peer is leaving the network.
+func (n *Note) IsLeaving() bool {
+	return n.leaving
+}
+
 func (n *Note) Equal(epoch uint64) bool {
 	return n.epoch == epoch
 }
@@ -37,9 +54,10 @@ func (n *Note) IsMoreRecent(other uint64) bool {
 
 func (n *Note) ToPbMsg() *pb.Note {
 	return &pb.Note{
-		Epoch: n.epoch,
-		Id:    []byte(n.id),
-		Mask:  n.mask,
+		Epoch:   n.epoch,
+		Id:      []byte(n.id),
+		Mask:    n.mask,
+		Leaving: n.leaving,
 		Signature: &pb.Signature{
 			R: n.r,
 			S: n.s,
@@ -69,6 +87,23 @@ func NewNote(id string, epoch uint64, mask uint32, priv *ecdsa.PrivateKey) *pb.N
 	return n.ToPbMsg()
 }
 
+// ONLY FOR TESTING
+func NewLeavingNote(id string, epoch uint64, mask uint32, priv *ecdsa.PrivateKey) *pb.Note {
+	n := &Note{
+		id:      id,
+		epoch:   epoch,
+		mask:    mask,
+		leaving: true,
+	}
+
+	err := signNote(n, priv)
+	if err != nil {
+		panic(err)
+	}
+
+	return n.ToPbMsg()
+}
+
 // ONLY FOR TESTING
 func NewUnsignedNote(id string, epoch uint64, mask uint32) *pb.Note {
 	n := &Note{
@@ -88,9 +123,10 @@ func signNote(n *Note, privKey *ecdsa.PrivateKey) error {
 	}
 
 	noteMsg := &pb.Note{
-		Epoch: n.epoch,
-		Id:    []byte(n.id),
-		Mask:  n.mask,
+		Epoch:   n.epoch,
+		Id:      []byte(n.id),
+		Mask:    n.mask,
+		Leaving: n.leaving,
 	}
 
 	b, err := proto.Marshal(noteMsg)
@@ -121,6 +121,10 @@ func (p *Peer) Certificate() []byte {
 	return p.cert.Raw
 }
 
+func (p *Peer) X509() *x509.Certificate {
+	return p.cert
+}
+
 func (p *Peer) PublicKey() *ecdsa.PublicKey {
 	return p.publicKey
 }
@@ -303,15 +307,16 @@ func (p *Peer) IsAccused() bool {
 	return false
 }
 
-func (p *Peer) AddNote(mask uint32, epoch uint64, r, s []byte) {
+func (p *Peer) AddNote(mask uint32, epoch uint64, leaving bool, r, s []byte) {
 	p.noteMutex.Lock()
 	defer p.noteMutex.Unlock()
 
 	if p.note == nil || p.note.IsMoreRecent(epoch) {
 		p.note = &Note{
-			id:    p.Id,
-			mask:  mask,
-			epoch: epoch,
+			id:      p.Id,
+			mask:    mask,
+			epoch:   epoch,
+			leaving: leaving,
 			signature: &signature{
 				r: r,
 				s: s,
@@ -327,6 +332,15 @@ func (p *Peer) Note() *Note {
 	return p.note
 }
 
+// Returns the epoch of the current note, zero if the peer has no note.
+func (p *Peer) noteEpoch() uint64 {
+	if note := p.Note(); note != nil {
+		return note.epoch
+	}
+
+	return 0
+}
+
 func (p *Peer) Info() (*pb.Certificate, *pb.Note, []*pb.Accusation) {
 	var c *pb.Certificate
 	var n *pb.Note
@@ -707,7 +707,7 @@ func (suite *PeerTestSuite) TestAddNote() {
 
 	for i, t := range tests {
 		old := t.p.Note()
-		t.p.AddNote(t.mask, t.epoch, t.r, t.s)
+		t.p.AddNote(t.mask, t.epoch, false, t.r, t.s)
 		new := t.p.Note()
 
 		if t.replace {
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"sort"
 
 	log "github.com/inconshreveable/log15"
 )
@@ -204,6 +205,16 @@ func (rs *rings) myRingPredecessor(ringNum uint32) *Peer {
 
 }
 
+// Returns the num first peers succeeding the position of key on the given ring.
+func (rs *rings) lookup(ringNum uint32, key []byte, num int) ([]*Peer, error) {
+	r, ok := rs.ringMap[ringNum]
+	if !ok {
+		return nil, errInvalidRingNum
+	}
+
+	return r.successors(key, num), nil
+}
+
 func (rs *rings) shouldBeMyNeighbour(id string) bool {
 	for _, r := range rs.ringMap {
 		if isNeighbour := r.betweenNeighbours(id); isNeighbour {
@@ -425,6 +436,28 @@ func (r *ring) neighbours(id string) (*ringId, *ringId) {
 	return findSuccAndPrev(r.succList, rId, r.length)
 }
 
+// Returns the num first peers whose position is equal to or succeeds the hash of key,
+// fewer if the ring holds less than num peers.
+func (r *ring) successors(key []byte, num int) []*Peer {
+	hash := hashId(r.ringNum, key)
+
+	idx := sort.Search(r.length, func(i int) bool {
+		return bytes.Compare(r.succList[i].hash, hash) >= 0
+	})
+
+	if num > r.length {
+		num = r.length
+	}
+
+	ret := make([]*Peer, 0, num)
+
+	for i := 0; i < num; i++ {
+		ret = append(ret, r.succList[(idx+i)%r.length].p)
+	}
+
+	return ret
+}
+
 func isBetween(start, end, new *ringId) bool {
 	startEndCmp := start.compare(end)
 	startNewCmp := start.compare(new)
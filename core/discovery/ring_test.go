@@ -87,6 +87,15 @@ func (suite *RingsTestSuite) TestCompare() {
 	assert.Equal(suite.T(), id.compare(id), 0, "equal id should return 0.")
 }
 
+func (suite *RingsTestSuite) TestLookup() {
+	_, err := suite.lookup(suite.numRings+1, []byte("testKey"), 1)
+	assert.Equal(suite.T(), errInvalidRingNum, err, "Should return error with invalid ring number.")
+
+	owners, err := suite.lookup(suite.numRings, []byte("testKey"), 1)
+	require.NoError(suite.T(), err, "Returned error on valid lookup.")
+	assert.Equal(suite.T(), []*Peer{suite.self}, owners, "Should only return myself when alone.")
+}
+
 func (suite *RingsTestSuite) TestEqual() {
 	id := &ringId{
 		hash: []byte("testId"),
@@ -645,6 +654,48 @@ func (suite *RingTestSuite) TestSuccessor() {
 
 }
 
+func (suite *RingTestSuite) TestSuccessors() {
+	r := suite.ring
+
+	key := []byte("testKey")
+
+	owners := r.successors(key, 3)
+	require.Equal(suite.T(), 1, len(owners), "Should only return myself when alone.")
+	assert.Equal(suite.T(), r.selfId.p, owners[0], "Did not return myself.")
+
+	for i := 0; i < 10; i++ {
+		r.add(&Peer{
+			Id: fmt.Sprintf("testId%d", i),
+		})
+	}
+
+	owners = r.successors(key, 3)
+	require.Equal(suite.T(), 3, len(owners), "Returned wrong amount of successors.")
+
+	hash := &ringId{
+		hash: hashId(r.ringNum, key),
+	}
+
+	first := r.peerToRing[owners[0].Id]
+	_, prev := findSuccAndPrev(r.succList, first, r.length)
+	assert.True(suite.T(), isBetween(prev, first, hash), "First successor does not succeed the key.")
+
+	for i := 1; i < len(owners); i++ {
+		curr := r.peerToRing[owners[i].Id]
+		assert.Equal(suite.T(), curr, r.succAtIdx(mustSearch(suite, r, owners[i-1])), "Successors are not consecutive.")
+	}
+
+	assert.Equal(suite.T(), owners, r.successors(key, 3), "Lookup is not deterministic.")
+	assert.Equal(suite.T(), r.length, len(r.successors(key, 100)), "Should not return more peers than the ring holds.")
+}
+
+func mustSearch(suite *RingTestSuite, r *ring, p *Peer) int {
+	idx, err := search(r.succList, r.peerToRing[p.Id], r.length)
+	require.NoError(suite.T(), err, "Peer not found in ring.")
+
+	return idx
+}
+
 func (suite *RingTestSuite) TestPredecessor() {
 	r := suite.ring
 
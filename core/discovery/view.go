@@ -13,7 +13,6 @@ import (
 	log "github.com/inconshreveable/log15"
 	"github.com/joonnna/ifrit/protobuf"
 	pb "github.com/joonnna/ifrit/protobuf"
-	"github.com/spf13/viper"
 )
 
 var (
@@ -45,7 +44,7 @@ type View struct {
 	maxByz           uint32
 	deactivatedRings uint32
 
-	removalTimeout float64
+	removalTimeout time.Duration
 	updateTimeout  time.Duration
 
 	self *Peer
@@ -53,6 +52,10 @@ type View struct {
 	cm connectionManager
 	s  signer
 
+	events *eventBus
+
+	clock Clock
+
 	exitChan chan bool
 }
 
@@ -64,7 +67,9 @@ type signer interface {
 	Sign([]byte) ([]byte, []byte, error)
 }
 
-func NewView(numRings uint32, cert *x509.Certificate, cm connectionManager, s signer) (*View, error) {
+// Creates the view of the local peer, the local note starts at the given epoch.
+// An epoch of zero is treated as one, resumed identities must start above the epoch they last announced.
+func NewView(numRings uint32, cert *x509.Certificate, cm connectionManager, s signer, removalTimeout, updateTimeout time.Duration, epoch uint64) (*View, error) {
 	var i, mask uint32
 
 	maxByz := (float64(numRings) / 2.0) - 1
@@ -94,18 +99,23 @@ func NewView(numRings uint32, cert *x509.Certificate, cm connectionManager, s si
 		cm:              cm,
 		exitChan:        make(chan bool, 1),
 		s:               s,
+		events:          newEventBus(),
+		clock:           RealClock(),
 
-		removalTimeout: viper.GetFloat64("dead_timeout"),
-		updateTimeout: time.Second * time.Duration(viper.
-			GetInt32("view_update_interval")),
+		removalTimeout: removalTimeout,
+		updateTimeout:  updateTimeout,
 	}
 
 	for i = 0; i < numRings; i++ {
 		mask = setBit(mask, i)
 	}
 
+	if epoch == 0 {
+		epoch = 1
+	}
+
 	localNote := &Note{
-		epoch: 1,
+		epoch: epoch,
 		mask:  mask,
 		id:    self.Id,
 	}
@@ -124,14 +134,54 @@ func (v *View) Start() {
 		case <-v.exitChan:
 			log.Info("Stopping view update")
 			return
-		case <-time.After(v.updateTimeout):
-			v.checkTimeouts()
+		case <-v.clock.After(v.updateTimeout):
+			v.CheckTimeouts()
 		}
 	}
 }
 
+// Replaces the clock of the view, must be invoked before Start.
+func (v *View) SetClock(c Clock) {
+	v.clock = c
+}
+
 func (v *View) Stop() {
 	close(v.exitChan)
+	v.events.close()
+}
+
+// Returns a channel populated with all subsequent membership events,
+// and a function cancelling the subscription.
+// Events are dropped if the subscriber does not keep up with the channel buffer.
+// The channel is closed when the subscription is cancelled or the view stops.
+func (v *View) Subscribe() (<-chan Event, func()) {
+	return v.events.subscribe()
+}
+
+// Publishes an event of the given type concerning p to all subscribers.
+func (v *View) Emit(t EventType, p *Peer, ringNum uint32, epoch uint64) {
+	v.events.publish(Event{
+		Type:    t,
+		Id:      p.Id,
+		Addr:    p.Addr,
+		RingNum: ringNum,
+		Epoch:   epoch,
+	})
+}
+
+// Publishes a LeaderChanged event for group, p is nil if the group has no leader.
+func (v *View) EmitLeader(group string, p *Peer) {
+	e := Event{
+		Type:  LeaderChanged,
+		Group: group,
+	}
+
+	if p != nil {
+		e.Id = p.Id
+		e.Addr = p.Addr
+	}
+
+	v.events.publish(e)
 }
 
 func (v *View) NumRings() uint32 {
@@ -255,6 +305,8 @@ func (v *View) AddLive(p *Peer) {
 	for _, addr := range old {
 		v.cm.CloseConn(addr)
 	}
+
+	v.Emit(PeerJoined, p, 0, p.noteEpoch())
 }
 
 func (v *View) MyRingNeighbours(ringNum uint32) (*Peer, *Peer) {
@@ -272,6 +324,17 @@ func (v *View) LivePeer(id string) *Peer {
 }
 
 func (v *View) RemoveLive(id string) {
+	v.removeLive(id, PeerRemoved)
+}
+
+// Removes a peer which announced that it is leaving from the live view,
+// along with any pending accusation timeout.
+func (v *View) RemoveLeft(id string) {
+	v.DeleteTimeout(id)
+	v.removeLive(id, PeerLeft)
+}
+
+func (v *View) removeLive(id string, t EventType) {
 	v.liveMutex.Lock()
 	defer v.liveMutex.Unlock()
 
@@ -282,6 +345,8 @@ func (v *View) RemoveLive(id string) {
 
 		v.cm.CloseConn(peer.Addr)
 
+		v.Emit(t, peer, 0, peer.noteEpoch())
+
 		log.Debug("Removed livePeer", "addr", peer.Addr)
 	} else {
 		log.Debug("Tried to remove non-existing peer from live view.")
@@ -324,7 +389,7 @@ func (v *View) StartTimer(accused *Peer, n *Note, observer *Peer) error {
 	} else {
 		newTimeout = &timeout{
 			observer:  observer,
-			timeStamp: time.Now(),
+			timeStamp: v.clock.Now(),
 			lastNote:  n,
 			accused:   accused,
 		}
@@ -366,14 +431,16 @@ func (v *View) allTimeouts() []*timeout {
 	return ret
 }
 
-func (v *View) checkTimeouts() {
+// Removes accused peers from the live view once their removal timeout expired,
+// invoked periodically by Start.
+func (v *View) CheckTimeouts() {
 	timeouts := v.allTimeouts()
 	if numTimeouts := len(timeouts); numTimeouts > 0 {
 		log.Debug("Have timeouts", "amount", numTimeouts)
 	}
 
 	for _, t := range timeouts {
-		if time.Since(t.timeStamp).Seconds() > v.removalTimeout {
+		if v.clock.Now().Sub(t.timeStamp) > v.removalTimeout {
 			log.Debug("Timeout expired, removing from live", "addr", t.accused.Addr)
 			v.RemoveLive(t.accused.Id)
 			v.DeleteTimeout(t.accused.Id)
@@ -408,12 +475,39 @@ func (v *View) ShouldRebuttal(epoch uint64, ringNum uint32) bool {
 
 		v.self.note = newNote
 
+		v.Emit(NoteReissued, v.self, ringNum, newNote.epoch)
+
 		return true
 	} else {
 		return false
 	}
 }
 
+// Re-issues the local note with the leaving flag set,
+// peers receiving it remove the local peer from their live view immediately.
+func (v *View) Leave() error {
+	v.self.noteMutex.Lock()
+	defer v.self.noteMutex.Unlock()
+
+	newNote := &Note{
+		id:      v.self.Id,
+		epoch:   v.self.note.epoch + 1,
+		mask:    v.self.note.mask,
+		leaving: true,
+	}
+
+	return v.signLocalNote(newNote)
+}
+
+// Returns the num first live peers succeeding the position of key on the given ring,
+// the local peer included.
+func (v *View) Lookup(ringNum uint32, key []byte, num int) ([]*Peer, error) {
+	v.liveMutex.RLock()
+	defer v.liveMutex.RUnlock()
+
+	return v.rings.lookup(ringNum, key, num)
+}
+
 func (v *View) ShouldBeNeighbour(id string) bool {
 	v.liveMutex.RLock()
 	defer v.liveMutex.RUnlock()
@@ -534,9 +628,10 @@ func (v *View) deactivateRing(ringNumber uint32) (uint32, error) {
 
 func (v *View) signLocalNote(n *Note) error {
 	pbNote := &pb.Note{
-		Epoch: n.epoch,
-		Mask:  n.mask,
-		Id:    []byte(n.id),
+		Epoch:   n.epoch,
+		Mask:    n.mask,
+		Id:      []byte(n.id),
+		Leaving: n.leaving,
 	}
 
 	bytes, err := gpb.Marshal(pbNote)
@@ -39,7 +39,7 @@ func (suite *ViewTestSuite) SetupTest() {
 
 	cert := validCert("selfId", privKey.Public())
 
-	v, err := NewView(10, cert, &cmStub{}, &signerStub{})
+	v, err := NewView(10, cert, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 1)
 	require.NoError(suite.T(), err, "Failed to create view.")
 
 	suite.v = v
@@ -55,24 +55,32 @@ func (suite *ViewTestSuite) TestNewView() {
 
 	expectedByz := uint32((float64(numRings) / 2.0) - 1)
 
-	v, err := NewView(numRings, cert, &cmStub{}, &signerStub{})
+	v, err := NewView(numRings, cert, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 1)
 	require.NoError(suite.T(), err, "Failed to create view.")
 	require.Equal(suite.T(), expectedByz, v.maxByz, "Max byzantine not set correctly.")
 
-	v2, err := NewView(2, cert, &cmStub{}, &signerStub{})
+	v2, err := NewView(2, cert, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 1)
 	require.NoError(suite.T(), err, "Failed to create view.")
 	require.Equal(suite.T(), uint32(0), v2.maxByz, "Max byzantine should be zero with 2 rings.")
 
-	v3, err := NewView(1, cert, &cmStub{}, &signerStub{})
+	v3, err := NewView(1, cert, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 1)
 	require.NoError(suite.T(), err, "Failed to create view.")
 	require.Equal(suite.T(), uint32(0), v3.maxByz, "Max byzantine should be zero with 1 ring.")
 
-	_, err = NewView(0, cert, &cmStub{}, &signerStub{})
+	_, err = NewView(0, cert, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 1)
 	require.Error(suite.T(), err, "Should return error with 0 rings.")
 
-	_, err = NewView(numRings, nil, &cmStub{}, &signerStub{})
+	_, err = NewView(numRings, nil, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 1)
 	require.Error(suite.T(), err, "Should return error with no certificate.")
 
+	v4, err := NewView(numRings, cert, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 5)
+	require.NoError(suite.T(), err, "Failed to create view.")
+	require.Equal(suite.T(), uint64(5), v4.Self().Note().Epoch(), "Local note should start at given epoch.")
+
+	v5, err := NewView(numRings, cert, &cmStub{}, &signerStub{}, time.Minute, time.Second*10, 0)
+	require.NoError(suite.T(), err, "Failed to create view.")
+	require.Equal(suite.T(), uint64(1), v5.Self().Note().Epoch(), "Zero epoch should start at one.")
+
 }
 
 func (suite *ViewTestSuite) TestNumRings() {
@@ -310,6 +318,57 @@ func (suite *ViewTestSuite) TestAddLive() {
 	assert.True(suite.T(), ok, "Adding peer twice does not alter state.")
 }
 
+func (suite *ViewTestSuite) TestSubscribe() {
+	view := suite.v
+
+	ch, cancel := view.Subscribe()
+
+	p := &Peer{
+		Id:   "testId",
+		Addr: "testAddr",
+	}
+
+	view.AddLive(p)
+
+	e := <-ch
+	require.Equal(suite.T(), PeerJoined, e.Type, "Adding live peer should emit join event.")
+	require.Equal(suite.T(), p.Id, e.Id, "Event contains wrong id.")
+	require.Equal(suite.T(), p.Addr, e.Addr, "Event contains wrong address.")
+
+	view.RemoveLive(p.Id)
+
+	e = <-ch
+	require.Equal(suite.T(), PeerRemoved, e.Type, "Removing live peer should emit remove event.")
+
+	view.RemoveLive(p.Id)
+	require.Zero(suite.T(), len(ch), "Removing non-existing peer should not emit events.")
+
+	epoch := view.self.note.epoch
+	require.True(suite.T(), view.ShouldRebuttal(epoch, 1), "Should rebuttal with current epoch.")
+
+	e = <-ch
+	require.Equal(suite.T(), NoteReissued, e.Type, "Rebuttal should emit note event.")
+	require.Equal(suite.T(), epoch+1, e.Epoch, "Event should contain new epoch.")
+	require.Equal(suite.T(), uint32(1), e.RingNum, "Event should contain accused ring.")
+
+	cancel()
+
+	_, open := <-ch
+	require.False(suite.T(), open, "Channel should be closed after cancelling.")
+
+	view.AddLive(p)
+
+	ch2, _ := view.Subscribe()
+	view.Stop()
+
+	_, open = <-ch2
+	require.False(suite.T(), open, "Channel should be closed after stopping view.")
+
+	ch3, _ := view.Subscribe()
+	_, open = <-ch3
+	require.False(suite.T(), open, "Subscribing to a stopped view should return closed channel.")
+}
+
 func (suite *ViewTestSuite) TestRingNeighbours() {
 	var i uint32
 
@@ -506,7 +565,7 @@ func (suite *ViewTestSuite) TestAllTimeouts() {
 func (suite *ViewTestSuite) TestCheckTimeouts() {
 	view := suite.v
 
-	view.checkTimeouts()
+	view.CheckTimeouts()
 	assert.Zero(suite.T(), len(view.timeoutMap), "Does not alter state when there are no timeouts.")
 
 	accused := &Peer{
@@ -521,8 +580,7 @@ func (suite *ViewTestSuite) TestCheckTimeouts() {
 		id: accused.Id,
 	}
 
-	// 100.0 seconds
-	view.removalTimeout = 100.0
+	view.removalTimeout = time.Second * 100
 
 	t := &timeout{
 		accused:   accused,
@@ -533,7 +591,7 @@ func (suite *ViewTestSuite) TestCheckTimeouts() {
 
 	view.timeoutMap[accused.Id] = t
 
-	view.checkTimeouts()
+	view.CheckTimeouts()
 	require.Equal(suite.T(), 1, len(view.timeoutMap), "Timeout removed before expiration.")
 
 	_, ok := view.timeoutMap[accused.Id]
@@ -542,7 +600,7 @@ func (suite *ViewTestSuite) TestCheckTimeouts() {
 	// 10 years in the past
 	t.timeStamp = t.timeStamp.AddDate(-10, 0, 0)
 
-	view.checkTimeouts()
+	view.CheckTimeouts()
 	require.Zero(suite.T(), len(view.timeoutMap), "Timeout not removed after expiration.")
 
 	_, ok = view.timeoutMap[accused.Id]
@@ -570,6 +628,43 @@ func (suite *ViewTestSuite) TestShouldRebuttal() {
 	assert.True(suite.T(), view.ValidMask(view.self.note.mask), "Mask is not valid after disabling.")
 }
 
+func (suite *ViewTestSuite) TestLeave() {
+	view := suite.v
+
+	prevNote := view.self.note
+
+	require.NoError(suite.T(), view.Leave(), "Failed to issue leaving note.")
+
+	assert.True(suite.T(), view.self.note.IsLeaving(), "New note is not flagged as leaving.")
+	assert.Equal(suite.T(), prevNote.epoch+1, view.self.note.epoch, "Leaving note should increment epoch.")
+	assert.Equal(suite.T(), prevNote.mask, view.self.note.mask, "Leaving note should keep mask.")
+	assert.NotNil(suite.T(), view.self.note.signature, "Leaving note not signed.")
+}
+
+func (suite *ViewTestSuite) TestRemoveLeft() {
+	view := suite.v
+
+	ch, cancel := view.Subscribe()
+	defer cancel()
+
+	p := &Peer{
+		Id:   "testId",
+		Addr: "testAddr",
+	}
+
+	view.AddLive(p)
+	<-ch
+
+	view.timeoutMap[p.Id] = &timeout{accused: p}
+
+	view.RemoveLeft(p.Id)
+
+	e := <-ch
+	require.Equal(suite.T(), PeerLeft, e.Type, "Removing leaving peer should emit leave event.")
+	require.False(suite.T(), view.IsAlive(p.Id), "Leaving peer still in live view.")
+	require.False(suite.T(), view.HasTimer(p.Id), "Timeout of leaving peer not removed.")
+}
+
 func (suite *ViewTestSuite) TestShouldBeNeighbour() {
 	view := suite.v
 
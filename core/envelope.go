@@ -0,0 +1,165 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/joonnna/ifrit/protobuf"
+)
+
+var (
+	errNoEnvelopeSignature = errors.New("Envelope has no signature")
+	errNoEnvelopeCert      = errors.New("Envelope contains no certificate")
+	errNoEnvelopeKey       = errors.New("Envelope certificate has no ecdsa public key")
+	errEnvelopeValidity    = errors.New("Envelope was signed outside the validity period of its certificate")
+	errNoEnvelopeCa        = errors.New("No CA certificate given to verify the envelope")
+	errNoEnvelopeSigner    = errors.New("No signer certificate given to verify the envelope")
+	errEnvelopeSigner      = errors.New("Envelope was not signed by the expected certificate")
+)
+
+// Envelope is a payload signed by a member, see SignEnvelope and VerifyEnvelope.
+type Envelope struct {
+	// Ifrit id of the signer.
+	Signer string
+
+	// Certificate of the signer, issued by the CA.
+	Certificate *x509.Certificate
+
+	Payload []byte
+
+	// Time of signing as stated by the signer.
+	Timestamp time.Time
+}
+
+// Signs the given payload together with the certificate of this node and the current time.
+// The returned bytes are a marshaled envelope, verifiable by anyone holding the CA certificate.
+func (n *Node) SignEnvelope(payload []byte) ([]byte, error) {
+	e := &pb.Envelope{
+		Payload:     payload,
+		Certificate: n.cm.Certificate().Raw,
+		Timestamp:   time.Now().UnixNano(),
+	}
+
+	bytes, err := proto.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := n.cs.Sign(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Signature = &pb.Signature{
+		R: r,
+		S: s,
+	}
+
+	return proto.Marshal(e)
+}
+
+// Verifies an envelope created by SignEnvelope without consulting the network,
+// the signer does not have to be a member anymore.
+// The certificate has to be issued by caCert and valid at the time of signing.
+// Networks without a CA use VerifySelfSignedEnvelope instead.
+func VerifyEnvelope(raw []byte, caCert *x509.Certificate) (*Envelope, error) {
+	if caCert == nil {
+		return nil, errNoEnvelopeCa
+	}
+
+	return verifyEnvelope(raw, func(cert *x509.Certificate) error {
+		return cert.CheckSignatureFrom(caCert)
+	})
+}
+
+// Verifies an envelope created by SignEnvelope in a network without a CA.
+// Anyone can create a self-signed certificate, the envelope is therefore only accepted
+// if it carries the given certificate of the expected signer, e.g. Member.Certificate.
+func VerifySelfSignedEnvelope(raw []byte, signer *x509.Certificate) (*Envelope, error) {
+	if signer == nil {
+		return nil, errNoEnvelopeSigner
+	}
+
+	return verifyEnvelope(raw, func(cert *x509.Certificate) error {
+		if !cert.Equal(signer) {
+			return errEnvelopeSigner
+		}
+
+		return cert.CheckSignatureFrom(cert)
+	})
+}
+
+// Verifies the envelope signature and timestamp, the certificate of the envelope is checked by checkCert.
+func verifyEnvelope(raw []byte, checkCert func(*x509.Certificate) error) (*Envelope, error) {
+	e := &pb.Envelope{}
+
+	if err := proto.Unmarshal(raw, e); err != nil {
+		return nil, err
+	}
+
+	sign := e.GetSignature()
+	if sign == nil {
+		return nil, errNoEnvelopeSignature
+	}
+
+	if len(e.GetCertificate()) == 0 {
+		return nil, errNoEnvelopeCert
+	}
+
+	cert, err := x509.ParseCertificate(e.GetCertificate())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cert.SubjectKeyId) != sha256.Size {
+		return nil, errInvalidId
+	}
+
+	if err := checkCert(cert); err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Unix(0, e.GetTimestamp())
+
+	if timestamp.Before(cert.NotBefore) || timestamp.After(cert.NotAfter) {
+		return nil, errEnvelopeValidity
+	}
+
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errNoEnvelopeKey
+	}
+
+	// Verify a copy without the signature.
+	unsigned := &pb.Envelope{
+		Payload:     e.GetPayload(),
+		Certificate: e.GetCertificate(),
+		Timestamp:   e.GetTimestamp(),
+	}
+
+	bytes, err := proto.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	var rInt, sInt big.Int
+
+	rInt.SetBytes(sign.GetR())
+	sInt.SetBytes(sign.GetS())
+
+	if valid := ecdsa.Verify(pub, hashContent(bytes), &rInt, &sInt); !valid {
+		return nil, errInvalidSignature
+	}
+
+	return &Envelope{
+		Signer:      string(cert.SubjectKeyId),
+		Certificate: cert,
+		Payload:     e.GetPayload(),
+		Timestamp:   timestamp,
+	}, nil
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *HandlerTestSuite) TestSelfSignedEnvelope() {
+	node := suite.n
+
+	raw, err := node.SignEnvelope([]byte("audit"))
+	require.NoError(suite.T(), err, "Failed to sign envelope.")
+
+	cert := node.cm.Certificate()
+
+	e, err := VerifySelfSignedEnvelope(raw, cert)
+	require.NoError(suite.T(), err, "Valid envelope was rejected.")
+	require.Equal(suite.T(), node.self.Id, e.Signer, "Invalid signer.")
+	require.Equal(suite.T(), []byte("audit"), e.Payload, "Invalid payload.")
+	require.WithinDuration(suite.T(), time.Now(), e.Timestamp, time.Second, "Invalid timestamp.")
+
+	msg := &pb.Envelope{}
+	require.NoError(suite.T(), proto.Unmarshal(raw, msg), "Failed to unmarshal envelope.")
+
+	msg.Payload = []byte("tampered")
+	tampered, err := proto.Marshal(msg)
+	require.NoError(suite.T(), err, "Failed to marshal envelope.")
+
+	_, err = VerifySelfSignedEnvelope(tampered, cert)
+	require.Equal(suite.T(), errInvalidSignature, err, "Tampered envelope should be rejected.")
+
+	msg.Signature = nil
+	unsigned, err := proto.Marshal(msg)
+	require.NoError(suite.T(), err, "Failed to marshal envelope.")
+
+	_, err = VerifySelfSignedEnvelope(unsigned, cert)
+	require.Equal(suite.T(), errNoEnvelopeSignature, err, "Unsigned envelope should be rejected.")
+
+	_, err = VerifySelfSignedEnvelope([]byte("garbage"), cert)
+	require.Error(suite.T(), err, "Invalid envelope should be rejected.")
+
+	_, err = VerifyEnvelope(raw, nil)
+	require.Equal(suite.T(), errNoEnvelopeCa, err, "Verification without CA certificate should be rejected.")
+
+	_, err = VerifySelfSignedEnvelope(raw, nil)
+	require.Equal(suite.T(), errNoEnvelopeSigner, err, "Verification without signer certificate should be rejected.")
+
+	// Anyone can sign a self-signed certificate, only the expected one is accepted.
+	otherPriv, err := genKeys()
+	require.NoError(suite.T(), err, "Failed to generate keys.")
+
+	_, err = VerifySelfSignedEnvelope(raw, genCert(otherPriv, 10))
+	require.Equal(suite.T(), errEnvelopeSigner, err, "Envelope of another signer should be rejected.")
+}
+
+func (suite *HandlerTestSuite) TestCaSignedEnvelope() {
+	node := suite.n
+
+	caPriv, err := genKeys()
+	require.NoError(suite.T(), err, "Failed to generate keys.")
+
+	caCert := genCert(caPriv, 32)
+
+	otherPriv, err := genKeys()
+	require.NoError(suite.T(), err, "Failed to generate keys.")
+
+	otherCa := genCert(otherPriv, 32)
+
+	node.cm = &cmStub{cert: issueCert(caCert, caPriv, suite.priv, time.Now().Add(time.Hour))}
+
+	raw, err := node.SignEnvelope([]byte("audit"))
+	require.NoError(suite.T(), err, "Failed to sign envelope.")
+
+	// The signer is not required to be part of any view.
+	e, err := VerifyEnvelope(raw, caCert)
+	require.NoError(suite.T(), err, "Valid envelope was rejected.")
+	require.Equal(suite.T(), node.cm.Certificate().Raw, e.Certificate.Raw, "Invalid certificate.")
+
+	_, err = VerifyEnvelope(raw, otherCa)
+	require.Error(suite.T(), err, "Envelope of another CA should be rejected.")
+
+	_, err = VerifyEnvelope(raw, nil)
+	require.Equal(suite.T(), errNoEnvelopeCa, err, "Verification without CA certificate should be rejected.")
+
+	_, err = VerifySelfSignedEnvelope(raw, node.cm.Certificate())
+	require.Error(suite.T(), err, "CA issued certificate is not self-signed.")
+
+	node.cm = &cmStub{cert: issueCert(caCert, caPriv, suite.priv, time.Now().Add(-time.Hour))}
+
+	raw, err = node.SignEnvelope([]byte("audit"))
+	require.NoError(suite.T(), err, "Failed to sign envelope.")
+
+	_, err = VerifyEnvelope(raw, caCert)
+	require.Equal(suite.T(), errEnvelopeValidity, err, "Envelope signed with expired certificate should be rejected.")
+}
+
+func issueCert(caCert *x509.Certificate, caPriv, priv *ecdsa.PrivateKey, notAfter time.Time) *x509.Certificate {
+	serial, err := genSerialNumber()
+	if err != nil {
+		panic(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		SubjectKeyId: genId(),
+		Subject:      pkix.Name{Locality: []string{"127.0.0.1:8000", "pingAddr", "httpAddr"}},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, caCert, priv.Public(), caPriv)
+	if err != nil {
+		panic(err)
+	}
+
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	return cert
+}
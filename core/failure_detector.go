@@ -3,6 +3,7 @@ package core
 import (
 	"crypto/rand"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -10,6 +11,8 @@ import (
 	pb "github.com/joonnna/ifrit/protobuf"
 )
 
+const defaultPingTimeout = time.Second * 5
+
 var (
 	errDead                 = errors.New("Peer is dead")
 	errInvalidPongSignature = errors.New("Invalid signature on pong message")
@@ -19,6 +22,16 @@ type failureDetector struct {
 	ps             pingService
 	cs             cryptoService
 	maxFailedPings uint32
+
+	// Suspicion level at which peers are considered dead,
+	// zero considers peers dead after maxFailedPings consecutive failed pings.
+	phiThreshold float64
+
+	// Pong arrivals indexed by peer id.
+	history      map[string]*arrivals
+	historyMutex sync.Mutex
+
+	clock discovery.Clock
 }
 
 type pingService interface {
@@ -28,11 +41,14 @@ type pingService interface {
 	Stop()
 }
 
-func newFd(ps pingService, cs cryptoService, maxPing uint32) *failureDetector {
+func newFd(ps pingService, cs cryptoService, maxPing uint32, phiThreshold float64, clock discovery.Clock) *failureDetector {
 	return &failureDetector{
 		ps:             ps,
 		cs:             cs,
 		maxFailedPings: maxPing,
+		phiThreshold:   phiThreshold,
+		history:        make(map[string]*arrivals),
+		clock:          clock,
 	}
 }
 
@@ -48,28 +64,93 @@ func (fd *failureDetector) probe(dest *discovery.Peer) error {
 	pong, err := fd.ps.Ping(dest.PingAddr, msg)
 	if err != nil {
 		dest.IncrementPing()
-		if dest.NumPing() >= fd.maxFailedPings {
+		if fd.isDead(dest) {
 			return errDead
 		}
 
 		return err
 	}
 
-	pong.Signature = nil
-	bytes, err := proto.Marshal(pong)
-	if err != nil {
+	if err := fd.verifyPong(dest, msg, pong); err != nil {
 		return err
 	}
 
-	if sign := pong.GetSignature(); sign == nil {
-		return errInvalidPongSignature
-	} else {
-		if valid := fd.cs.Verify(bytes, sign.GetR(), sign.GetS(), dest.PublicKey()); !valid {
-			return errInvalidPongSignature
+	dest.ResetPing()
+	fd.arrived(dest)
+
+	return nil
+}
+
+// Decides whether a peer that just failed a ping is dead, through its suspicion level
+// if enabled and enough pongs have arrived, otherwise through its number of failed pings.
+func (fd *failureDetector) isDead(p *discovery.Peer) bool {
+	if fd.phiThreshold > 0 {
+		fd.historyMutex.Lock()
+		defer fd.historyMutex.Unlock()
+
+		if a, ok := fd.history[p.Id]; ok && a.ready() {
+			now := fd.clock.Now()
+
+			if p.NumPing() == 1 {
+				a.failed(now)
+			}
+
+			return a.phi(now) >= fd.phiThreshold
 		}
 	}
 
-	dest.ResetPing()
+	return p.NumPing() >= fd.maxFailedPings
+}
+
+func (fd *failureDetector) arrived(p *discovery.Peer) {
+	fd.historyMutex.Lock()
+	defer fd.historyMutex.Unlock()
+
+	a, ok := fd.history[p.Id]
+	if !ok {
+		a = newArrivals()
+		fd.history[p.Id] = a
+	}
+
+	a.add(fd.clock.Now())
+}
+
+// Returns the current suspicion level of the peer, false if too few pongs have arrived.
+func (fd *failureDetector) suspicion(id string) (float64, bool) {
+	fd.historyMutex.Lock()
+	defer fd.historyMutex.Unlock()
+
+	a, ok := fd.history[id]
+	if !ok || !a.ready() {
+		return 0, false
+	}
+
+	return a.phi(fd.clock.Now()), true
+}
+
+// Drops the pong arrivals of the peer, invoked once it is removed from the full view.
+func (fd *failureDetector) forget(id string) {
+	fd.historyMutex.Lock()
+	defer fd.historyMutex.Unlock()
+
+	delete(fd.history, id)
+}
+
+// Checks that the pong is signed by dest, the signature covers the ping it replies to.
+func (fd *failureDetector) verifyPong(dest *discovery.Peer, ping *pb.Ping, pong *pb.Pong) error {
+	sign := pong.GetSignature()
+	if sign == nil {
+		return errInvalidPongSignature
+	}
+
+	bytes, err := proto.Marshal(ping)
+	if err != nil {
+		return err
+	}
+
+	if valid := fd.cs.Verify(bytes, sign.GetR(), sign.GetS(), dest.PublicKey()); !valid {
+		return errInvalidPongSignature
+	}
 
 	return nil
 }
@@ -1 +1,72 @@
 package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+var errPingStub = errors.New("Ping stub error")
+
+// Replies to pings with pongs signed by priv, or fails with err if set.
+type pongStub struct {
+	priv *ecdsa.PrivateKey
+	err  error
+}
+
+func (ps *pongStub) Pause(d time.Duration) {
+}
+
+func (ps *pongStub) Start() {
+}
+
+func (ps *pongStub) Stop() {
+}
+
+func (ps *pongStub) Ping(addr string, p *pb.Ping) (*pb.Pong, error) {
+	if ps.err != nil {
+		return nil, ps.err
+	}
+
+	b, err := proto.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := (&cryptoStub{priv: ps.priv}).Sign(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Pong{
+		Signature: &pb.Signature{
+			R: r,
+			S: s,
+		},
+	}, nil
+}
+
+func (suite *HandlerTestSuite) TestProbe() {
+	node := suite.n
+
+	p := node.view.Live()[0]
+	ps := &pongStub{priv: suite.privMap[p.Id]}
+	fd := newFd(ps, node.cs, 2, 0, discovery.RealClock())
+
+	p.IncrementPing()
+
+	require.NoError(suite.T(), fd.probe(p), "Valid pong was rejected.")
+	require.Zero(suite.T(), p.NumPing(), "Failed pings should be reset.")
+
+	ps.priv = suite.priv
+	require.Equal(suite.T(), errInvalidPongSignature, fd.probe(p), "Pong signed by other peer should be rejected.")
+
+	ps.err = errPingStub
+	require.Equal(suite.T(), errPingStub, fd.probe(p), "First failed ping should not be fatal.")
+	require.Equal(suite.T(), errDead, fd.probe(p), "Peer should be dead after reaching the ping limit.")
+}
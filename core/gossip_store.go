@@ -0,0 +1,255 @@
+package core
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	pb "github.com/joonnna/ifrit/protobuf"
+)
+
+var (
+	errNoGossipKey      = errors.New("Gossip key is empty")
+	errOldGossipEntry   = errors.New("Already had the same or a more recent gossip entry")
+	errUnknownOrigin    = errors.New("Origin of gossip entry not found in full view")
+	errNoEntrySignature = errors.New("Gossip entry has no signature")
+)
+
+// GossipEntry is the most recent value a member has set for a gossip key.
+type GossipEntry struct {
+	// Ifrit id of the member that set the value.
+	Origin string
+
+	Key     string
+	Version uint64
+	Value   []byte
+}
+
+// Signed gossip entries of all members, indexed by origin and key.
+// Entries are stored as received so they can be relayed without re-signing.
+type gossipStore struct {
+	entries  map[string]map[string]*pb.GossipEntry
+	handlers map[string]func(*GossipEntry)
+
+	mutex sync.RWMutex
+}
+
+func newGossipStore() *gossipStore {
+	return &gossipStore{
+		entries:  make(map[string]map[string]*pb.GossipEntry),
+		handlers: make(map[string]func(*GossipEntry)),
+	}
+}
+
+// Sets the local value of key and increments its version.
+// Versions are prefixed by the epoch of the local note, a resumed identity starts at a higher epoch
+// and its entries therefore supersede those it set before the restart.
+// Only the keys that changed since the last exchange are transferred to neighbours.
+func (n *Node) SetGossipKey(key string, value []byte) error {
+	if key == "" {
+		return errNoGossipKey
+	}
+
+	n.store.mutex.Lock()
+	defer n.store.mutex.Unlock()
+
+	version := n.self.Note().Epoch()<<32 + 1
+	if prev := n.store.entries[n.self.Id][key]; prev != nil && prev.GetVersion() >= version {
+		version = prev.GetVersion() + 1
+	}
+
+	entry := &pb.GossipEntry{
+		Origin:  []byte(n.self.Id),
+		Key:     key,
+		Version: version,
+		Value:   value,
+	}
+
+	bytes, err := proto.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r, s, err := n.cs.Sign(bytes)
+	if err != nil {
+		return err
+	}
+
+	entry.Signature = &pb.Signature{
+		R: r,
+		S: s,
+	}
+
+	n.store.add(n.self.Id, entry)
+
+	return nil
+}
+
+// Returns the most recent entry of each member that has set the given key, including the local one.
+func (n *Node) GossipEntries(key string) []*GossipEntry {
+	var ret []*GossipEntry
+
+	n.store.mutex.RLock()
+	defer n.store.mutex.RUnlock()
+
+	for origin, keys := range n.store.entries {
+		if e, ok := keys[key]; ok {
+			ret = append(ret, newGossipEntry(origin, e))
+		}
+	}
+
+	return ret
+}
+
+// Registers the handler invoked each time a member changes the value of key,
+// a nil handler removes it. Local changes do not invoke the handler.
+func (n *Node) SetGossipKeyHandler(key string, handler func(*GossipEntry)) {
+	n.store.mutex.Lock()
+	defer n.store.mutex.Unlock()
+
+	if handler == nil {
+		delete(n.store.handlers, key)
+	} else {
+		n.store.handlers[key] = handler
+	}
+}
+
+// Must hold the store lock.
+func (gs *gossipStore) add(origin string, e *pb.GossipEntry) {
+	keys, ok := gs.entries[origin]
+	if !ok {
+		keys = make(map[string]*pb.GossipEntry)
+		gs.entries[origin] = keys
+	}
+
+	keys[e.GetKey()] = e
+}
+
+// Returns the versions of all entries held locally.
+func (n *Node) gossipDigest() []*pb.GossipDigest {
+	var ret []*pb.GossipDigest
+
+	n.store.mutex.RLock()
+	defer n.store.mutex.RUnlock()
+
+	for origin, keys := range n.store.entries {
+		for key, e := range keys {
+			ret = append(ret, &pb.GossipDigest{
+				Origin:  []byte(origin),
+				Key:     key,
+				Version: e.GetVersion(),
+			})
+		}
+	}
+
+	return ret
+}
+
+// Returns all entries which are missing or older in the given digest.
+func (n *Node) newerGossipEntries(digest []*pb.GossipDigest) []*pb.GossipEntry {
+	var ret []*pb.GossipEntry
+
+	given := make(map[string]map[string]uint64)
+
+	for _, d := range digest {
+		origin := string(d.GetOrigin())
+		if _, ok := given[origin]; !ok {
+			given[origin] = make(map[string]uint64)
+		}
+		given[origin][d.GetKey()] = d.GetVersion()
+	}
+
+	n.store.mutex.RLock()
+	defer n.store.mutex.RUnlock()
+
+	for origin, keys := range n.store.entries {
+		for key, e := range keys {
+			if version, ok := given[origin][key]; !ok || e.GetVersion() > version {
+				ret = append(ret, e)
+			}
+		}
+	}
+
+	return ret
+}
+
+func (n *Node) mergeGossipEntries(entries []*pb.GossipEntry) {
+	for _, e := range entries {
+		if err := n.evalGossipEntry(e); err != nil {
+			log.Debug(err.Error(), "key", e.GetKey())
+		}
+	}
+}
+
+func (n *Node) evalGossipEntry(e *pb.GossipEntry) error {
+	origin := string(e.GetOrigin())
+
+	sign := e.GetSignature()
+	if sign == nil {
+		return errNoEntrySignature
+	}
+
+	// Entries set before a restart are adopted, subsequent local changes then continue from their version.
+	p := n.self
+	if origin != n.self.Id {
+		p = n.view.Peer(origin)
+	}
+	if p == nil {
+		return errUnknownOrigin
+	}
+
+	n.store.mutex.RLock()
+	prev := n.store.entries[origin][e.GetKey()]
+	n.store.mutex.RUnlock()
+
+	if prev != nil && prev.GetVersion() >= e.GetVersion() {
+		return errOldGossipEntry
+	}
+
+	// The received entry is stored and relayed as is, verify a copy without the signature.
+	unsigned := &pb.GossipEntry{
+		Origin:  e.GetOrigin(),
+		Key:     e.GetKey(),
+		Version: e.GetVersion(),
+		Value:   e.GetValue(),
+	}
+
+	bytes, err := proto.Marshal(unsigned)
+	if err != nil {
+		return err
+	}
+
+	if valid := n.cs.Verify(bytes, sign.GetR(), sign.GetS(), p.PublicKey()); !valid {
+		return errInvalidSignature
+	}
+
+	n.store.mutex.Lock()
+	// Another gossip exchange might have stored a more recent entry in the meantime.
+	if prev := n.store.entries[origin][e.GetKey()]; prev != nil && prev.GetVersion() >= e.GetVersion() {
+		n.store.mutex.Unlock()
+		return errOldGossipEntry
+	}
+	n.store.add(origin, e)
+	handler := n.store.handlers[e.GetKey()]
+	n.store.mutex.Unlock()
+
+	if origin == n.self.Id {
+		return nil
+	}
+
+	if handler != nil {
+		handler(newGossipEntry(origin, e))
+	}
+
+	return nil
+}
+
+func newGossipEntry(origin string, e *pb.GossipEntry) *GossipEntry {
+	return &GossipEntry{
+		Origin:  origin,
+		Key:     e.GetKey(),
+		Version: e.GetVersion(),
+		Value:   e.GetValue(),
+	}
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *HandlerTestSuite) TestGossipStore() {
+	var updates []*GossipEntry
+
+	node := suite.n
+	p := node.view.Full()[0]
+
+	require.Equal(suite.T(), errNoGossipKey, node.SetGossipKey("", []byte("value")),
+		"Empty key should be rejected.")
+
+	require.NoError(suite.T(), node.SetGossipKey("load", []byte("1")), "Failed to set key.")
+	require.NoError(suite.T(), node.SetGossipKey("load", []byte("2")), "Failed to set key.")
+
+	entries := node.GossipEntries("load")
+	require.Len(suite.T(), entries, 1, "Should only hold the local entry.")
+	require.Equal(suite.T(), node.self.Note().Epoch()<<32+2, entries[0].Version, "Version was not incremented.")
+	require.Equal(suite.T(), []byte("2"), entries[0].Value, "Invalid value.")
+
+	node.SetGossipKeyHandler("load", func(e *GossipEntry) {
+		updates = append(updates, e)
+	})
+	defer node.SetGossipKeyHandler("load", nil)
+
+	remote := suite.signedEntry(p.Id, "load", 3, []byte("remote"))
+	require.NoError(suite.T(), node.evalGossipEntry(remote), "Valid entry was rejected.")
+	require.Len(suite.T(), updates, 1, "Handler was not invoked.")
+	require.Equal(suite.T(), p.Id, updates[0].Origin, "Invalid origin.")
+	require.Equal(suite.T(), []byte("remote"), updates[0].Value, "Invalid value.")
+
+	require.Equal(suite.T(), errOldGossipEntry, node.evalGossipEntry(suite.signedEntry(p.Id, "load", 3, []byte("old"))),
+		"Entry with same version should be discarded.")
+
+	tampered := suite.signedEntry(p.Id, "load", 4, []byte("remote"))
+	tampered.Value = []byte("tampered")
+	require.Equal(suite.T(), errInvalidSignature, node.evalGossipEntry(tampered),
+		"Tampered entry should be rejected.")
+	require.Len(suite.T(), updates, 1, "Handler invoked for rejected entries.")
+
+	require.Len(suite.T(), node.GossipEntries("load"), 2, "Should hold local and remote entry.")
+
+	// Digest covering everything, only entries with more recent versions should be returned.
+	digest := node.gossipDigest()
+	require.Len(suite.T(), digest, 2, "Invalid digest length.")
+	require.Empty(suite.T(), node.newerGossipEntries(digest), "Nothing should be newer than own digest.")
+
+	for _, d := range digest {
+		if string(d.GetOrigin()) == p.Id {
+			d.Version = 1
+		}
+	}
+
+	newer := node.newerGossipEntries(digest)
+	require.Len(suite.T(), newer, 1, "Only the outdated entry should be returned.")
+	require.Equal(suite.T(), p.Id, string(newer[0].GetOrigin()), "Invalid entry returned.")
+
+	require.Len(suite.T(), node.newerGossipEntries(nil), 2, "Empty digest should return all entries.")
+}
+
+func (suite *HandlerTestSuite) TestGossipStoreRestart() {
+	var updates []*GossipEntry
+
+	node := suite.n
+	epoch := node.self.Note().Epoch()
+
+	suite.privMap[node.self.Id] = suite.priv
+
+	node.SetGossipKeyHandler("load", func(e *GossipEntry) {
+		updates = append(updates, e)
+	})
+	defer node.SetGossipKeyHandler("load", nil)
+
+	// Entry set before the restart, still held by other members.
+	before := suite.signedEntry(node.self.Id, "load", epoch<<32+5, []byte("before"))
+	require.NoError(suite.T(), node.evalGossipEntry(before), "Own entry from before the restart was rejected.")
+	require.Empty(suite.T(), updates, "Handler invoked for own entry.")
+
+	entries := node.GossipEntries("load")
+	require.Len(suite.T(), entries, 1, "Own entry was not adopted.")
+	require.Equal(suite.T(), []byte("before"), entries[0].Value, "Invalid value.")
+
+	require.Equal(suite.T(), errOldGossipEntry, node.evalGossipEntry(before), "Adopted entry should be discarded.")
+
+	require.NoError(suite.T(), node.SetGossipKey("load", []byte("after")), "Failed to set key.")
+	require.Equal(suite.T(), epoch<<32+6, node.GossipEntries("load")[0].Version,
+		"Version should continue from the adopted entry.")
+
+	// Resumed identities start at a higher epoch, versions must exceed those of the previous epoch.
+	require.True(suite.T(), node.view.ShouldRebuttal(epoch, 1), "Note was not re-issued.")
+
+	require.NoError(suite.T(), node.SetGossipKey("load", []byte("resumed")), "Failed to set key.")
+	require.Equal(suite.T(), (epoch+1)<<32+1, node.GossipEntries("load")[0].Version,
+		"Version should be prefixed by the new epoch.")
+}
+
+func (suite *HandlerTestSuite) signedEntry(origin, key string, version uint64, value []byte) *pb.GossipEntry {
+	e := &pb.GossipEntry{
+		Origin:  []byte(origin),
+		Key:     key,
+		Version: version,
+		Value:   value,
+	}
+
+	bytes, err := proto.Marshal(e)
+	require.NoError(suite.T(), err, "Failed to marshal entry.")
+
+	cs := &cryptoStub{priv: suite.privMap[origin]}
+	r, s, err := cs.Sign(bytes)
+	require.NoError(suite.T(), err, "Failed to sign entry.")
+
+	e.Signature = &pb.Signature{R: r, S: s}
+
+	return e
+}
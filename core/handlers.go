@@ -11,8 +11,10 @@ import (
 	"github.com/joonnna/ifrit/core/discovery"
 	pb "github.com/joonnna/ifrit/protobuf"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	grpcPeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -73,14 +75,18 @@ func (n *Node) Spread(ctx context.Context, args *pb.State) (*pb.StateResponse, e
 		// no need to merge views.
 		if hosts != nil {
 			n.mergeViews(hosts, reply)
+			reply.GossipEntries = n.newerGossipEntries(args.GetGossipDigest())
 		}
 
 		if handler := n.getGossipHandler(); handler != nil && extGossip != nil {
-			reply.ExternalGossip, err = handler(extGossip)
+			reply.ExternalGossip, err = handler(newRequest(ctx, cert, extGossip))
 			if err != nil {
 				log.Error(err.Error())
 			}
 		}
+
+		n.handleTopicGossip(ctx, cert, args.GetTopicGossip(), reply)
+		n.mergeRumors(args.GetRumors())
 	} else if observed {
 		if !peer.IsAccused() {
 			err := n.evalNote(args.GetOwnNote())
@@ -123,21 +129,33 @@ func (n *Node) Spread(ctx context.Context, args *pb.State) (*pb.StateResponse, e
 		}
 	}
 
+	n.getBehavior().Reply(n, remoteId, reply)
+
 	return reply, nil
 }
 
 func (n *Node) Messenger(ctx context.Context, args *pb.Msg) (*pb.MsgResponse, error) {
 	var replyContent []byte
 
-	_, err := n.validateCtx(ctx)
+	cert, err := n.validateCtx(ctx)
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
-	if handler := n.getMsgHandler(); handler != nil {
-		replyContent, err = handler(args.GetContent())
+	handler := n.getMsgHandler()
+
+	if topic := args.GetTopic(); topic != "" {
+		if handler = n.topicMsgHandler(topic); handler == nil {
+			return nil, status.Error(codes.Unimplemented, topic)
+		}
+	}
+
+	// Handler errors are always sent with the unknown code,
+	// the sender relies on it to separate them from transport errors.
+	if handler != nil {
+		replyContent, err = handler(newRequest(ctx, cert, args.GetContent()))
 		if err != nil {
-			return nil, err
+			return nil, status.Error(codes.Unknown, err.Error())
 		}
 	}
 
@@ -150,10 +168,16 @@ func (n *Node) Stream(srv pb.Gossip_StreamServer) error {
 	reply := make(chan []byte)
 	defer close(input)
 
-	if handler := n.getStreamHandler(); handler != nil {		
-		go n.runStreamHandler(handler, input, reply)
+	ctx := srv.Context()
+
+	cert, err := n.validateCtx(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if handler := n.getStreamHandler(); handler != nil {
+		go n.runStreamHandler(handler, newRequest(ctx, cert, nil), input, reply)
 		go n.replyStream(reply, srv)
-		ctx := srv.Context()
 
 		for {
 			select {
@@ -191,8 +215,8 @@ func (n *Node) replyStream(reply chan []byte, srv pb.Gossip_StreamServer) {
 	}
 }
 
-func (n *Node) runStreamHandler(handler streamMsg, input, reply chan []byte) {
-	handler(input, reply)
+func (n *Node) runStreamHandler(handler streamMsg, r *Request, input, reply chan []byte) {
+	handler(r, input, reply)
 }
 
 func (n *Node) mergeViews(given map[string]uint64, reply *pb.StateResponse) {
@@ -285,6 +309,11 @@ func (n *Node) mergeCertificates(certs []*pb.Certificate) {
 			continue
 		}
 
+		// Known certificates are ignored, skip verifying their signature again.
+		if n.view.Exists(string(cert.SubjectKeyId)) {
+			continue
+		}
+
 		err = n.evalCertificate(cert)
 		if err != nil {
 			log.Debug(err.Error())
@@ -319,12 +348,14 @@ func (n *Node) evalAccusation(a *pb.Accusation, accuserPeer, p *discovery.Peer)
 			return errInvalidSignature
 		}
 
-		if rebut := n.view.ShouldRebuttal(epoch, ringNum); rebut {
-			n.protocol().Rebuttal(n)
-			return nil
-		} else {
+		if note := n.self.Note(); note == nil || !note.Equal(epoch) {
 			return errInvalidSelfAccusation
 		}
+
+		n.accusedSelf(epoch)
+		n.getBehavior().Rebuttal(n, epoch, ringNum)
+
+		return nil
 	}
 
 	acc := p.RingAccusation(ringNum)
@@ -355,6 +386,8 @@ func (n *Node) evalAccusation(a *pb.Accusation, accuserPeer, p *discovery.Peer)
 			return err
 		}
 
+		n.view.Emit(discovery.PeerAccused, p, ringNum, epoch)
+
 		live := n.view.IsAlive(p.Id)
 		if exists := n.view.HasTimer(p.Id); !exists && live {
 			n.view.StartTimer(p, p.Note(), accuserPeer)
@@ -399,6 +432,28 @@ func (n *Node) evalNote(newNote *pb.Note) error {
 		return err
 	}
 
+	// Peer is shutting down, no need to wait for the failure detector.
+	if newNote.GetLeaving() {
+		if valid := n.cs.Verify(bytes, r, s, p.PublicKey()); !valid {
+			return errInvalidSignature
+		}
+
+		p.AddNote(mask, epoch, true, r, s)
+
+		// The leaving note invalidates all accusations against previous notes.
+		for _, a := range p.AllAccusations() {
+			if a.IsMoreRecent(epoch) {
+				p.RemoveAccusation(a)
+			}
+		}
+
+		n.view.RemoveLeft(p.Id)
+
+		log.Debug("Peer left", "epoch", epoch, "addr", p.Addr)
+
+		return nil
+	}
+
 	accusations := p.AllAccusations()
 	// Not accused, only need to check if newnote is more recent
 	if numAccs := len(accusations); numAccs == 0 {
@@ -408,7 +463,7 @@ func (n *Node) evalNote(newNote *pb.Note) error {
 				return errInvalidSignature
 			}
 
-			p.AddNote(mask, epoch, r, s)
+			p.AddNote(mask, epoch, false, r, s)
 
 			if alive := n.view.IsAlive(p.Id); !alive {
 				n.view.AddLive(p)
@@ -427,7 +482,7 @@ func (n *Node) evalNote(newNote *pb.Note) error {
 		}
 
 		if note == nil || note.IsMoreRecent(epoch) {
-			p.AddNote(mask, epoch, r, s)
+			p.AddNote(mask, epoch, false, r, s)
 		}
 
 		// All accusations has to be invalidated before we add peer back to full view.
@@ -442,6 +497,8 @@ func (n *Node) evalNote(newNote *pb.Note) error {
 				n.view.AddLive(p)
 			}
 
+			n.view.Emit(discovery.PeerRebutted, p, 0, epoch)
+
 			log.Debug("Rebuttal received", "epoch", epoch, "addr", p.Addr)
 		}
 	}
@@ -23,8 +23,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	grpcPeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type HandlerTestSuite struct {
@@ -52,7 +54,7 @@ func (suite *HandlerTestSuite) SetupTest() {
 
 	ownCert := genCert(priv, 10)
 
-	n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: ownCert}, &cryptoStub{priv: priv})
+	n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: ownCert}, &cryptoStub{priv: priv}, testConfig())
 	require.NoError(suite.T(), err, "Failed to create node.")
 
 	suite.n = n
@@ -277,7 +279,38 @@ func (suite *HandlerTestSuite) TestSpread() {
 }
 
 func (suite *HandlerTestSuite) TestMessenger() {
+	var received *Request
 
+	node := suite.n
+	p := node.view.Full()[0]
+
+	node.SetMsgHandler(func(r *Request) ([]byte, error) {
+		received = r
+		if string(r.Data) == "fail" {
+			return nil, errors.New("handler failure")
+		}
+		return []byte("reply"), nil
+	})
+	defer node.SetMsgHandler(nil)
+
+	resp, err := node.Messenger(peerContext(p), &proto.Msg{Content: []byte("data")})
+	require.NoError(suite.T(), err, "Valid message returned error.")
+	require.Equal(suite.T(), []byte("reply"), resp.GetContent(), "Invalid reply content.")
+
+	require.NotNil(suite.T(), received, "Handler was not invoked.")
+	require.Equal(suite.T(), p.Id, received.SenderId, "Invalid sender id.")
+	require.Equal(suite.T(), p.Addr, received.SenderAddr, "Invalid sender address.")
+	require.Equal(suite.T(), p.Certificate(), received.Certificate.Raw, "Invalid sender certificate.")
+	require.Equal(suite.T(), []byte("data"), received.Data, "Invalid request data.")
+	require.NotNil(suite.T(), received.Context, "Request context was nil.")
+
+	_, err = node.Messenger(peerContext(p), &proto.Msg{Content: []byte("fail")})
+	require.Equal(suite.T(), codes.Unknown, status.Code(err), "Handler error had invalid code.")
+
+	received = nil
+	_, err = node.Messenger(noCertPeerContext(p), &proto.Msg{Content: []byte("data")})
+	require.Equal(suite.T(), codes.Unauthenticated, status.Code(err), "Missing certificate had invalid code.")
+	require.Nil(suite.T(), received, "Handler invoked without sender certificate.")
 }
 
 func (suite *HandlerTestSuite) TestMergeViews() {
@@ -673,6 +706,36 @@ func (suite *HandlerTestSuite) TestEvalNote() {
 	}
 }
 
+func (suite *HandlerTestSuite) TestEvalLeavingNote() {
+	node := suite.n
+
+	mask := uint32(math.MaxUint32)
+
+	peer := node.view.Live()[0]
+	epoch := peer.Note().Epoch() + 1
+
+	ch, cancel := node.Subscribe()
+	defer cancel()
+
+	err := node.evalNote(discovery.NewLeavingNote(peer.Id, epoch, mask, suite.priv))
+	require.Equal(suite.T(), errInvalidSignature, err, "Leaving note signed by another key should be rejected.")
+	require.True(suite.T(), node.view.IsAlive(peer.Id), "Peer removed on invalid leaving note.")
+
+	err = node.evalNote(discovery.NewLeavingNote(peer.Id, epoch, mask, suite.privMap[peer.Id]))
+	require.NoError(suite.T(), err, "Valid leaving note rejected.")
+	require.False(suite.T(), node.view.IsAlive(peer.Id), "Leaving peer still in live view.")
+	require.True(suite.T(), peer.Note().IsLeaving(), "Leaving note not stored.")
+
+	e := <-ch
+	require.Equal(suite.T(), discovery.PeerLeft, e.Type, "Should emit leave event.")
+	require.Equal(suite.T(), peer.Id, e.Id, "Event contains wrong id.")
+
+	// Restarting with the same identity issues a more recent note.
+	err = node.evalNote(discovery.NewNote(peer.Id, epoch+1, mask, suite.privMap[peer.Id]))
+	require.NoError(suite.T(), err, "Note after leaving rejected.")
+	require.True(suite.T(), node.view.IsAlive(peer.Id), "Peer not added back after rejoining.")
+}
+
 func (suite *HandlerTestSuite) TestEvalCertificate() {
 	node := suite.n
 
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"golang.org/x/net/context"
+)
+
+const pingReqTopic = InternalTopicPrefix + "pingreq"
+
+var errUnknownTarget = errors.New("Ping target is not in the full view")
+
+// Probes the peer directly, once it failed too many consecutive pings some of our ring neighbours
+// are asked to ping it on our behalf.
+// Returns errDead only if the direct and all indirect probes failed.
+func (n *Node) probe(p *discovery.Peer) error {
+	err := n.fd.probe(p)
+	if err != errDead || n.indirectProbes <= 0 {
+		return err
+	}
+
+	if n.probeIndirect(p) {
+		log.Debug("Indirect probe succeeded", "addr", p.Addr)
+		p.ResetPing()
+		n.fd.arrived(p)
+		return nil
+	}
+
+	return errDead
+}
+
+// Asks the relays to ping the target concurrently, returns true as soon as one of them
+// relays a pong signed by the target.
+func (n *Node) probeIndirect(target *discovery.Peer) bool {
+	relays := n.pingRelays(target)
+	if len(relays) == 0 {
+		return false
+	}
+
+	ping := &pb.Ping{
+		Nonce: genNonce(),
+	}
+
+	req, err := proto.Marshal(&pb.PingRequest{
+		Target: []byte(target.Id),
+		Nonce:  ping.GetNonce(),
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return false
+	}
+
+	// Covers the request to the relay and the relay's own ping.
+	ctx, cancel := context.WithTimeout(context.Background(), n.pingTimeout*2)
+	defer cancel()
+
+	ch := make(chan bool, len(relays))
+
+	for _, r := range relays {
+		go func(relay *discovery.Peer) {
+			ch <- n.relayPing(ctx, relay, target, ping, req)
+		}(r)
+	}
+
+	for range relays {
+		if <-ch {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *Node) relayPing(ctx context.Context, relay, target *discovery.Peer, ping *pb.Ping, req []byte) bool {
+	msg := &pb.Msg{
+		Content: req,
+		Topic:   pingReqTopic,
+	}
+
+	// Bypasses the dispatcher, probes should not queue behind application messages.
+	resp, err := n.sendMsg(ctx, relay.Addr, msg)
+	if err != nil {
+		log.Debug("Indirect probe failed", "relay", relay.Addr, "target", target.Addr, "err", err)
+		return false
+	}
+
+	pong := &pb.Pong{}
+
+	if err := proto.Unmarshal(resp, pong); err != nil {
+		log.Error(err.Error())
+		return false
+	}
+
+	if err := n.fd.verifyPong(target, ping, pong); err != nil {
+		log.Debug(err.Error(), "relay", relay.Addr, "target", target.Addr)
+		return false
+	}
+
+	return true
+}
+
+// Returns up to indirectProbes unaccused ring neighbours other than the target.
+// Ring positions are random, the neighbours are therefore a random sample of the network.
+func (n *Node) pingRelays(target *discovery.Peer) []*discovery.Peer {
+	var ret []*discovery.Peer
+
+	for _, p := range n.view.MyNeighbours() {
+		if len(ret) >= n.indirectProbes {
+			break
+		}
+
+		if p.Id == target.Id || p.IsAccused() {
+			continue
+		}
+
+		ret = append(ret, p)
+	}
+
+	return ret
+}
+
+// Pings the target of an indirect probe on behalf of the sender and returns the pong.
+func (n *Node) handlePingRequest(r *Request) ([]byte, error) {
+	req := &pb.PingRequest{}
+
+	if err := proto.Unmarshal(r.Data, req); err != nil {
+		return nil, err
+	}
+
+	target := n.view.Peer(string(req.GetTarget()))
+	if target == nil {
+		return nil, errUnknownTarget
+	}
+
+	pong, err := n.fd.ps.Ping(target.PingAddr, &pb.Ping{Nonce: req.GetNonce()})
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(pong)
+}
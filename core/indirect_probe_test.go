@@ -0,0 +1,59 @@
+package core
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *HandlerTestSuite) TestHandlePingRequest() {
+	node := suite.n
+
+	p := node.view.Live()[0]
+	node.fd = newFd(&pongStub{priv: suite.privMap[p.Id]}, node.cs, 3, 0, discovery.RealClock())
+
+	ping := &pb.Ping{
+		Nonce: genNonce(),
+	}
+
+	req, err := proto.Marshal(&pb.PingRequest{Target: []byte(p.Id), Nonce: ping.GetNonce()})
+	require.NoError(suite.T(), err, "Failed to marshal request.")
+
+	resp, err := node.handlePingRequest(&Request{Data: req})
+	require.NoError(suite.T(), err, "Failed to handle ping request.")
+
+	pong := &pb.Pong{}
+	require.NoError(suite.T(), proto.Unmarshal(resp, pong), "Failed to unmarshal pong.")
+
+	require.NoError(suite.T(), node.fd.verifyPong(p, ping, pong), "Relayed pong was rejected.")
+	require.Equal(suite.T(), errInvalidPongSignature, node.fd.verifyPong(p, &pb.Ping{Nonce: genNonce()}, pong),
+		"Relayed pong should only be valid for the requested nonce.")
+
+	req, err = proto.Marshal(&pb.PingRequest{Target: []byte("unknown"), Nonce: ping.GetNonce()})
+	require.NoError(suite.T(), err, "Failed to marshal request.")
+
+	_, err = node.handlePingRequest(&Request{Data: req})
+	require.Equal(suite.T(), errUnknownTarget, err, "Unknown target should be rejected.")
+}
+
+func (suite *HandlerTestSuite) TestPingRelays() {
+	node := suite.n
+
+	target := node.view.MyNeighbours()[0]
+
+	node.indirectProbes = 3
+
+	relays := node.pingRelays(target)
+	require.Len(suite.T(), relays, 3, "Invalid number of relays.")
+
+	for _, r := range relays {
+		require.NotEqual(suite.T(), target.Id, r.Id, "Target should not relay its own ping.")
+	}
+
+	// Without relays the peer is considered dead once the direct pings failed.
+	node.fd = newFd(&pongStub{err: errPingStub}, node.cs, 1, 0, discovery.RealClock())
+	node.indirectProbes = 0
+
+	require.Equal(suite.T(), errDead, node.probe(target), "Peer should be dead without relays.")
+}
@@ -0,0 +1,456 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"golang.org/x/net/context"
+)
+
+const (
+	leaseTopic = InternalTopicPrefix + "lease"
+
+	defaultLeaseDuration = time.Second * 10
+
+	leaseRequestTimeout = time.Second * 5
+)
+
+var (
+	// Returned by Leader if no member currently grants a lease for the group.
+	ErrNoLeader = errors.New("No leader holds a lease for the group")
+
+	errNoGroup          = errors.New("Group is empty")
+	errNotCandidate     = errors.New("Not the leader candidate of the group")
+	errCandidatePending = errors.New("Leader candidate waits for previous leases to expire")
+	errNoCandidate      = errors.New("No leader candidate found in live view")
+	errLeaseMismatch    = errors.New("Lease does not match the requested group and candidate")
+	errLeaseExpired     = errors.New("Lease has expired")
+	errNoLeaseSignature = errors.New("Lease has no signature")
+)
+
+// Lease grants leadership over a group until it expires, see Leader.
+type Lease struct {
+	Group string
+
+	// Ifrit id and address of the leader.
+	Leader string
+	Addr   string
+
+	// Time at which the lease expires according to the leader's clock.
+	Expires time.Time
+}
+
+type leaseState struct {
+	duration time.Duration
+
+	// Groups to keep a lease for, and the most recent valid lease of each.
+	groups map[string]bool
+	leases map[string]*pb.Lease
+
+	// Groups other members requested leases for.
+	requested map[string]bool
+
+	// Time since when the local node has been the candidate of a group,
+	// re-evaluated each renewal interval and on every membership change.
+	candidateSince map[string]time.Time
+
+	// Epoch of the local note when it was last validly accused.
+	accused      bool
+	accusedEpoch uint64
+
+	mutex sync.Mutex
+}
+
+func newLeaseState(duration time.Duration) *leaseState {
+	if duration == 0 {
+		duration = defaultLeaseDuration
+	}
+
+	return &leaseState{
+		duration:       duration,
+		groups:         make(map[string]bool),
+		leases:         make(map[string]*pb.Lease),
+		requested:      make(map[string]bool),
+		candidateSince: make(map[string]time.Time),
+	}
+}
+
+// Returns the current lease of group, acquiring one from the leader candidate if none is held.
+// The candidate is the first member succeeding the group on ring 1 that is not accused,
+// every member with the same live view agrees on it. A candidate only grants leases once it has
+// been the candidate for a full lease duration without any membership change or accusation in between,
+// so leases granted by a previous leader have expired.
+// Leases of the group are renewed in the background from then on, and revoked when the leader
+// is accused, removed or leaves. Each change emits a LeaderChanged event.
+// Returns ErrNoLeader if no lease could be acquired.
+func (n *Node) Leader(group string) (*Lease, error) {
+	if group == "" {
+		return nil, errNoGroup
+	}
+
+	n.leases.mutex.Lock()
+	n.leases.groups[group] = true
+	l := n.leases.leases[group]
+	n.leases.mutex.Unlock()
+
+	if l != nil && n.leaseValid(l) {
+		return n.newLease(l), nil
+	}
+
+	l, err := n.acquireLease(group)
+	if err != nil {
+		log.Debug(err.Error(), "group", group)
+		return nil, ErrNoLeader
+	}
+
+	return n.newLease(l), nil
+}
+
+// Returns the first live member succeeding group on ring 1 that is not accused.
+// The local node counts as accused until it has rebutted an accusation against it.
+func (n *Node) leaderCandidate(group string) (*discovery.Peer, error) {
+	peers, err := n.view.Lookup(1, []byte(group), len(n.view.Live())+1)
+	if err != nil {
+		return nil, err
+	}
+
+	selfAccused := n.selfAccused()
+
+	for _, p := range peers {
+		if p.Id == n.self.Id && !selfAccused {
+			return p, nil
+		}
+
+		if p.Id != n.self.Id && !p.IsAccused() {
+			return p, nil
+		}
+	}
+
+	return nil, errNoCandidate
+}
+
+func (n *Node) acquireLease(group string) (*pb.Lease, error) {
+	var l *pb.Lease
+
+	candidate, err := n.leaderCandidate(group)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate.Id == n.self.Id {
+		l, err = n.grantLease(group)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), leaseRequestTimeout)
+		defer cancel()
+
+		resp, err := n.SendTopicMessageContext(ctx, candidate.Addr, leaseTopic, []byte(group))
+		if err != nil {
+			return nil, err
+		}
+
+		l = &pb.Lease{}
+
+		if err := proto.Unmarshal(resp, l); err != nil {
+			return nil, err
+		}
+
+		if err := n.verifyLease(l, group, candidate); err != nil {
+			return nil, err
+		}
+	}
+
+	n.setLease(group, l)
+
+	return l, nil
+}
+
+// Signs a lease of group for the local node, given that it is the candidate and
+// has been for at least a lease duration.
+func (n *Node) grantLease(group string) (*pb.Lease, error) {
+	candidate, err := n.leaderCandidate(group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	n.leases.mutex.Lock()
+	since, ok := n.leases.candidateSince[group]
+	duration := n.leases.duration
+	n.leases.mutex.Unlock()
+
+	if candidate.Id != n.self.Id {
+		return nil, errNotCandidate
+	}
+
+	if !ok || now.Sub(since) < duration {
+		return nil, errCandidatePending
+	}
+
+	l := &pb.Lease{
+		Group:   group,
+		Leader:  []byte(n.self.Id),
+		Expires: now.Add(duration).UnixNano(),
+	}
+
+	bytes, err := proto.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := n.cs.Sign(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	l.Signature = &pb.Signature{
+		R: r,
+		S: s,
+	}
+
+	return l, nil
+}
+
+// Message handler of the internal lease topic, the request carries the group name.
+// The candidacy of the local node for the group is tracked from then on.
+func (n *Node) handleLeaseRequest(r *Request) ([]byte, error) {
+	group := string(r.Data)
+	if group == "" {
+		return nil, errNoGroup
+	}
+
+	n.leases.mutex.Lock()
+	n.leases.requested[group] = true
+	n.leases.mutex.Unlock()
+
+	l, err := n.grantLease(group)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(l)
+}
+
+func (n *Node) verifyLease(l *pb.Lease, group string, candidate *discovery.Peer) error {
+	if l.GetGroup() != group || string(l.GetLeader()) != candidate.Id {
+		return errLeaseMismatch
+	}
+
+	if time.Now().UnixNano() >= l.GetExpires() {
+		return errLeaseExpired
+	}
+
+	sign := l.GetSignature()
+	if sign == nil {
+		return errNoLeaseSignature
+	}
+
+	// Verify a copy without the signature.
+	unsigned := &pb.Lease{
+		Group:   l.GetGroup(),
+		Leader:  l.GetLeader(),
+		Expires: l.GetExpires(),
+	}
+
+	bytes, err := proto.Marshal(unsigned)
+	if err != nil {
+		return err
+	}
+
+	if valid := n.cs.Verify(bytes, sign.GetR(), sign.GetS(), candidate.PublicKey()); !valid {
+		return errInvalidSignature
+	}
+
+	return nil
+}
+
+// Returns true if the lease has not expired and its leader is still a live, unaccused member.
+func (n *Node) leaseValid(l *pb.Lease) bool {
+	if time.Now().UnixNano() >= l.GetExpires() {
+		return false
+	}
+
+	leader := string(l.GetLeader())
+	if leader == n.self.Id {
+		return !n.selfAccused()
+	}
+
+	p := n.view.LivePeer(leader)
+
+	return p != nil && !p.IsAccused()
+}
+
+// Stores the lease of group and emits a LeaderChanged event if the leader changed.
+func (n *Node) setLease(group string, l *pb.Lease) {
+	n.leases.mutex.Lock()
+	prev := n.leases.leases[group]
+	n.leases.leases[group] = l
+	n.leases.mutex.Unlock()
+
+	if prev == nil || string(prev.GetLeader()) != string(l.GetLeader()) {
+		n.view.EmitLeader(group, n.leasePeer(l))
+	}
+}
+
+// Drops the leases held by the member with the given id, or all expired leases if id is empty.
+func (n *Node) revokeLeases(id string) {
+	var revoked []string
+
+	now := time.Now().UnixNano()
+
+	n.leases.mutex.Lock()
+
+	for group, l := range n.leases.leases {
+		if string(l.GetLeader()) == id || (id == "" && now >= l.GetExpires()) {
+			delete(n.leases.leases, group)
+			revoked = append(revoked, group)
+		}
+	}
+
+	n.leases.mutex.Unlock()
+
+	for _, group := range revoked {
+		n.view.EmitLeader(group, nil)
+	}
+}
+
+// Renews the leases of all groups in use and revokes leases of members
+// that are accused, removed or leave. Membership changes and accusations restart
+// the candidacy of the local node, they might have changed the candidate at other members.
+func (n *Node) leaseLoop() {
+	defer n.wg.Done()
+
+	events, cancel := n.view.Subscribe()
+	defer cancel()
+
+	ticker := time.NewTicker(n.leases.duration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.exitChan:
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			switch e.Type {
+			case discovery.PeerJoined, discovery.PeerRebutted:
+				n.updateCandidacy()
+			case discovery.PeerAccused, discovery.PeerRemoved, discovery.PeerLeft:
+				n.updateCandidacy()
+				n.revokeLeases(e.Id)
+			}
+		case <-ticker.C:
+			n.updateCandidacy()
+			n.renewLeases()
+		}
+	}
+}
+
+func (n *Node) renewLeases() {
+	var groups []string
+
+	n.leases.mutex.Lock()
+	for group := range n.leases.groups {
+		groups = append(groups, group)
+	}
+	n.leases.mutex.Unlock()
+
+	for _, group := range groups {
+		if _, err := n.acquireLease(group); err != nil {
+			log.Debug(err.Error(), "group", group)
+		}
+	}
+
+	n.revokeLeases("")
+}
+
+// Records since when the local node has been the candidate of each group it tracks,
+// and forgets groups it is no longer the candidate of. Membership changes which do not
+// change the candidate of a group leave its candidacy untouched.
+func (n *Node) updateCandidacy() {
+	groups := make(map[string]bool)
+
+	n.leases.mutex.Lock()
+	for group := range n.leases.groups {
+		groups[group] = true
+	}
+	for group := range n.leases.requested {
+		groups[group] = true
+	}
+	n.leases.mutex.Unlock()
+
+	now := time.Now()
+
+	for group := range groups {
+		candidate, err := n.leaderCandidate(group)
+
+		n.leases.mutex.Lock()
+		if err != nil || candidate.Id != n.self.Id {
+			delete(n.leases.candidateSince, group)
+		} else if _, ok := n.leases.candidateSince[group]; !ok {
+			n.leases.candidateSince[group] = now
+		}
+		n.leases.mutex.Unlock()
+	}
+}
+
+// Records a valid accusation against the local note with the given epoch,
+// the local node revokes its own leases and is no candidate until it has rebutted.
+func (n *Node) accusedSelf(epoch uint64) {
+	n.leases.mutex.Lock()
+	n.leases.accused = true
+	n.leases.accusedEpoch = epoch
+	n.leases.candidateSince = make(map[string]time.Time)
+	n.leases.mutex.Unlock()
+
+	n.revokeLeases(n.self.Id)
+}
+
+// Returns true if the local note was accused and has not been re-issued since.
+func (n *Node) selfAccused() bool {
+	n.leases.mutex.Lock()
+	accused, epoch := n.leases.accused, n.leases.accusedEpoch
+	n.leases.mutex.Unlock()
+
+	if !accused {
+		return false
+	}
+
+	note := n.self.Note()
+
+	return note == nil || note.Equal(epoch)
+}
+
+func (n *Node) leasePeer(l *pb.Lease) *discovery.Peer {
+	leader := string(l.GetLeader())
+	if leader == n.self.Id {
+		return n.self
+	}
+
+	return n.view.Peer(leader)
+}
+
+func (n *Node) newLease(l *pb.Lease) *Lease {
+	ret := &Lease{
+		Group:   l.GetGroup(),
+		Leader:  string(l.GetLeader()),
+		Expires: time.Unix(0, l.GetExpires()),
+	}
+
+	if p := n.leasePeer(l); p != nil {
+		ret.Addr = p.Addr
+	}
+
+	return ret
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *HandlerTestSuite) TestLeader() {
+	node := suite.n
+	node.leases.duration = time.Millisecond * 50
+
+	_, err := node.Leader("")
+	require.Equal(suite.T(), errNoGroup, err, "Empty group should be rejected.")
+
+	group := suite.candidateGroup(true)
+
+	events, cancel := node.view.Subscribe()
+	defer cancel()
+
+	_, err = node.Leader(group)
+	require.Equal(suite.T(), ErrNoLeader, err, "New candidate should wait before granting leases.")
+
+	node.updateCandidacy()
+	time.Sleep(node.leases.duration)
+
+	l, err := node.Leader(group)
+	require.NoError(suite.T(), err, "Candidate should grant lease after waiting.")
+	require.Equal(suite.T(), group, l.Group, "Invalid group.")
+	require.Equal(suite.T(), node.self.Id, l.Leader, "Invalid leader.")
+	require.Equal(suite.T(), node.self.Addr, l.Addr, "Invalid leader address.")
+	require.True(suite.T(), l.Expires.After(time.Now()), "Lease should not have expired.")
+
+	e := <-events
+	require.Equal(suite.T(), discovery.LeaderChanged, e.Type, "Invalid event type.")
+	require.Equal(suite.T(), group, e.Group, "Invalid event group.")
+	require.Equal(suite.T(), node.self.Id, e.Id, "Invalid event leader.")
+
+	// Renewing with the same leader does not emit events.
+	node.renewLeases()
+	require.Empty(suite.T(), events, "Renewal should not emit events.")
+
+	_, err = node.handleLeaseRequest(&Request{Data: []byte(suite.candidateGroup(false))})
+	require.Equal(suite.T(), errNotCandidate, err, "Only the candidate should grant leases.")
+}
+
+func (suite *HandlerTestSuite) TestLeaderChurn() {
+	node := suite.n
+	node.leases.duration = time.Millisecond * 50
+
+	group := suite.candidateGroup(true)
+
+	_, err := node.Leader(group)
+	require.Equal(suite.T(), ErrNoLeader, err, "New candidate should wait before granting leases.")
+
+	node.updateCandidacy()
+	since := node.leases.candidateSince[group]
+
+	// Accusing another member does not change the candidate of the group.
+	var other *discovery.Peer
+	for _, p := range node.view.Live() {
+		if p.Id != node.self.Id {
+			other = p
+			break
+		}
+	}
+
+	other.AddTestAccusation(discovery.NewAccusation(1, other.Id, node.self.Id, 1, suite.priv))
+	node.updateCandidacy()
+	require.Equal(suite.T(), since, node.leases.candidateSince[group], "Unrelated churn should not reset candidacy.")
+
+	time.Sleep(node.leases.duration)
+
+	_, err = node.Leader(group)
+	require.NoError(suite.T(), err, "Candidate should grant lease despite unrelated churn.")
+}
+
+func (suite *HandlerTestSuite) TestVerifyLease() {
+	node := suite.n
+	group := suite.candidateGroup(false)
+
+	candidate, err := node.leaderCandidate(group)
+	require.NoError(suite.T(), err, "Failed to find candidate.")
+
+	expires := time.Now().Add(time.Minute)
+
+	l := suite.signedLease(candidate.Id, group, expires)
+	require.NoError(suite.T(), node.verifyLease(l, group, candidate), "Valid lease was rejected.")
+
+	require.Equal(suite.T(), errLeaseMismatch, node.verifyLease(l, "other", candidate),
+		"Lease of another group should be rejected.")
+
+	tampered := suite.signedLease(candidate.Id, group, expires)
+	tampered.Expires = expires.Add(time.Hour).UnixNano()
+	require.Equal(suite.T(), errInvalidSignature, node.verifyLease(tampered, group, candidate),
+		"Tampered lease should be rejected.")
+
+	expired := suite.signedLease(candidate.Id, group, time.Now().Add(-time.Second))
+	require.Equal(suite.T(), errLeaseExpired, node.verifyLease(expired, group, candidate),
+		"Expired lease should be rejected.")
+
+	unsigned := suite.signedLease(candidate.Id, group, expires)
+	unsigned.Signature = nil
+	require.Equal(suite.T(), errNoLeaseSignature, node.verifyLease(unsigned, group, candidate),
+		"Unsigned lease should be rejected.")
+}
+
+func (suite *HandlerTestSuite) TestRevokeLeases() {
+	node := suite.n
+	group := suite.candidateGroup(false)
+
+	candidate, err := node.leaderCandidate(group)
+	require.NoError(suite.T(), err, "Failed to find candidate.")
+
+	l := suite.signedLease(candidate.Id, group, time.Now().Add(time.Minute))
+	require.True(suite.T(), node.leaseValid(l), "Lease should be valid.")
+
+	events, cancel := node.view.Subscribe()
+	defer cancel()
+
+	node.setLease(group, l)
+
+	e := <-events
+	require.Equal(suite.T(), candidate.Id, e.Id, "Invalid event leader.")
+	require.Equal(suite.T(), candidate.Addr, e.Addr, "Invalid event address.")
+
+	node.revokeLeases("")
+	require.Contains(suite.T(), node.leases.leases, group, "Valid lease should not be revoked.")
+
+	node.revokeLeases(candidate.Id)
+	require.NotContains(suite.T(), node.leases.leases, group, "Lease of removed leader should be revoked.")
+
+	e = <-events
+	require.Equal(suite.T(), discovery.LeaderChanged, e.Type, "Invalid event type.")
+	require.Equal(suite.T(), group, e.Group, "Invalid event group.")
+	require.Empty(suite.T(), e.Id, "Revoked group should have no leader.")
+
+	acc := discovery.NewAccusation(1, candidate.Id, node.self.Id, 1, suite.priv)
+	candidate.AddTestAccusation(acc)
+	require.False(suite.T(), node.leaseValid(l), "Lease of accused leader should be invalid.")
+
+	next, err := node.leaderCandidate(group)
+	require.NoError(suite.T(), err, "Failed to find candidate.")
+	require.NotEqual(suite.T(), candidate.Id, next.Id, "Accused member should not be candidate.")
+}
+
+func (suite *HandlerTestSuite) TestLeaderAccused() {
+	node := suite.n
+	node.leases.duration = time.Millisecond * 50
+
+	group := suite.candidateGroup(true)
+
+	_, err := node.Leader(group)
+	require.Equal(suite.T(), ErrNoLeader, err, "New candidate should wait before granting leases.")
+
+	node.updateCandidacy()
+	time.Sleep(node.leases.duration)
+
+	l, err := node.Leader(group)
+	require.NoError(suite.T(), err, "Candidate should grant lease after waiting.")
+	require.Equal(suite.T(), node.self.Id, l.Leader, "Invalid leader.")
+
+	lease := node.leases.leases[group]
+
+	_, prev := node.view.MyRingNeighbours(1)
+	epoch := node.self.Note().Epoch()
+
+	node.SetBehavior(NoRebuttal())
+
+	acc := discovery.NewAccusation(epoch, node.self.Id, prev.Id, 1, suite.privMap[prev.Id])
+	require.NoError(suite.T(), node.evalAccusation(acc, prev, node.self), "Valid accusation was rejected.")
+
+	require.False(suite.T(), node.leaseValid(lease), "Lease of accused leader should be invalid.")
+	require.NotContains(suite.T(), node.leases.leases, group, "Lease of accused leader should be revoked.")
+
+	next, err := node.leaderCandidate(group)
+	require.NoError(suite.T(), err, "Failed to find candidate.")
+	require.NotEqual(suite.T(), node.self.Id, next.Id, "Accused leader should not be candidate.")
+
+	_, err = node.handleLeaseRequest(&Request{Data: []byte(group)})
+	require.Equal(suite.T(), errNotCandidate, err, "Accused leader should not grant leases.")
+
+	newLease := suite.signedLease(next.Id, group, time.Now().Add(time.Minute))
+	require.NoError(suite.T(), node.verifyLease(newLease, group, next), "Lease of new leader was rejected.")
+	node.setLease(group, newLease)
+
+	// The old leader rebuts and becomes candidate again.
+	node.SetBehavior(nil)
+
+	acc = discovery.NewAccusation(epoch, node.self.Id, prev.Id, 1, suite.privMap[prev.Id])
+	require.NoError(suite.T(), node.evalAccusation(acc, prev, node.self), "Valid accusation was rejected.")
+	require.Equal(suite.T(), epoch+1, node.self.Note().Epoch(), "Note should be re-issued.")
+
+	candidate, err := node.leaderCandidate(group)
+	require.NoError(suite.T(), err, "Failed to find candidate.")
+	require.Equal(suite.T(), node.self.Id, candidate.Id, "Returned leader should be candidate again.")
+
+	node.updateCandidacy()
+
+	_, err = node.grantLease(group)
+	require.Equal(suite.T(), errCandidatePending, err,
+		"Returned leader should wait for the leases of the new leader to expire.")
+
+	time.Sleep(node.leases.duration)
+
+	_, err = node.grantLease(group)
+	require.NoError(suite.T(), err, "Returned leader should grant leases after waiting.")
+}
+
+// Returns a group whose leader candidate is the local node if local is true, and a remote member otherwise.
+func (suite *HandlerTestSuite) candidateGroup(local bool) string {
+	for i := 0; ; i++ {
+		group := fmt.Sprintf("group%d", i)
+
+		candidate, err := suite.n.leaderCandidate(group)
+		require.NoError(suite.T(), err, "Failed to find candidate.")
+
+		if (candidate.Id == suite.n.self.Id) == local {
+			return group
+		}
+	}
+}
+
+func (suite *HandlerTestSuite) signedLease(leader, group string, expires time.Time) *pb.Lease {
+	l := &pb.Lease{
+		Group:   group,
+		Leader:  []byte(leader),
+		Expires: expires.UnixNano(),
+	}
+
+	bytes, err := proto.Marshal(l)
+	require.NoError(suite.T(), err, "Failed to marshal lease.")
+
+	cs := &cryptoStub{priv: suite.privMap[leader]}
+	r, s, err := cs.Sign(bytes)
+	require.NoError(suite.T(), err, "Failed to sign lease.")
+
+	l.Signature = &pb.Signature{R: r, S: s}
+
+	return l
+}
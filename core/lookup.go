@@ -0,0 +1,184 @@
+package core
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/joonnna/ifrit/core/discovery"
+)
+
+var (
+	errInvalidLookupSize = errors.New("Number of successors must be positive")
+	errNoLookupHandler   = errors.New("Ownership handler is nil")
+)
+
+// Key watched for ownership changes, see WatchOwnership.
+type ownershipWatch struct {
+	key     []byte
+	num     int
+	ringNum uint32
+
+	// Ids of the current owners, addresses might be shared or change.
+	owners  []string
+	handler func([]string)
+}
+
+type ownershipWatches struct {
+	watches map[uint64]*ownershipWatch
+	nextId  uint64
+
+	mutex sync.Mutex
+}
+
+func newOwnershipWatches() *ownershipWatches {
+	return &ownershipWatches{
+		watches: make(map[uint64]*ownershipWatch),
+	}
+}
+
+// Returns the addresses of the num first live members succeeding the position of key
+// on the given ring, the local node included. The first address is the primary owner.
+// Every member with the same live view returns the same owners.
+func (n *Node) Lookup(key []byte, num int, ringNum uint32) ([]string, error) {
+	peers, err := n.lookup(key, num, ringNum)
+	if err != nil {
+		return nil, err
+	}
+
+	return peerAddrs(peers), nil
+}
+
+func (n *Node) lookup(key []byte, num int, ringNum uint32) ([]*discovery.Peer, error) {
+	if num <= 0 {
+		return nil, errInvalidLookupSize
+	}
+
+	return n.view.Lookup(ringNum, key, num)
+}
+
+// Invokes handler with the new owners each time membership changes alter the
+// result of Lookup for the given key. Returns a function cancelling the watch.
+func (n *Node) WatchOwnership(key []byte, num int, ringNum uint32, handler func([]string)) (func(), error) {
+	if handler == nil {
+		return nil, errNoLookupHandler
+	}
+
+	owners, err := n.lookup(key, num, ringNum)
+	if err != nil {
+		return nil, err
+	}
+
+	n.owners.mutex.Lock()
+	defer n.owners.mutex.Unlock()
+
+	id := n.owners.nextId
+	n.owners.nextId++
+
+	n.owners.watches[id] = &ownershipWatch{
+		key:     key,
+		num:     num,
+		ringNum: ringNum,
+		owners:  peerIds(owners),
+		handler: handler,
+	}
+
+	cancel := func() {
+		n.owners.mutex.Lock()
+		defer n.owners.mutex.Unlock()
+
+		delete(n.owners.watches, id)
+	}
+
+	return cancel, nil
+}
+
+// Re-evaluates ownership of watched keys on each change of the live view.
+func (n *Node) ownershipLoop() {
+	defer n.wg.Done()
+
+	events, cancel := n.view.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-n.exitChan:
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			switch e.Type {
+			case discovery.PeerJoined, discovery.PeerRemoved, discovery.PeerLeft:
+				n.checkOwnership()
+			}
+		}
+	}
+}
+
+func (n *Node) checkOwnership() {
+	type change struct {
+		handler func([]string)
+		owners  []string
+	}
+
+	var changes []change
+
+	n.owners.mutex.Lock()
+
+	for _, w := range n.owners.watches {
+		owners, err := n.lookup(w.key, w.num, w.ringNum)
+		if err != nil {
+			continue
+		}
+
+		ids := peerIds(owners)
+		if equalOwners(w.owners, ids) {
+			continue
+		}
+
+		w.owners = ids
+		changes = append(changes, change{handler: w.handler, owners: peerAddrs(owners)})
+	}
+
+	n.owners.mutex.Unlock()
+
+	// Handlers are invoked without holding the lock, they might cancel their watch.
+	for _, c := range changes {
+		c.handler(c.owners)
+	}
+}
+
+func equalOwners(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func peerIds(peers []*discovery.Peer) []string {
+	ret := make([]string, 0, len(peers))
+
+	for _, p := range peers {
+		ret = append(ret, p.Id)
+	}
+
+	return ret
+}
+
+func peerAddrs(peers []*discovery.Peer) []string {
+	ret := make([]string, 0, len(peers))
+
+	for _, p := range peers {
+		ret = append(ret, p.Addr)
+	}
+
+	return ret
+}
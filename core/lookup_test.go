@@ -0,0 +1,93 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	priv, err := genKeys()
+	require.NoError(t, err, "Failed to generate keys")
+
+	n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: genCert(priv, 10)}, &cryptoStub{priv: priv}, testConfig())
+	require.NoError(t, err, "Failed to create node.")
+
+	key := []byte("testKey")
+
+	_, err = n.Lookup(key, 0, 1)
+	require.Equal(t, errInvalidLookupSize, err, "Should return error with zero successors.")
+
+	_, err = n.Lookup(key, 1, n.view.NumRings()+1)
+	require.Error(t, err, "Should return error with invalid ring.")
+
+	owners, err := n.Lookup(key, 3, 1)
+	require.NoError(t, err, "Returned error on valid lookup.")
+	require.Equal(t, []string{n.self.Addr}, owners, "Should only return myself when alone.")
+
+	for i := 0; i < 10; i++ {
+		_, _, err := addPeer(n)
+		require.NoError(t, err, "Could not add peer.")
+	}
+
+	owners, err = n.Lookup(key, 3, 1)
+	require.NoError(t, err, "Returned error on valid lookup.")
+	require.Equal(t, 3, len(owners), "Returned wrong amount of owners.")
+
+	other, err := n.Lookup(key, 3, 2)
+	require.NoError(t, err, "Returned error on valid lookup.")
+	require.Equal(t, 3, len(other), "Returned wrong amount of owners.")
+}
+
+func TestWatchOwnership(t *testing.T) {
+	var changes [][]string
+
+	priv, err := genKeys()
+	require.NoError(t, err, "Failed to generate keys")
+
+	n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: genCert(priv, 10)}, &cryptoStub{priv: priv}, testConfig())
+	require.NoError(t, err, "Failed to create node.")
+
+	key := []byte("testKey")
+
+	_, err = n.WatchOwnership(key, 1, 1, nil)
+	require.Equal(t, errNoLookupHandler, err, "Should return error with nil handler.")
+
+	cancel, err := n.WatchOwnership(key, 1, 1, func(owners []string) {
+		changes = append(changes, owners)
+	})
+	require.NoError(t, err, "Failed to watch key.")
+
+	owner := n.self
+
+	// Add peers until one of them takes over the key.
+	for len(changes) == 0 {
+		_, _, err := addPeer(n)
+		require.NoError(t, err, "Could not add peer.")
+
+		n.checkOwnership()
+	}
+
+	require.Equal(t, 1, len(changes), "Handler should be invoked once per change.")
+
+	owners, err := n.view.Lookup(1, key, 1)
+	require.NoError(t, err, "Returned error on valid lookup.")
+	require.NotEqual(t, owner, owners[0], "Ownership did not move.")
+	require.Equal(t, []string{owners[0].Addr}, changes[0], "Handler received wrong owners.")
+
+	n.checkOwnership()
+	require.Equal(t, 1, len(changes), "Handler invoked without ownership change.")
+
+	n.view.RemoveLive(owners[0].Id)
+	n.checkOwnership()
+	require.Equal(t, 2, len(changes), "Removing the owner should move the key.")
+
+	cancel()
+
+	_, _, err = addPeer(n)
+	require.NoError(t, err, "Could not add peer.")
+
+	n.view.RemoveLive(n.view.Live()[0].Id)
+	n.checkOwnership()
+	require.Equal(t, 2, len(changes), "Cancelled watch should not be invoked.")
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"crypto/x509"
+	"sort"
+
+	"github.com/joonnna/ifrit/core/discovery"
+)
+
+// Member describes a single participant of the network as observed by the local node.
+type Member struct {
+	// Ifrit id, stable across address changes.
+	Id string
+
+	// Address of the rpc endpoint (ip:port).
+	Addr string
+
+	// Address of the udp ping endpoint (ip:port).
+	PingAddr string
+
+	// Epoch and ring mask of the most recent note, both zero if no note is observed.
+	Epoch uint64
+	Mask  uint32
+
+	// Rings on which the member is currently accused, in ascending order.
+	AccusedRings []uint32
+
+	// True if the member is in the live view.
+	Live bool
+
+	Certificate *x509.Certificate
+}
+
+// Returns true if the member is accused on at least one ring.
+func (m *Member) Accused() bool {
+	return len(m.AccusedRings) > 0
+}
+
+func (n *Node) member(p *discovery.Peer) *Member {
+	m := &Member{
+		Id:          p.Id,
+		Addr:        p.Addr,
+		PingAddr:    p.PingAddr,
+		Live:        n.view.IsAlive(p.Id),
+		Certificate: p.X509(),
+	}
+
+	if note := p.Note(); note != nil {
+		m.Epoch = note.Epoch()
+		m.Mask = note.Mask()
+	}
+
+	for _, a := range p.AllAccusations() {
+		m.AccusedRings = append(m.AccusedRings, a.RingNum())
+	}
+
+	sort.Slice(m.AccusedRings, func(i, j int) bool {
+		return m.AccusedRings[i] < m.AccusedRings[j]
+	})
+
+	return m
+}
+
+// Returns the member with the given id, ErrUnknownPeer if it is not in the full view.
+func (n *Node) Member(id string) (*Member, error) {
+	p := n.view.Peer(id)
+	if p == nil {
+		return nil, ErrUnknownPeer
+	}
+
+	return n.member(p), nil
+}
+
+// Returns all members of the full view, including those not believed to be alive.
+func (n *Node) AllMembers() []*Member {
+	full := n.view.Full()
+
+	ret := make([]*Member, 0, len(full))
+
+	for _, p := range full {
+		ret = append(ret, n.member(p))
+	}
+
+	return ret
+}
+
+// Returns all members of the live view.
+func (n *Node) LiveMemberInfo() []*Member {
+	live := n.view.Live()
+
+	ret := make([]*Member, 0, len(live))
+
+	for _, p := range live {
+		ret = append(ret, n.member(p))
+	}
+
+	return ret
+}
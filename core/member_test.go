@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/joonnna/ifrit/core/discovery"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMember(t *testing.T) {
+	priv, err := genKeys()
+	require.NoError(t, err, "Failed to generate keys")
+
+	n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: genCert(priv, 10)}, &cryptoStub{priv: priv}, testConfig())
+	require.NoError(t, err, "Failed to create node.")
+
+	_, err = n.Member("non-existing-id")
+	require.Equal(t, ErrUnknownPeer, err, "Should return error with non-existing id.")
+
+	p, _, err := addPeer(n)
+	require.NoError(t, err, "Could not add peer.")
+
+	accuserPriv, err := genKeys()
+	require.NoError(t, err, "Failed to generate keys")
+
+	acc := discovery.NewAccusation(1, p.Id, "accuser", 3, accuserPriv)
+	p.AddTestAccusation(acc)
+
+	m, err := n.Member(p.Id)
+	require.NoError(t, err, "Should find existing member.")
+
+	require.Equal(t, p.Id, m.Id, "Wrong id.")
+	require.Equal(t, p.Addr, m.Addr, "Wrong address.")
+	require.Equal(t, p.PingAddr, m.PingAddr, "Wrong ping address.")
+	require.Equal(t, uint64(1), m.Epoch, "Wrong epoch.")
+	require.Equal(t, p.Note().Mask(), m.Mask, "Wrong mask.")
+	require.Equal(t, []uint32{3}, m.AccusedRings, "Wrong accused rings.")
+	require.True(t, m.Accused(), "Member should be accused.")
+	require.True(t, m.Live, "Member should be live.")
+	require.Equal(t, p.X509(), m.Certificate, "Wrong certificate.")
+
+	n.view.RemoveLive(p.Id)
+
+	m, err = n.Member(p.Id)
+	require.NoError(t, err, "Removed live peer should still be in full view.")
+	require.False(t, m.Live, "Member should not be live after removal.")
+
+	require.Equal(t, 1, len(n.AllMembers()), "Full view should contain removed peer.")
+	require.Zero(t, len(n.LiveMemberInfo()), "Live view should be empty.")
+}
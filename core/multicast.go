@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	// Returned by Multicast when fewer destinations than required responded successfully.
+	ErrInsufficientResponses = errors.New("Received fewer successful responses than required")
+
+	// Set on destinations which had not responded when the required number of responses arrived.
+	ErrNotCompleted = errors.New("Multicast completed before destination responded")
+)
+
+// Outcome of a single destination of a multicast.
+type MulticastResult struct {
+	Dest    string
+	Content []byte
+	Err     error
+}
+
+// Sends data to all destinations concurrently and returns one result per destination,
+// in the same order as dests.
+// If need is larger than zero, the remaining destinations are abandoned as soon as need destinations
+// responded successfully, their results carry ErrNotCompleted unless they responded in the meantime.
+// ErrInsufficientResponses is returned if fewer than need destinations responded successfully.
+// With need equal to zero Multicast waits for all destinations and never returns an error.
+func (n *Node) Multicast(ctx context.Context, dests []string, data []byte, need int) ([]*MulticastResult, error) {
+	type indexed struct {
+		idx int
+		res *MulticastResult
+	}
+
+	mctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan *indexed, len(dests))
+
+	for i, addr := range dests {
+		go func(idx int, dest string) {
+			content, err := n.SendMessageContext(mctx, dest, data)
+			ch <- &indexed{
+				idx: idx,
+				res: &MulticastResult{Dest: dest, Content: content, Err: err},
+			}
+		}(i, addr)
+	}
+
+	results := make([]*MulticastResult, len(dests))
+	successes := 0
+	completed := false
+
+	for range dests {
+		r := <-ch
+
+		// Failures after completion stem from abandoning the destination.
+		if completed && r.res.Err != nil {
+			r.res.Err = ErrNotCompleted
+		} else if r.res.Err == nil {
+			successes++
+			if need > 0 && successes >= need {
+				completed = true
+				cancel()
+			}
+		}
+
+		results[r.idx] = r.res
+	}
+
+	if need > 0 && successes < need {
+		return results, ErrInsufficientResponses
+	}
+
+	return results, nil
+}
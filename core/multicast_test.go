@@ -0,0 +1,75 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Responds with the destination address, fails on "fail" and blocks on "slow" until cancelled.
+type multicastCommStub struct {
+	commStub
+}
+
+func (cs *multicastCommStub) Send(ctx context.Context, addr string, m *pb.Msg) (*pb.MsgResponse, error) {
+	switch addr {
+	case "fail":
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	case "slow":
+		<-ctx.Done()
+		return nil, status.Error(codes.Canceled, "cancelled")
+	default:
+		return &pb.MsgResponse{Content: []byte(addr)}, nil
+	}
+}
+
+func multicastNode(t *testing.T) *Node {
+	priv, err := genKeys()
+	require.NoError(t, err, "Failed to generate keys")
+
+	conf := testConfig()
+	conf.MaxConcurrentMessages = 10
+
+	n, err := NewNode(&multicastCommStub{}, &pingStub{}, &cmStub{cert: genCert(priv, 10)}, &cryptoStub{priv: priv}, conf)
+	require.NoError(t, err, "Failed to create node.")
+
+	n.dispatcher.Start()
+
+	return n
+}
+
+func TestMulticast(t *testing.T) {
+	n := multicastNode(t)
+	defer n.dispatcher.Stop()
+
+	ctx := context.Background()
+
+	res, err := n.Multicast(ctx, []string{"a", "fail", "b"}, []byte("data"), 0)
+	require.NoError(t, err, "Should not return error without required responses.")
+	require.Equal(t, 3, len(res), "Should return one result per destination.")
+
+	require.Equal(t, "a", res[0].Dest, "Results should be ordered as destinations.")
+	require.Equal(t, []byte("a"), res[0].Content, "Wrong response content.")
+	require.True(t, errors.Is(res[1].Err, ErrTransport), "Failed destination should carry transport error.")
+	require.Equal(t, []byte("b"), res[2].Content, "Wrong response content.")
+
+	res, err = n.Multicast(ctx, []string{"a", "slow", "b"}, []byte("data"), 2)
+	require.NoError(t, err, "Should complete after required responses.")
+	require.Equal(t, ErrNotCompleted, res[1].Err, "Abandoned destination should not be completed.")
+
+	_, err = n.Multicast(ctx, []string{"a", "fail", "fail"}, []byte("data"), 2)
+	require.Equal(t, ErrInsufficientResponses, err, "Should fail without enough successful responses.")
+
+	tCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+
+	res, err = n.Multicast(tCtx, []string{"a", "slow"}, []byte("data"), 2)
+	require.Equal(t, ErrInsufficientResponses, err, "Should fail when deadline expires.")
+	require.Equal(t, ErrDeadlineExceeded, res[1].Err, "Slow destination should exceed deadline.")
+}
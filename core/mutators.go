@@ -16,22 +16,31 @@ func (n *Node) collectGossipContent() *proto.State {
 	msg := n.view.State()
 
 	msg.ExternalGossip = n.getExternalGossip()
+	msg.TopicGossip = n.topicGossipContent()
+	msg.GossipDigest = n.gossipDigest()
+	msg.Rumors = n.collectRumors()
 
 	return msg
 }
 
-func (n *Node) setProtocol(pr protocol) {
-	n.protocolMutex.Lock()
-	defer n.protocolMutex.Unlock()
+// Replaces the behavior of the node, nil restores the correct behavior.
+// Intended for testing applications against misbehaving nodes.
+func (n *Node) SetBehavior(b Behavior) {
+	n.behaviorMutex.Lock()
+	defer n.behaviorMutex.Unlock()
 
-	n.p = pr
+	if b == nil {
+		b = correct{}
+	}
+
+	n.behavior = b
 }
 
-func (n *Node) protocol() protocol {
-	n.protocolMutex.RLock()
-	defer n.protocolMutex.RUnlock()
+func (n *Node) getBehavior() Behavior {
+	n.behaviorMutex.RLock()
+	defer n.behaviorMutex.RUnlock()
 
-	return n.p
+	return n.behavior
 }
 
 func (n *Node) setGossipTimeout(timeout int) {
@@ -94,14 +103,14 @@ func (n *Node) getGossipHandler() processMsg {
 }
 
 // Expose so that client can set new handler directly
-func (n *Node) SetResponseHandler(newHandler func([]byte)) {
+func (n *Node) SetResponseHandler(newHandler processResponse) {
 	n.responseHandlerMutex.Lock()
 	defer n.responseHandlerMutex.Unlock()
 
 	n.responseHandler = newHandler
 }
 
-func (n *Node) getResponseHandler() func([]byte) {
+func (n *Node) getResponseHandler() processResponse {
 	n.responseHandlerMutex.RLock()
 	defer n.responseHandlerMutex.RUnlock()
 
@@ -4,6 +4,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -11,7 +12,14 @@ import (
 	"github.com/joonnna/ifrit/core/discovery"
 	pb "github.com/joonnna/ifrit/protobuf"
 	"github.com/joonnna/workerpool"
-	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Upper bound on how long shutdown waits for neighbours to receive the leaving note.
+	leaveTimeout = time.Second * 3
 )
 
 var (
@@ -21,15 +29,78 @@ var (
 	errNoEntryAddrs = errors.New("No entry_addrs set in config with use_ca disabled")
 )
 
-type processMsg func([]byte) ([]byte, error)
-type streamMsg func(chan []byte, chan []byte)
+var (
+	// Returned when no observed peer has the specified id.
+	ErrUnknownPeer = errors.New("Could not find peer with specified id")
+
+	// Returned when the destination could not be reached.
+	ErrTransport = errors.New("Failed to reach destination")
+
+	// Returned when the deadline of the context expired before a response arrived.
+	ErrDeadlineExceeded = errors.New("Deadline exceeded before receiving a response")
+
+	// Returned when the message handler of the destination returned an error.
+	ErrRemoteHandler = errors.New("Remote message handler returned an error")
+
+	// Returned when the destination has no message handler registered for the topic.
+	ErrUnknownTopic = errors.New("Destination has no handler registered for topic")
+)
+
+// Config holds the parameters of a node.
+type Config struct {
+	GossipInterval     time.Duration
+	MonitorInterval    time.Duration
+	ViewUpdateInterval time.Duration
+	RemovalTimeout     time.Duration
+
+	PingLimit        uint32
+	PingsPerInterval int
+
+	// Number of ring neighbours asked to ping a peer that failed PingLimit consecutive pings,
+	// before it is accused. Zero disables indirect probes.
+	IndirectProbes int
+
+	// Suspicion level at which peers failing to answer pings are considered dead, see Suspicions.
+	// Zero considers peers dead after PingLimit consecutive failed pings,
+	// as does a non-zero threshold until enough pongs have arrived from the peer.
+	PhiThreshold float64
+
+	// How long the ping service waits for pongs, bounds indirect probes as well.
+	PingTimeout time.Duration
+
+	MaxConcurrentMessages uint32
+
+	// Only contacted when no CA is used.
+	EntryAddrs []string
+
+	// Epoch of the first local note, a resumed identity must start above the last epoch it announced.
+	Epoch uint64
+
+	// Invoked each time the local note is re-issued, used to persist the epoch.
+	SaveEpoch func(uint64) error
+
+	// How long leases granted by group leaders last, see Leader.
+	LeaseDuration time.Duration
+
+	// Drives the gossip, monitor and view update loops, the real clock if nil.
+	Clock discovery.Clock
+
+	// Visualizer specific
+	UseViz            bool
+	VizAddr           string
+	VizUpdateInterval time.Duration
+}
+
+type processMsg func(*Request) ([]byte, error)
+type processResponse func(*Request)
+type streamMsg func(*Request, chan []byte, chan []byte)
 
 type Node struct {
 	view *discovery.View
 	self *discovery.Peer
 
-	p             protocol
-	protocolMutex sync.RWMutex
+	behavior      Behavior
+	behaviorMutex sync.RWMutex
 
 	wg       *sync.WaitGroup
 	exitChan chan bool
@@ -43,6 +114,8 @@ type Node struct {
 	gossipTimeoutMutex sync.RWMutex
 
 	pingsPerInterval int
+	indirectProbes   int
+	pingTimeout      time.Duration
 	monitorTimeout   time.Duration
 	nodeDeadTimeout  float64
 
@@ -52,7 +125,7 @@ type Node struct {
 	gossipHandler      processMsg
 	gossipHandlerMutex sync.RWMutex
 
-	responseHandler      func([]byte) 
+	responseHandler      processResponse
 	responseHandlerMutex sync.RWMutex
 
 	externalGossip      []byte
@@ -61,12 +134,24 @@ type Node struct {
 	streamHandler 		streamMsg
 	streamHandlerMutex 	sync.RWMutex
 
+	topics *topicRegistry
+	store  *gossipStore
+	rumors *rumorBuffer
+	rbc    *reliableBroadcast
+	pubsub *pubsub
+	leases *leaseState
+	owners *ownershipWatches
+
 	dispatcher *workerpool.Dispatcher
 
 	entryAddrs []string
 
+	saveEpoch func(uint64) error
+
 	fd *failureDetector
 
+	clock discovery.Clock
+
 	comm commService
 	cs   cryptoService
 	cm   certManager
@@ -83,7 +168,7 @@ type commService interface {
 	Stop()
 
 	Gossip(string, *pb.State) (*pb.StateResponse, error)
-	Send(string, *pb.Msg) (*pb.MsgResponse, error)
+	Send(context.Context, string, *pb.Msg) (*pb.MsgResponse, error)
 	StreamMessenger(string, chan []byte, chan []byte) error
 }
 
@@ -100,12 +185,6 @@ type cryptoService interface {
 	Sign([]byte) ([]byte, []byte, error)
 }
 
-type protocol interface {
-	Monitor(n *Node)
-	Gossip(n *Node)
-	Rebuttal(n *Node)
-}
-
 func (n *Node) gossipLoop() {
 	defer n.wg.Done()
 
@@ -114,8 +193,8 @@ func (n *Node) gossipLoop() {
 		case <-n.exitChan:
 			log.Info("Exiting gossiping")
 			return
-		case <-time.After(n.getGossipTimeout()):
-			n.protocol().Gossip(n)
+		case <-n.clock.After(n.getGossipTimeout()):
+			n.GossipRound()
 		}
 	}
 }
@@ -123,27 +202,71 @@ func (n *Node) gossipLoop() {
 func (n *Node) monitorLoop() {
 	defer n.wg.Done()
 
+	events, cancel := n.view.Subscribe()
+	defer cancel()
+
+	// Renewed only once a round ran, events must not postpone the next round.
+	round := n.clock.After(n.monitorTimeout)
+
 	for {
 		select {
 		case <-n.exitChan:
 			log.Info("Stopping monitoring")
 			return
-		case <-time.After(n.monitorTimeout):
-			n.protocol().Monitor(n)
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			switch e.Type {
+			case discovery.PeerRemoved, discovery.PeerLeft:
+				n.fd.forget(e.Id)
+			}
+		case <-round:
+			n.MonitorRound()
+			round = n.clock.After(n.monitorTimeout)
 		}
 	}
 }
 
-func NewNode(comm commService, ps pingService, cm certManager, cs cryptoService) (*Node, error) {
+// Gossips with all gossip partners once, invoked periodically by Start.
+// Exported to let simulations drive nodes without running their loops.
+func (n *Node) GossipRound() {
+	n.getBehavior().Gossip(n)
+}
+
+// Probes the monitor targets once, invoked periodically by Start.
+func (n *Node) MonitorRound() {
+	n.getBehavior().Monitor(n)
+}
+
+// Removes accused peers whose removal timeout expired, invoked periodically by Start.
+func (n *Node) CheckTimeouts() {
+	n.view.CheckTimeouts()
+}
+
+func NewNode(comm commService, ps pingService, cm certManager, cs cryptoService, conf *Config) (*Node, error) {
 	var perInterval int
 
-	v, err := discovery.NewView(cm.NumRings(), cm.Certificate(), comm, cs)
+	v, err := discovery.NewView(cm.NumRings(), cm.Certificate(), comm, cs, conf.RemovalTimeout, conf.ViewUpdateInterval, conf.Epoch)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
 	}
 
-	num := viper.GetInt("pings_per_interval")
+	clock := conf.Clock
+	if clock == nil {
+		clock = discovery.RealClock()
+	}
+
+	v.SetClock(clock)
+
+	pingTimeout := conf.PingTimeout
+	if pingTimeout == 0 {
+		pingTimeout = defaultPingTimeout
+	}
+
+	num := conf.PingsPerInterval
 	if num == 0 {
 		perInterval = 1
 	} else if rings := int(v.NumRings()); num > rings {
@@ -153,36 +276,50 @@ func NewNode(comm commService, ps pingService, cm certManager, cs cryptoService)
 	}
 
 	n := &Node{
-		exitChan:       make(chan bool, 1),
-		wg:             &sync.WaitGroup{},
-		gossipTimeout:  time.Second * time.Duration(viper.GetInt32("gossip_interval")),
-		monitorTimeout: time.Second * time.Duration(viper.GetInt32("monitor_interval")),
-		dispatcher: workerpool.NewDispatcher(uint32(viper.
-			GetInt32("max_concurrent_messages"))),
-		entryAddrs:       viper.GetStringSlice("entry_addrs"),
-		p:                correct{},
+		exitChan:         make(chan bool, 1),
+		wg:               &sync.WaitGroup{},
+		gossipTimeout:    conf.GossipInterval,
+		monitorTimeout:   conf.MonitorInterval,
+		dispatcher:       workerpool.NewDispatcher(conf.MaxConcurrentMessages),
+		topics:           newTopicRegistry(),
+		store:            newGossipStore(),
+		rumors:           newRumorBuffer(),
+		rbc:              newReliableBroadcast(),
+		pubsub:           newPubsub(),
+		leases:           newLeaseState(conf.LeaseDuration),
+		owners:           newOwnershipWatches(),
+		entryAddrs:       conf.EntryAddrs,
+		saveEpoch:        conf.SaveEpoch,
+		behavior:         correct{},
 		pingsPerInterval: perInterval,
+		indirectProbes:   conf.IndirectProbes,
+		pingTimeout:      pingTimeout,
 
-		fd:   newFd(ps, cs, uint32(viper.GetInt32("ping_limit"))),
-		cm:   cm,
-		cs:   cs,
-		comm: comm,
-		self: v.Self(),
-		view: v,
+		fd:    newFd(ps, cs, conf.PingLimit, conf.PhiThreshold, clock),
+		clock: clock,
+		cm:    cm,
+		cs:    cs,
+		comm:  comm,
+		self:  v.Self(),
+		view:  v,
 
 		// Visualizer specific
-		useViz: viper.GetBool("use_viz"),
+		useViz: conf.UseViz,
 	}
 
 	if n.useViz {
-		interval := time.Second * time.Duration(viper.GetInt32("viz_update_interval"))
-		viz, err := newViz(n, viper.GetString("viz_addr"), interval, cm.Trusted())
+		viz, err := newViz(n, conf.VizAddr, conf.VizUpdateInterval, cm.Trusted())
 		if err != nil {
 			return nil, err
 		}
 		n.viz = viz
 	}
 
+	n.SetTopicMsgHandler(rbcTopic, n.handleRbc)
+	n.SetTopicMsgHandler(pubsubTopic, n.handlePublication)
+	n.SetTopicMsgHandler(leaseTopic, n.handleLeaseRequest)
+	n.SetTopicMsgHandler(pingReqTopic, n.handlePingRequest)
+
 	n.comm.Register(n)
 
 	if n.cm.CaCertificate() != nil {
@@ -212,8 +349,41 @@ func (n *Node) SendMessage(dest string, ch chan []byte, data []byte) {
 	}
 
 	n.dispatcher.Submit(func() {
-		n.sendMsg(dest, ch, msg)
+		ch <- n.sendMsgToChan(dest, msg)
+	})
+}
+
+// Sends the given data to dest and blocks until a response arrives,
+// the context is done or the message fails.
+// Errors are one of ErrTransport, ErrDeadlineExceeded, ErrRemoteHandler
+// or the context error if the context was cancelled.
+func (n *Node) SendMessageContext(ctx context.Context, dest string, data []byte) ([]byte, error) {
+	msg := &pb.Msg{
+		Content: data,
+	}
+
+	return n.sendMsgContext(ctx, dest, msg)
+}
+
+func (n *Node) sendMsgContext(ctx context.Context, dest string, msg *pb.Msg) ([]byte, error) {
+	type result struct {
+		content []byte
+		err     error
+	}
+
+	ch := make(chan *result, 1)
+
+	n.dispatcher.Submit(func() {
+		content, err := n.sendMsg(ctx, dest, msg)
+		ch <- &result{content: content, err: err}
 	})
+
+	select {
+	case r := <-ch:
+		return r.content, r.err
+	case <-ctx.Done():
+		return nil, sendError(ctx, ctx.Err())
+	}
 }
 
 func (n *Node) Sign(content []byte) ([]byte, []byte, error) {
@@ -226,6 +396,10 @@ func (n *Node) Sign(content []byte) ([]byte, []byte, error) {
 }
 
 func (n *Node) Verify(r, s, content []byte, id string) bool {
+	if id == n.self.Id {
+		return n.cs.Verify(content, r, s, n.self.PublicKey())
+	}
+
 	p := n.view.Peer(id)
 	if p == nil {
 		return false
@@ -237,7 +411,7 @@ func (n *Node) Verify(r, s, content []byte, id string) bool {
 func (n *Node) IdToAddr(id []byte) (string, error) {
 	p := n.view.Peer(string(id))
 	if p == nil {
-		return "", errors.New("Could not find peer with specified id")
+		return "", ErrUnknownPeer
 	}
 
 	return p.Addr, nil
@@ -251,7 +425,7 @@ func (n *Node) SendMessages(dest []string, ch chan []byte, data []byte) {
 	for _, addr := range dest {
 		a := addr
 		n.dispatcher.Submit(func() {
-			n.sendMsg(a, ch, msg)
+			ch <- n.sendMsgToChan(a, msg)
 		})
 	}
 }
@@ -272,13 +446,52 @@ func (n *Node) openStream(dest string, input, reply chan []byte) {
 	}
 }
 
-func (n *Node) sendMsg(dest string, ch chan []byte, msg *pb.Msg) {
-	reply, err := n.comm.Send(dest, msg)
+func (n *Node) sendMsg(ctx context.Context, dest string, msg *pb.Msg) ([]byte, error) {
+	reply, err := n.comm.Send(ctx, dest, msg)
 	if err != nil {
-		log.Error(err.Error())
-		ch <- nil
+		return nil, sendError(ctx, err)
+	}
+
+	return reply.GetContent(), nil
+}
+
+// Legacy channel semantics, nil is returned on any failure.
+func (n *Node) sendMsgToChan(dest string, msg *pb.Msg) []byte {
+	content, err := n.sendMsg(context.Background(), dest, msg)
+	if err != nil {
+		log.Error(err.Error(), "addr", dest)
+		return nil
+	}
+
+	return content
+}
+
+// Translates errors from the comm layer into the exported error values.
+func sendError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return ErrDeadlineExceeded
+	case context.Canceled:
+		return context.Canceled
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTransport, err.Error())
+	}
+
+	switch s.Code() {
+	case codes.DeadlineExceeded:
+		return ErrDeadlineExceeded
+	case codes.Canceled:
+		return context.Canceled
+	case codes.Unknown:
+		return fmt.Errorf("%w: %s", ErrRemoteHandler, s.Message())
+	case codes.Unimplemented:
+		return fmt.Errorf("%w: %s", ErrUnknownTopic, s.Message())
+	default:
+		return fmt.Errorf("%w: %s", ErrTransport, s.Message())
 	}
-	ch <- reply.GetContent()
 }
 
 func (n *Node) isStopping() bool {
@@ -300,6 +513,8 @@ func (n *Node) Stop() {
 		return
 	}
 
+	n.announceLeave()
+
 	if n.useViz {
 		n.viz.stop()
 	}
@@ -311,6 +526,58 @@ func (n *Node) Stop() {
 	n.wg.Wait()
 }
 
+// Issues a leaving note and gossips it to all ring neighbours, they remove
+// the node from their live view instead of waiting for the failure detector.
+// The note spreads to the remaining members through regular gossip.
+func (n *Node) announceLeave() {
+	if err := n.view.Leave(); err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	n.persistEpoch()
+
+	msg := &pb.State{
+		OwnNote: n.self.Note().ToPbMsg(),
+	}
+
+	wg := &sync.WaitGroup{}
+	done := make(chan struct{})
+
+	for _, p := range n.view.MyNeighbours() {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+
+			if _, err := n.comm.Gossip(addr, msg); err != nil {
+				log.Debug(err.Error(), "addr", addr)
+			}
+		}(p.Addr)
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(leaveTimeout):
+		log.Debug("Timed out announcing leave")
+	}
+}
+
+// Persists the epoch of the local note if a persistence hook is configured.
+func (n *Node) persistEpoch() {
+	if n.saveEpoch == nil {
+		return
+	}
+
+	if err := n.saveEpoch(n.self.Note().Epoch()); err != nil {
+		log.Error(err.Error())
+	}
+}
+
 func (n *Node) LiveMembers() []string {
 	live := n.view.Live()
 
@@ -323,6 +590,26 @@ func (n *Node) LiveMembers() []string {
 	return ret
 }
 
+// Returns the suspicion level of the live members monitored by the local node, indexed by their ifrit id.
+// Members are omitted until enough pongs have arrived from them.
+func (n *Node) Suspicions() map[string]float64 {
+	ret := make(map[string]float64)
+
+	for _, p := range n.view.Live() {
+		if phi, ok := n.fd.suspicion(p.Id); ok {
+			ret[p.Id] = phi
+		}
+	}
+
+	return ret
+}
+
+// Returns a channel populated with all subsequent membership events
+// and a function cancelling the subscription.
+func (n *Node) Subscribe() (<-chan discovery.Event, func()) {
+	return n.view.Subscribe()
+}
+
 func (n *Node) HttpAddr() string {
 	return n.self.HttpAddr
 }
@@ -335,16 +622,25 @@ func (n *Node) Addr() string {
 	return n.comm.Addr()
 }
 
+func (n *Node) CaCertificate() *x509.Certificate {
+	return n.cm.CaCertificate()
+}
+
 func (n *Node) Start() {
 	log.Info("Started Node")
 
+	n.persistEpoch()
+
 	go n.fd.start()
 	go n.comm.Start()
 	go n.view.Start()
 
-	n.wg.Add(2)
+	n.wg.Add(5)
 	go n.gossipLoop()
 	go n.monitorLoop()
+	go n.ownershipLoop()
+	go n.leaseLoop()
+	go n.rbcLoop()
 
 	n.dispatcher.Start()
 
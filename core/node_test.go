@@ -5,16 +5,18 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"math/big"
 	"os"
 	"testing"
 	"time"
 
 	log "github.com/inconshreveable/log15"
-	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/joonnna/ifrit/protobuf"
 )
@@ -32,9 +34,6 @@ func TestNodeTestSuite(t *testing.T) {
 
 	r.SetHandler(log.CallerFileHandler(log.StreamHandler(os.Stdout, log.TerminalFormat())))
 
-	viper.Set("use_ca", false)
-	viper.Set("use_viz", false)
-
 	suite.Run(t, new(NodeTestSuite))
 }
 
@@ -45,7 +44,7 @@ func (suite *NodeTestSuite) SetupTest() {
 		require.NoError(suite.T(), err, "Failed to generate keys")
 
 		ownCert := genCert(priv, 10)
-		n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: ownCert}, &cryptoStub{priv: priv})
+		n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: ownCert}, &cryptoStub{priv: priv}, testConfig())
 		require.NoError(suite.T(), err, "Failed to create node.")
 
 		suite.nodes = append(suite.nodes, n)
@@ -76,6 +75,95 @@ func (suite *NodeTestSuite) TestGossip() {
 
 }
 
+func testConfig() *Config {
+	return &Config{
+		GossipInterval:        time.Second * 10,
+		MonitorInterval:       time.Second * 10,
+		ViewUpdateInterval:    time.Second * 10,
+		RemovalTimeout:        time.Second * 60,
+		PingLimit:             3,
+		PingsPerInterval:      3,
+		MaxConcurrentMessages: 5,
+	}
+}
+
+func TestPersistEpoch(t *testing.T) {
+	var saved []uint64
+
+	priv, err := genKeys()
+	require.NoError(t, err, "Failed to generate keys")
+
+	conf := testConfig()
+	conf.Epoch = 4
+	conf.SaveEpoch = func(epoch uint64) error {
+		saved = append(saved, epoch)
+		return nil
+	}
+
+	n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: genCert(priv, 10)}, &cryptoStub{priv: priv}, conf)
+	require.NoError(t, err, "Failed to create node.")
+	require.Equal(t, uint64(4), n.self.Note().Epoch(), "Local note should start at configured epoch.")
+
+	n.persistEpoch()
+	require.Equal(t, []uint64{4}, saved, "Epoch was not persisted.")
+
+	require.True(t, n.view.ShouldRebuttal(4, 1), "Should rebut accusation on current epoch.")
+	n.persistEpoch()
+	require.Equal(t, []uint64{4, 5}, saved, "Re-issued epoch was not persisted.")
+}
+
+func TestAnnounceLeave(t *testing.T) {
+	var saved []uint64
+
+	priv, err := genKeys()
+	require.NoError(t, err, "Failed to generate keys")
+
+	conf := testConfig()
+	conf.SaveEpoch = func(epoch uint64) error {
+		saved = append(saved, epoch)
+		return nil
+	}
+
+	n, err := NewNode(&commStub{}, &pingStub{}, &cmStub{cert: genCert(priv, 10)}, &cryptoStub{priv: priv}, conf)
+	require.NoError(t, err, "Failed to create node.")
+
+	_, _, err = addPeer(n)
+	require.NoError(t, err, "Could not add peer.")
+
+	epoch := n.self.Note().Epoch()
+
+	n.announceLeave()
+
+	require.True(t, n.self.Note().IsLeaving(), "Local note not flagged as leaving.")
+	require.Equal(t, epoch+1, n.self.Note().Epoch(), "Leaving note should increment epoch.")
+	require.Equal(t, []uint64{epoch + 1}, saved, "Leaving epoch was not persisted.")
+}
+
+func TestSendError(t *testing.T) {
+	bg := context.Background()
+
+	err := sendError(bg, errors.New("dial failed"))
+	require.True(t, errors.Is(err, ErrTransport), "Non-status errors are transport errors.")
+
+	err = sendError(bg, status.Error(codes.Unavailable, "unavailable"))
+	require.True(t, errors.Is(err, ErrTransport), "Unavailable should be a transport error.")
+
+	err = sendError(bg, status.Error(codes.Unknown, "handler failed"))
+	require.True(t, errors.Is(err, ErrRemoteHandler), "Unknown code should be a handler error.")
+
+	err = sendError(bg, status.Error(codes.Unimplemented, "kv.put"))
+	require.True(t, errors.Is(err, ErrUnknownTopic), "Unimplemented code should be an unknown topic error.")
+
+	err = sendError(bg, status.Error(codes.DeadlineExceeded, "deadline"))
+	require.Equal(t, ErrDeadlineExceeded, err, "Should return deadline error.")
+
+	ctx, cancel := context.WithCancel(bg)
+	cancel()
+
+	err = sendError(ctx, status.Error(codes.Unavailable, "unavailable"))
+	require.Equal(t, context.Canceled, err, "Cancelled context should take precedence.")
+}
+
 type clientStub struct {
 }
 
@@ -134,10 +222,14 @@ func (cs *commStub) Gossip(addr string, m *pb.State) (*pb.StateResponse, error)
 	return &pb.StateResponse{}, nil
 }
 
-func (cs *commStub) Send(addr string, m *pb.Msg) (*pb.MsgResponse, error) {
+func (cs *commStub) Send(ctx context.Context, addr string, m *pb.Msg) (*pb.MsgResponse, error) {
 	return &pb.MsgResponse{}, nil
 }
 
+func (cs *commStub) StreamMessenger(addr string, input, reply chan []byte) error {
+	return nil
+}
+
 type pingStub struct {
 }
 
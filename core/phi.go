@@ -0,0 +1,104 @@
+package core
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Number of inter-arrival times kept per peer.
+	phiWindowSize = 100
+
+	// Inter-arrival times required before the suspicion level of a peer is used.
+	phiMinSamples = 3
+
+	// Lower bound of the standard deviation relative to the mean,
+	// peers answering at perfectly regular intervals are otherwise suspected on the slightest delay.
+	phiMinStdDevRatio = 0.25
+)
+
+// Arrival history of the pongs of a single peer, suspicion is computed following
+// the phi accrual failure detector (Hayashibara et al.), assuming normally distributed inter-arrival times.
+type arrivals struct {
+	intervals []time.Duration
+	next      int
+
+	last time.Time
+}
+
+func newArrivals() *arrivals {
+	return &arrivals{
+		intervals: make([]time.Duration, 0, phiWindowSize),
+	}
+}
+
+// Records the arrival of a pong.
+func (a *arrivals) add(now time.Time) {
+	if !a.last.IsZero() {
+		interval := now.Sub(a.last)
+
+		if len(a.intervals) < phiWindowSize {
+			a.intervals = append(a.intervals, interval)
+		} else {
+			a.intervals[a.next] = interval
+			a.next = (a.next + 1) % phiWindowSize
+		}
+	}
+
+	a.last = now
+}
+
+// Records the first failed ping after an arrival.
+// Gaps in monitoring, e.g when the peer was not our ring successor for a while,
+// are not held against the peer: the failure is treated as if it happened one mean interval after the last arrival.
+func (a *arrivals) failed(now time.Time) {
+	if !a.ready() {
+		return
+	}
+
+	mean, _ := a.stats()
+
+	if expected := now.Add(-mean); expected.After(a.last) {
+		a.last = expected
+	}
+}
+
+func (a *arrivals) ready() bool {
+	return len(a.intervals) >= phiMinSamples
+}
+
+// Returns the mean and standard deviation of the inter-arrival times.
+func (a *arrivals) stats() (time.Duration, time.Duration) {
+	var sum, squares float64
+
+	for _, i := range a.intervals {
+		sum += float64(i)
+	}
+
+	mean := sum / float64(len(a.intervals))
+
+	for _, i := range a.intervals {
+		diff := float64(i) - mean
+		squares += diff * diff
+	}
+
+	stdDev := math.Sqrt(squares / float64(len(a.intervals)))
+
+	return time.Duration(mean), time.Duration(math.Max(stdDev, mean*phiMinStdDevRatio))
+}
+
+// Returns the suspicion level at the given time, -log10 of the probability
+// that a pong arrives this late or later. A phi of 1 means a 10% chance of a false suspicion, 2 a 1% chance, etc.
+func (a *arrivals) phi(now time.Time) float64 {
+	mean, stdDev := a.stats()
+
+	if stdDev <= 0 {
+		return 0
+	}
+
+	y := float64(now.Sub(a.last)-mean) / float64(stdDev)
+
+	p := 0.5 * math.Erfc(y/math.Sqrt2)
+
+	return -math.Log10(math.Max(p, math.SmallestNonzeroFloat64))
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrivals(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	a := newArrivals()
+
+	for i := 0; i < phiMinSamples; i++ {
+		require.False(t, a.ready(), "Too few samples to compute suspicion.")
+		a.add(start.Add(time.Second * time.Duration(i*10)))
+	}
+
+	a.add(start.Add(time.Second * 30))
+	require.True(t, a.ready(), "Enough samples to compute suspicion.")
+
+	mean, stdDev := a.stats()
+	require.Equal(t, time.Second*10, mean, "Invalid mean.")
+	require.Equal(t, time.Millisecond*2500, stdDev, "Regular arrivals should use the minimum deviation.")
+
+	last := start.Add(time.Second * 30)
+
+	require.InDelta(t, 0.3, a.phi(last.Add(time.Second*10)), 0.01, "Pong arriving on time should barely be suspected.")
+	require.True(t, a.phi(last.Add(time.Second*20)) > a.phi(last.Add(time.Second*15)), "Suspicion should grow over time.")
+	require.True(t, a.phi(last.Add(time.Hour)) > 300, "Suspicion should be bounded.")
+
+	// Not monitored for a long time, the first failure is treated as on time.
+	a.failed(last.Add(time.Hour))
+	require.InDelta(t, 0.3, a.phi(last.Add(time.Hour)), 0.01, "Gap in monitoring should not be suspected.")
+
+	for i := 0; i < phiWindowSize*2; i++ {
+		a.add(last.Add(time.Hour + time.Second*time.Duration(i+1)))
+	}
+
+	require.Len(t, a.intervals, phiWindowSize, "Window should be bounded.")
+
+	mean, _ = a.stats()
+	require.Equal(t, time.Second, mean, "Old intervals should be replaced.")
+}
+
+func (suite *HandlerTestSuite) TestPhiThreshold() {
+	node := suite.n
+
+	c := &testClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+
+	p := node.view.Live()[0]
+	ps := &pongStub{priv: suite.privMap[p.Id]}
+	node.fd = newFd(ps, node.cs, 1, 8, c)
+
+	for i := 0; i <= phiMinSamples; i++ {
+		require.NoError(suite.T(), node.fd.probe(p), "Valid pong was rejected.")
+		c.advance(time.Second * 10)
+	}
+
+	require.Contains(suite.T(), node.Suspicions(), p.Id, "Suspicion should be available.")
+
+	ps.err = errPingStub
+
+	// A single failed ping is not enough despite the ping limit.
+	require.Equal(suite.T(), errPingStub, node.fd.probe(p), "Peer should only be suspected.")
+
+	c.advance(time.Second * 10)
+	require.Equal(suite.T(), errPingStub, node.fd.probe(p), "Peer should only be suspected.")
+
+	c.advance(time.Second * 10)
+	require.Equal(suite.T(), errDead, node.fd.probe(p), "Peer should be dead after exceeding the threshold.")
+
+	node.fd.forget(p.Id)
+	require.NotContains(suite.T(), node.Suspicions(), p.Id, "Suspicion should be dropped with the peer.")
+}
+
+// Clock only advanced by the test.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
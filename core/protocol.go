@@ -4,61 +4,44 @@ import (
 	log "github.com/inconshreveable/log15"
 	"github.com/joonnna/ifrit/core/discovery"
 	pb "github.com/joonnna/ifrit/protobuf"
+	"golang.org/x/net/context"
 )
 
-type correct struct {
-}
+// Behavior decides how a node gossips, monitors its ring neighbours and responds to accusations.
+// Nodes behave correctly by default, see SetBehavior and the adversaries of this package.
+type Behavior interface {
+	// Invoked each gossip interval.
+	Gossip(n *Node)
 
-type spamAccusations struct {
-}
+	// Invoked each monitor interval.
+	Monitor(n *Node)
 
-type experiment struct {
-	addr    string
-	maxConc int
+	// Invoked when a valid accusation against the local note with the given epoch is received.
+	Rebuttal(n *Node, epoch uint64, ringNum uint32)
+
+	// Invoked with the reply to gossip from the node with the given id, before it is sent.
+	Reply(n *Node, id string, reply *pb.StateResponse)
 }
 
-func (c correct) Rebuttal(n *Node) {
-	neighbours := n.view.GossipPartners()
+type correct struct {
+}
 
-	noteMsg := n.self.Note().ToPbMsg()
+// Returns the behavior of correct nodes, custom behaviors can embed it and override single methods.
+func Correct() Behavior {
+	return correct{}
+}
 
-	msg := &pb.State{
-		OwnNote: noteMsg,
+func (c correct) Rebuttal(n *Node, epoch uint64, ringNum uint32) {
+	if rebut := n.view.ShouldRebuttal(epoch, ringNum); !rebut {
+		return
 	}
 
-	for _, p := range neighbours {
-		_, err := n.comm.Gossip(p.Addr, msg)
-		if err != nil {
-			log.Error(err.Error(), "addr", p.Addr)
-			continue
-		}
-	}
+	n.persistEpoch()
+	n.sendNote(n.view.GossipPartners(), n.self.Note().ToPbMsg())
 }
 
 func (c correct) Gossip(n *Node) {
-	msg := n.collectGossipContent()
-
-	neighbours := n.view.GossipPartners()
-
-	for _, p := range neighbours {
-		reply, err := n.comm.Gossip(p.Addr, msg)
-		if err != nil {
-			log.Error(err.Error(), "addr", p.Addr)
-			continue
-		}
-
-		//log.Debug("Gossiped", "addr", p.Addr)
-
-		n.mergeCertificates(reply.GetCertificates())
-		n.mergeNotes(reply.GetNotes())
-		n.mergeAccusations(reply.GetAccusations())
-
-		if handler := n.getResponseHandler(); handler != nil {
-			if r := reply.GetExternalGossip(); r != nil {
-				handler(r)
-			}
-		}
-	}
+	n.gossipWith(n.view.GossipPartners(), n.collectGossipContent())
 }
 
 func (c correct) Monitor(n *Node) {
@@ -68,305 +51,88 @@ func (c correct) Monitor(n *Node) {
 			continue
 		}
 
-		err := n.fd.probe(p)
+		err := n.probe(p)
 		if err == errDead {
 			log.Debug("Successor dead, accusing", "succ", p.Addr, "ringNum", ringNum)
-			peerNote := p.Note()
-
-			// Will always have note for a peer in our liveView, except when the peer stems
-			// from the initial contact list of the CA, if it's dead
-			// we should remove it to ensure it doesn't stay in our liveView.
-			// Not possible to accuse a peer without a note.
-			if peerNote == nil {
-				n.view.RemoveLive(p.Id)
-				log.Debug("Removing live peer due to not having note and being accused",
-					"addr", p.Addr)
-				continue
-			}
-
-			err := p.CreateAccusation(peerNote, n.self, ringNum, n.cs)
-			if err == discovery.ErrAccAlreadyExists || err == nil {
-				live := n.view.IsAlive(p.Id)
-				if exists := n.view.HasTimer(p.Id); !exists && live {
-					n.view.StartTimer(p, peerNote, n.self)
-				}
-			} else {
-				log.Error(err.Error())
-			}
+			n.accuse(p, ringNum)
 		}
 	}
 }
 
-/*
-func (sa spamAccusations) Gossip(n *Node) {
-	msg, err := createFalseAccusations(n)
-	if err != nil {
-		log.Error(err.Error())
-		return
-	}
-
-	allNodes := n.getView()
-
-	for _, p := range allNodes {
-		reply, err := n.client.Gossip(p.addr, msg)
-		if err != nil {
-			log.Error(err.Error())
-			continue
-		}
-		n.mergeCertificates(reply.GetCertificates())
-		n.mergeNotes(reply.GetNotes())
-	}
+func (c correct) Reply(n *Node, id string, reply *pb.StateResponse) {
 }
 
-func (sa spamAccusations) Monitor(n *Node) {
-	for _, ring := range n.ringMap {
-		succ, err := ring.getRingSucc()
-		if err != nil {
-			log.Error(err.Error())
-			continue
-		}
-
-		p := n.getLivePeer(succ.key)
-		if p == nil {
-			continue
-		}
-
-		peerNote := p.getNote()
-		if peerNote == nil {
-			continue
-		}
-
-		a := &accusation{
-			peerId:  p.peerId,
-			epoch:   peerNote.epoch,
-			accuser: n.peerId,
-			mask:    peerNote.mask,
-			ringNum: ring.ringNum,
-		}
-
-		err = a.sign(n.privKey)
-		if err != nil {
-			log.Error(err.Error())
-			continue
-		}
-
-		err = p.setAccusation(a)
-		if err != nil {
-			log.Error(err.Error())
-			return
-		}
-
-		if !n.timerExist(p.key) {
-			n.startTimer(p.key, peerNote, n.peer, p.addr)
-		}
-
-	}
-}
-
-func (sa spamAccusations) Rebuttal(n *Node) {
-	var err error
-	var neighbours []string
-
-	for {
-		neighbours, err = n.getGossipPartners()
-		if err != nil {
-			log.Error(err.Error())
-		} else {
-			break
-		}
-	}
-
-	noteMsg := n.localNoteToPbMsg()
-
-	msg := &gossip.State{
-		OwnNote: noteMsg,
-	}
-
-	for _, addr := range neighbours {
-		if addr == n.addr {
-			continue
-		}
-		_, err = n.client.Gossip(addr, msg)
+// Gossips the given state with all partners and merges their replies.
+func (n *Node) gossipWith(partners []*discovery.Peer, msg *pb.State) {
+	for _, p := range partners {
+		reply, err := n.comm.Gossip(p.Addr, msg)
 		if err != nil {
-			log.Error(err.Error())
-			continue
-		}
-	}
-}
-
-func (sa spamAccusations) Timeouts(n *Node) {
-}
-
-func createFalseAccusations(n *Node) (*gossip.State, error) {
-	var i uint32
-	msg := &gossip.State{}
-
-	view := n.getView()
-
-	for _, p := range view {
-		peerNote := p.getNote()
-		if peerNote == nil {
+			log.Error(err.Error(), "addr", p.Addr)
 			continue
 		}
 
-		for i = 1; i <= n.numRings; i++ {
-			a := &accusation{
-				peerId:  p.peerId,
-				epoch:   peerNote.epoch,
-				accuser: n.peerId,
-				mask:    peerNote.mask,
-				ringNum: i,
-			}
-
-			err := a.sign(n.privKey)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
+		n.mergeCertificates(reply.GetCertificates())
+		n.mergeNotes(reply.GetNotes())
+		n.mergeAccusations(reply.GetAccusations())
+		n.mergeGossipEntries(reply.GetGossipEntries())
 
-			err = p.setAccusation(a)
-			if err != nil {
-				log.Error(err.Error())
-				continue
+		if handler := n.getResponseHandler(); handler != nil {
+			if r := reply.GetExternalGossip(); r != nil {
+				handler(&Request{
+					Context:     context.Background(),
+					SenderId:    p.Id,
+					SenderAddr:  p.Addr,
+					Certificate: p.X509(),
+					Data:        r,
+				})
 			}
-
-			//msg.Accusations = append(msg.Accusations, a.toPbMsg())
 		}
-	}
 
-	noteMsg := n.localNoteToPbMsg()
-
-	msg.OwnNote = noteMsg
-
-	return msg, nil
-}
-
-func (e experiment) Rebuttal(n *Node) {
-	var err error
-	var neighbours []string
-
-	for {
-		neighbours, err = n.getGossipPartners()
-		if err != nil {
-			log.Error(err.Error())
-		} else {
-			break
-		}
+		n.handleTopicResponses(p, reply.GetTopicGossip())
 	}
+}
 
-	noteMsg := n.localNoteToPbMsg()
-
-	msg := &gossip.State{
-		OwnNote: noteMsg,
+// Sends the given note to all partners, replies are ignored.
+func (n *Node) sendNote(partners []*discovery.Peer, note *pb.Note) {
+	msg := &pb.State{
+		OwnNote: note,
 	}
 
-	for _, addr := range neighbours {
-		if addr == n.addr {
-			continue
-		}
-		_, err = n.client.Gossip(addr, msg)
+	for _, p := range partners {
+		_, err := n.comm.Gossip(p.Addr, msg)
 		if err != nil {
-			log.Error(err.Error())
+			log.Error(err.Error(), "addr", p.Addr)
 			continue
 		}
 	}
 }
 
-func dos(addr string, msg *gossip.State, n *Node) {
-	_, err := n.client.Gossip(addr, msg)
-	if err != nil {
-		log.Error("%s, addr: %s", err.Error(), addr)
-	}
-}
-
-func (e experiment) Gossip(n *Node) {
-		msg, err := n.collectGossipContent()
-		if err != nil {
-			return
-		}
+// Accuses the given peer on the given ring and starts its removal timer.
+func (n *Node) accuse(p *discovery.Peer, ringNum uint32) {
+	peerNote := p.Note()
 
-	msg := &gossip.State{}
-
-	for i := 0; i < e.maxConc; i++ {
-		go dos(e.addr, msg, n)
+	// Will always have note for a peer in our liveView, except when the peer stems
+	// from the initial contact list of the CA, if it's dead
+	// we should remove it to ensure it doesn't stay in our liveView.
+	// Not possible to accuse a peer without a note.
+	if peerNote == nil {
+		n.view.RemoveLive(p.Id)
+		log.Debug("Removing live peer due to not having note and being accused",
+			"addr", p.Addr)
+		return
 	}
-}
 
-func (e experiment) Monitor(n *Node) {
-	for _, ring := range n.ringMap {
-		succ, err := ring.getRingSucc()
-		if err != nil {
-			log.Error(err.Error())
-			continue
-		}
-
-		p := n.getLivePeer(succ.key)
-		if p == nil {
-			continue
-		}
-
-		err = n.ping(p)
-		if err != nil {
-			if err != errDead {
-				continue
-			}
-
-			log.Debug("%s is dead, accusing", p.addr)
-			peerNote := p.getNote()
-			//Will always have note for a peer in our liveView, except when the peer stems
-			//from the initial contact list of the CA, if it's dead
-			//we should remove it to ensure it doesn't stay in our liveView.
-			//Not possible to accuse a peer without a note.
-			if peerNote == nil {
-				n.removeLivePeer(p.key)
-				continue
-			}
-
-			a := &accusation{
-				peerId:  p.peerId,
-				epoch:   peerNote.epoch,
-				accuser: n.peerId,
-				mask:    peerNote.mask,
-				ringNum: ring.ringNum,
-			}
-
-			acc := p.getRingAccusation(ring.ringNum)
-			if a.equal(acc) {
-				log.Debug("Already accused peer on this ring")
-				if !n.timerExist(p.key) {
-					n.startTimer(p.key, peerNote, n.peer, p.addr)
-				}
-				continue
-			}
-
-			err = a.sign(n.privKey)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
-
-			err = p.setAccusation(a)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
-			if !n.timerExist(p.key) {
-				n.startTimer(p.key, peerNote, n.peer, p.addr)
-			}
-		}
+	err := p.CreateAccusation(peerNote, n.self, ringNum, n.cs)
+	if err == nil {
+		n.view.Emit(discovery.PeerAccused, p, ringNum, peerNote.Epoch())
 	}
-}
 
-func (e experiment) Timeouts(n *Node) {
-	timeouts := n.getAllTimeouts()
-	for key, t := range timeouts {
-		log.Debug("Have timeout for: %s", t.addr)
-		since := time.Since(t.timeStamp)
-		if since.Seconds() > n.nodeDeadTimeout {
-			log.Debug("%s timeout expired, removing from live", t.addr)
-			n.deleteTimeout(key)
-			n.removeLivePeer(key)
+	if err == discovery.ErrAccAlreadyExists || err == nil {
+		live := n.view.IsAlive(p.Id)
+		if exists := n.view.HasTimer(p.Id); !exists && live {
+			n.view.StartTimer(p, peerNote, n.self)
 		}
+	} else {
+		log.Error(err.Error())
 	}
 }
-*/
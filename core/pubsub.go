@@ -0,0 +1,354 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"golang.org/x/net/context"
+)
+
+const (
+	pubsubTopic = InternalTopicPrefix + "pubsub"
+
+	// Gossip key under which members advertise the topics they are subscribed to.
+	subscriptionsKey = InternalTopicPrefix + "subscriptions"
+
+	pubsubSendTimeout = time.Second * 10
+
+	// How long publications are accepted after the time stated by their sequence number,
+	// their ids are remembered until then so they can not be delivered again.
+	pubsubSeenTimeout = time.Minute * 10
+)
+
+var (
+	errNoTopic         = errors.New("Topic is empty")
+	errInternalTopic   = errors.New("Topic is reserved for internal use")
+	errSeenPublication = errors.New("Publication already seen")
+	errNoPublisher     = errors.New("Publisher not found in full view")
+	errNoPubSignature  = errors.New("Publication has no signature")
+	errExpiredPub      = errors.New("Publication is older than the duplicate detection window")
+	errFuturePub       = errors.New("Publication is stamped in the future")
+)
+
+// Publication is a message published to a topic, see Publish.
+type Publication struct {
+	// Ifrit id of the member that published the message.
+	Origin string
+
+	Topic   string
+	Content []byte
+}
+
+// Local subscriptions and the ids of all publications seen recently.
+type pubsub struct {
+	handlers map[string]func(*Publication)
+	seen     map[string]time.Time
+
+	mutex sync.RWMutex
+}
+
+func newPubsub() *pubsub {
+	return &pubsub{
+		handlers: make(map[string]func(*Publication)),
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// Publishes the given data to all members subscribed to topic.
+// The publication is signed and sent to the closest subscribed successor on each ring,
+// subscribers forward it in the same way. Members that are not subscribed are skipped.
+// The local handler is invoked before Publish returns if the node is subscribed itself.
+func (n *Node) Publish(topic string, data []byte) error {
+	if topic == "" {
+		return errNoTopic
+	}
+
+	if len(data) == 0 {
+		return errNoData
+	}
+
+	now := time.Now()
+
+	msg := &pb.Publication{
+		Origin:  []byte(n.self.Id),
+		Topic:   topic,
+		Seq:     uint64(now.UnixNano()),
+		Content: data,
+	}
+
+	bytes, err := publicationBytes(msg)
+	if err != nil {
+		return err
+	}
+
+	r, s, err := n.cs.Sign(bytes)
+	if err != nil {
+		return err
+	}
+
+	msg.Signature = &pb.Signature{
+		R: r,
+		S: s,
+	}
+
+	n.markPublication(publicationId(bytes), now.Add(pubsubSeenTimeout+maxClockSkew))
+
+	n.forwardPublication(msg)
+
+	n.deliverPublication(msg)
+
+	return nil
+}
+
+// Subscribes to topic, the handler is invoked once for each publication.
+// A nil handler cancels the subscription. Subscriptions are advertised to other
+// members through gossip, publications are only routed to members once they learn of it.
+func (n *Node) SubscribeTopic(topic string, handler func(*Publication)) error {
+	if topic == "" {
+		return errNoTopic
+	}
+
+	if strings.HasPrefix(topic, InternalTopicPrefix) {
+		return errInternalTopic
+	}
+
+	n.pubsub.mutex.Lock()
+	defer n.pubsub.mutex.Unlock()
+
+	if handler == nil {
+		delete(n.pubsub.handlers, topic)
+	} else {
+		n.pubsub.handlers[topic] = handler
+	}
+
+	subs := &pb.Subscriptions{}
+
+	for t := range n.pubsub.handlers {
+		subs.Topics = append(subs.Topics, t)
+	}
+
+	sort.Strings(subs.Topics)
+
+	bytes, err := proto.Marshal(subs)
+	if err != nil {
+		return err
+	}
+
+	return n.SetGossipKey(subscriptionsKey, bytes)
+}
+
+// Message handler of the internal publish/subscribe topic.
+func (n *Node) handlePublication(r *Request) ([]byte, error) {
+	msg := &pb.Publication{}
+
+	if err := proto.Unmarshal(r.Data, msg); err != nil {
+		return nil, err
+	}
+
+	if err := n.evalPublication(msg); err != nil && err != errSeenPublication {
+		log.Debug(err.Error(), "topic", msg.GetTopic())
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (n *Node) evalPublication(msg *pb.Publication) error {
+	bytes, err := publicationBytes(msg)
+	if err != nil {
+		return err
+	}
+
+	id := publicationId(bytes)
+
+	n.pubsub.mutex.RLock()
+	_, seen := n.pubsub.seen[id]
+	n.pubsub.mutex.RUnlock()
+
+	if seen {
+		return errSeenPublication
+	}
+
+	now := time.Now()
+	stamp := time.Unix(0, int64(msg.GetSeq()))
+
+	if now.After(stamp.Add(pubsubSeenTimeout)) {
+		return errExpiredPub
+	}
+
+	if stamp.After(now.Add(maxClockSkew)) {
+		return errFuturePub
+	}
+
+	sign := msg.GetSignature()
+	if sign == nil {
+		return errNoPubSignature
+	}
+
+	p := n.view.Peer(string(msg.GetOrigin()))
+	if p == nil {
+		return errNoPublisher
+	}
+
+	if valid := n.cs.Verify(bytes, sign.GetR(), sign.GetS(), p.PublicKey()); !valid {
+		return errInvalidSignature
+	}
+
+	if !n.markPublication(id, stamp.Add(pubsubSeenTimeout+maxClockSkew)) {
+		return errSeenPublication
+	}
+
+	// Members that recently cancelled their subscription might still be
+	// advertised as subscribers, they keep forwarding without delivering.
+	n.forwardPublication(msg)
+
+	n.deliverPublication(msg)
+
+	return nil
+}
+
+// Marks the publication as seen until expiry and discards expired ids.
+// Returns false if the publication was already seen.
+func (n *Node) markPublication(id string, expiry time.Time) bool {
+	now := time.Now()
+
+	n.pubsub.mutex.Lock()
+	defer n.pubsub.mutex.Unlock()
+
+	if _, seen := n.pubsub.seen[id]; seen {
+		return false
+	}
+
+	for other, expire := range n.pubsub.seen {
+		if now.After(expire) {
+			delete(n.pubsub.seen, other)
+		}
+	}
+
+	n.pubsub.seen[id] = expiry
+
+	return true
+}
+
+// Sends the publication to the forwarding targets of its topic.
+func (n *Node) forwardPublication(msg *pb.Publication) {
+	targets := n.publicationTargets(msg.GetTopic(), string(msg.GetOrigin()))
+	if len(targets) == 0 {
+		return
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	for _, addr := range targets {
+		go func(addr string) {
+			ctx, cancel := context.WithTimeout(context.Background(), pubsubSendTimeout)
+			defer cancel()
+
+			if _, err := n.SendTopicMessageContext(ctx, addr, pubsubTopic, data); err != nil {
+				log.Debug(err.Error(), "addr", addr)
+			}
+		}(addr)
+	}
+}
+
+// Returns the addresses of the closest subscribed successor on each ring, indexed by id.
+// Subscribers form a cycle on every ring, forwarding along it reaches all of them
+// while skipping members without interest in the topic.
+func (n *Node) publicationTargets(topic, origin string) map[string]string {
+	var i uint32
+
+	targets := make(map[string]string)
+
+	subscribers := n.subscribers(topic)
+	if len(subscribers) == 0 {
+		return targets
+	}
+
+	size := len(n.view.Live()) + 1
+
+	for i = 1; i <= n.view.NumRings(); i++ {
+		succ, err := n.view.Lookup(i, []byte(n.self.Id), size)
+		if err != nil {
+			log.Error(err.Error())
+			continue
+		}
+
+		for _, p := range succ {
+			if p.Id == n.self.Id || !subscribers[p.Id] {
+				continue
+			}
+
+			if p.Id != origin {
+				targets[p.Id] = p.Addr
+			}
+			break
+		}
+	}
+
+	return targets
+}
+
+// Returns the ids of all members advertising a subscription to topic.
+func (n *Node) subscribers(topic string) map[string]bool {
+	ret := make(map[string]bool)
+
+	for _, e := range n.GossipEntries(subscriptionsKey) {
+		subs := &pb.Subscriptions{}
+
+		if err := proto.Unmarshal(e.Value, subs); err != nil {
+			log.Debug(err.Error(), "origin", e.Origin)
+			continue
+		}
+
+		for _, t := range subs.GetTopics() {
+			if t == topic {
+				ret[e.Origin] = true
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+func (n *Node) deliverPublication(msg *pb.Publication) {
+	n.pubsub.mutex.RLock()
+	handler := n.pubsub.handlers[msg.GetTopic()]
+	n.pubsub.mutex.RUnlock()
+
+	if handler == nil {
+		return
+	}
+
+	handler(&Publication{
+		Origin:  string(msg.GetOrigin()),
+		Topic:   msg.GetTopic(),
+		Content: msg.GetContent(),
+	})
+}
+
+// Returns the bytes signed by the origin, a copy of the publication without the signature.
+// The publication itself is forwarded as is.
+func publicationBytes(msg *pb.Publication) ([]byte, error) {
+	return proto.Marshal(&pb.Publication{
+		Origin:  msg.GetOrigin(),
+		Topic:   msg.GetTopic(),
+		Seq:     msg.GetSeq(),
+		Content: msg.GetContent(),
+	})
+}
+
+// Identifies a publication by a hash of its signed bytes, the signature itself is malleable.
+func publicationId(signed []byte) string {
+	return string(hashContent(signed))
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *HandlerTestSuite) TestSubscribeTopic() {
+	node := suite.n
+
+	handler := func(p *Publication) {}
+
+	require.Equal(suite.T(), errNoTopic, node.SubscribeTopic("", handler), "Empty topic should be rejected.")
+	require.Equal(suite.T(), errInternalTopic, node.SubscribeTopic(pubsubTopic, handler),
+		"Internal topic should be rejected.")
+
+	require.NoError(suite.T(), node.SubscribeTopic("b", handler), "Failed to subscribe.")
+	require.NoError(suite.T(), node.SubscribeTopic("a", handler), "Failed to subscribe.")
+
+	require.True(suite.T(), node.subscribers("a")[node.self.Id], "Subscription was not advertised.")
+	require.True(suite.T(), node.subscribers("b")[node.self.Id], "Subscription was not advertised.")
+
+	require.NoError(suite.T(), node.SubscribeTopic("a", nil), "Failed to unsubscribe.")
+	require.False(suite.T(), node.subscribers("a")[node.self.Id], "Cancelled subscription still advertised.")
+	require.True(suite.T(), node.subscribers("b")[node.self.Id], "Other subscription was dropped.")
+}
+
+func (suite *HandlerTestSuite) TestPublish() {
+	var delivered []*Publication
+
+	node := suite.n
+
+	require.Equal(suite.T(), errNoTopic, node.Publish("", []byte("data")), "Empty topic should be rejected.")
+	require.Equal(suite.T(), errNoData, node.Publish("topic", nil), "Empty publication should be rejected.")
+
+	require.NoError(suite.T(), node.Publish("topic", []byte("unheard")), "Failed to publish.")
+
+	require.NoError(suite.T(), node.SubscribeTopic("topic", func(p *Publication) {
+		delivered = append(delivered, p)
+	}), "Failed to subscribe.")
+
+	require.NoError(suite.T(), node.Publish("topic", []byte("local")), "Failed to publish.")
+	require.Len(suite.T(), delivered, 1, "Local handler was not invoked.")
+	require.Equal(suite.T(), node.self.Id, delivered[0].Origin, "Invalid origin.")
+	require.Equal(suite.T(), "topic", delivered[0].Topic, "Invalid topic.")
+	require.Equal(suite.T(), []byte("local"), delivered[0].Content, "Invalid content.")
+}
+
+func (suite *HandlerTestSuite) TestEvalPublication() {
+	var delivered []*Publication
+
+	node := suite.n
+	p := node.view.Full()[0]
+
+	require.NoError(suite.T(), node.SubscribeTopic("topic", func(p *Publication) {
+		delivered = append(delivered, p)
+	}), "Failed to subscribe.")
+
+	msg := suite.signedPublication(p.Id, "topic", []byte("remote"))
+
+	require.NoError(suite.T(), node.evalPublication(msg), "Valid publication was rejected.")
+	require.Equal(suite.T(), errSeenPublication, node.evalPublication(msg), "Duplicate should be discarded.")
+
+	resigned := suite.signPublication(p.Id, &pb.Publication{Origin: msg.Origin, Topic: msg.Topic, Seq: msg.Seq, Content: msg.Content})
+	require.NotEqual(suite.T(), msg.GetSignature().GetR(), resigned.GetSignature().GetR(), "Signatures should differ.")
+	require.Equal(suite.T(), errSeenPublication, node.evalPublication(resigned), "Re-signed duplicate should be discarded.")
+	require.Len(suite.T(), delivered, 1, "Handler should be invoked exactly once.")
+	require.Equal(suite.T(), p.Id, delivered[0].Origin, "Invalid origin.")
+	require.Equal(suite.T(), []byte("remote"), delivered[0].Content, "Invalid content.")
+
+	tampered := suite.signedPublication(p.Id, "topic", []byte("content"))
+	tampered.Content = []byte("tampered")
+	require.Equal(suite.T(), errInvalidSignature, node.evalPublication(tampered), "Tampered publication should be rejected.")
+
+	moved := suite.signedPublication(p.Id, "topic", []byte("content"))
+	moved.Topic = "other"
+	require.Equal(suite.T(), errInvalidSignature, node.evalPublication(moved), "Topic should be covered by signature.")
+
+	unsigned := suite.signedPublication(p.Id, "topic", []byte("content"))
+	unsigned.Signature = nil
+	require.Equal(suite.T(), errNoPubSignature, node.evalPublication(unsigned), "Unsigned publication should be rejected.")
+
+	unknown := suite.signedPublication(p.Id, "topic", []byte("content"))
+	unknown.Origin = genId()
+	require.Equal(suite.T(), errNoPublisher, node.evalPublication(unknown), "Unknown origin should be rejected.")
+
+	old := suite.signPublication(p.Id, &pb.Publication{
+		Origin:  []byte(p.Id),
+		Topic:   "topic",
+		Seq:     uint64(time.Now().Add(-time.Hour).UnixNano()),
+		Content: []byte("content"),
+	})
+	require.Equal(suite.T(), errExpiredPub, node.evalPublication(old), "Publication older than the seen timeout should be rejected.")
+
+	future := suite.signPublication(p.Id, &pb.Publication{
+		Origin:  []byte(p.Id),
+		Topic:   "topic",
+		Seq:     uint64(time.Now().Add(time.Hour).UnixNano()),
+		Content: []byte("content"),
+	})
+	require.Equal(suite.T(), errFuturePub, node.evalPublication(future), "Publication from the future should be rejected.")
+
+	require.Len(suite.T(), delivered, 1, "Handler invoked for rejected publications.")
+}
+
+func (suite *HandlerTestSuite) TestPublicationTargets() {
+	var i uint32
+
+	node := suite.n
+	live := node.view.Live()
+
+	require.Empty(suite.T(), node.publicationTargets("topic", node.self.Id), "No targets without subscribers.")
+
+	subscribers := make(map[string]bool)
+
+	for _, p := range live[:len(live)/4] {
+		subs, err := proto.Marshal(&pb.Subscriptions{Topics: []string{"topic"}})
+		require.NoError(suite.T(), err, "Failed to marshal subscriptions.")
+
+		require.NoError(suite.T(), node.evalGossipEntry(suite.signedEntry(p.Id, subscriptionsKey, 1, subs)),
+			"Failed to add subscription.")
+
+		subscribers[p.Id] = true
+	}
+
+	targets := node.publicationTargets("topic", node.self.Id)
+	require.NotEmpty(suite.T(), targets, "Should forward to subscribers.")
+	require.True(suite.T(), len(targets) <= int(node.view.NumRings()), "At most one target per ring.")
+
+	for id := range targets {
+		require.True(suite.T(), subscribers[id], "Target is not subscribed.")
+	}
+
+	// The closest subscribed successor on each ring is a target.
+	for i = 1; i <= node.view.NumRings(); i++ {
+		succ, err := node.view.Lookup(i, []byte(node.self.Id), len(live)+1)
+		require.NoError(suite.T(), err, "Lookup failed.")
+
+		for _, p := range succ {
+			if subscribers[p.Id] {
+				require.Contains(suite.T(), targets, p.Id, "Closest subscriber on ring is not a target.")
+				break
+			}
+		}
+	}
+
+	for id := range targets {
+		require.NotContains(suite.T(), node.publicationTargets("topic", id), id, "Origin should not be a target.")
+	}
+
+	require.Empty(suite.T(), node.publicationTargets("other", node.self.Id), "No targets for topic without subscribers.")
+}
+
+func (suite *HandlerTestSuite) signedPublication(origin, topic string, content []byte) *pb.Publication {
+	return suite.signPublication(origin, &pb.Publication{
+		Origin:  []byte(origin),
+		Topic:   topic,
+		Seq:     uint64(time.Now().UnixNano()),
+		Content: content,
+	})
+}
+
+func (suite *HandlerTestSuite) signPublication(origin string, msg *pb.Publication) *pb.Publication {
+	bytes, err := proto.Marshal(msg)
+	require.NoError(suite.T(), err, "Failed to marshal publication.")
+
+	cs := &cryptoStub{priv: suite.privMap[origin]}
+	r, s, err := cs.Sign(bytes)
+	require.NoError(suite.T(), err, "Failed to sign publication.")
+
+	msg.Signature = &pb.Signature{R: r, S: s}
+
+	return msg
+}
@@ -0,0 +1,444 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"golang.org/x/net/context"
+)
+
+const (
+	// Topics with this prefix are reserved for messages exchanged by ifrit itself.
+	InternalTopicPrefix = "ifrit."
+
+	rbcTopic = InternalTopicPrefix + "rbc"
+
+	rbcSendTimeout = time.Second * 10
+
+	// How often messages not yet acknowledged by their destination are sent again.
+	rbcRetransmitInterval = rbcSendTimeout
+
+	// How long the state of a broadcast instance is kept after the time stated by its sequence number,
+	// messages of older instances are rejected so they can not be delivered again.
+	rbcInstanceTimeout = time.Minute * 10
+)
+
+const (
+	rbcSend uint32 = iota + 1
+	rbcEcho
+	rbcReady
+)
+
+var (
+	errRbcSender      = errors.New("Reliable broadcast sender does not match rpc sender")
+	errRbcPhase       = errors.New("Reliable broadcast message has invalid phase")
+	errRbcOrigin      = errors.New("Reliable broadcast send phase not issued by origin")
+	errRbcUnknownPeer = errors.New("Reliable broadcast sender not found in full view")
+	errRbcExpired     = errors.New("Reliable broadcast instance has expired")
+	errRbcFuture      = errors.New("Reliable broadcast sequence number is in the future")
+)
+
+// ReliableMessage is a message delivered through reliable broadcast, see ReliableBroadcast.
+type ReliableMessage struct {
+	// Ifrit id of the member that broadcast the message.
+	Origin string
+
+	// Sequence number chosen by the origin, unique per origin.
+	// It is the time of the broadcast in nanoseconds since the unix epoch.
+	Seq uint64
+
+	Content []byte
+}
+
+// State of a single broadcast, identified by origin and sequence number.
+// Echoes and readies are counted per content digest, an equivocating
+// origin can therefore never get two different contents delivered.
+type rbcInstance struct {
+	// Network size and tolerated faulty members, fixed when the instance is created.
+	n int
+	f int
+
+	echoes  map[string]map[string]bool
+	readies map[string]map[string]bool
+
+	echoed    bool
+	readied   bool
+	delivered bool
+}
+
+type rbcKey struct {
+	origin string
+	seq    uint64
+}
+
+// Phase message issued by the local node, sent until each destination acknowledged it.
+type rbcOutgoing struct {
+	data []byte
+
+	// Ids of the members which have not acknowledged the message yet.
+	pending map[string]bool
+}
+
+type reliableBroadcast struct {
+	instances map[rbcKey]*rbcInstance
+	outbox    map[rbcKey][]*rbcOutgoing
+	handler   func(*ReliableMessage)
+
+	mutex sync.Mutex
+}
+
+func newReliableBroadcast() *reliableBroadcast {
+	return &reliableBroadcast{
+		instances: make(map[rbcKey]*rbcInstance),
+		outbox:    make(map[rbcKey][]*rbcOutgoing),
+	}
+}
+
+// Reliably broadcasts the given data following Bracha's protocol.
+// With n members of which at most f=(n-1)/3 are faulty, either all correct members
+// deliver the same content for a broadcast or none does, even if the origin equivocates.
+// The network size n is that of the full view, on which members agree once all certificates
+// have propagated. Phase messages are sent to the same full view and retransmitted until each
+// member acknowledged them, members which are removed from the full view are no longer sent to.
+// Failed members still count towards n of the broadcasts issued before their removal,
+// delivery of those requires more than two thirds of the network to be reachable.
+// The local handler is invoked once delivery conditions are met locally.
+// Each broadcast is delivered at most once, messages older than rbcInstanceTimeout are rejected.
+func (n *Node) ReliableBroadcast(data []byte) error {
+	if len(data) == 0 {
+		return errNoData
+	}
+
+	msg := &pb.RbcMsg{
+		Origin:  []byte(n.self.Id),
+		Seq:     uint64(time.Now().UnixNano()),
+		Phase:   rbcSend,
+		Content: data,
+	}
+
+	return n.rbcSendAll(msg)
+}
+
+// Registers the handler invoked once for each reliably delivered broadcast, a nil handler removes it.
+func (n *Node) SetReliableBroadcastHandler(handler func(*ReliableMessage)) {
+	n.rbc.mutex.Lock()
+	defer n.rbc.mutex.Unlock()
+
+	n.rbc.handler = handler
+}
+
+// Message handler of the internal reliable broadcast topic.
+func (n *Node) handleRbc(r *Request) ([]byte, error) {
+	msg := &pb.RbcMsg{}
+
+	if err := proto.Unmarshal(r.Data, msg); err != nil {
+		return nil, err
+	}
+
+	if string(msg.GetSender()) != r.SenderId {
+		return nil, errRbcSender
+	}
+
+	if err := n.evalRbc(msg); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (n *Node) evalRbc(msg *pb.RbcMsg) error {
+	sign := msg.GetSignature()
+	if sign == nil {
+		return errInvalidSignature
+	}
+
+	p := n.view.Peer(string(msg.GetSender()))
+	if p == nil {
+		return errRbcUnknownPeer
+	}
+
+	msg.Signature = nil
+	bytes, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if valid := n.cs.Verify(bytes, sign.GetR(), sign.GetS(), p.PublicKey()); !valid {
+		return errInvalidSignature
+	}
+
+	msg.Signature = sign
+
+	return n.processRbc(msg)
+}
+
+// Records the phase message and issues the next phases once their thresholds are reached.
+func (n *Node) processRbc(msg *pb.RbcMsg) error {
+	var out []uint32
+	var deliver *ReliableMessage
+
+	origin := string(msg.GetOrigin())
+	sender := string(msg.GetSender())
+	content := msg.GetContent()
+	digest := string(hashContent(content))
+
+	n.rbc.mutex.Lock()
+
+	inst, err := n.rbcInstance(origin, msg.GetSeq())
+	if err != nil {
+		n.rbc.mutex.Unlock()
+		return err
+	}
+
+	switch msg.GetPhase() {
+	case rbcSend:
+		if sender != origin {
+			n.rbc.mutex.Unlock()
+			return errRbcOrigin
+		}
+
+		// Only the first content proposed by the origin is echoed.
+		if !inst.echoed {
+			inst.echoed = true
+			out = append(out, rbcEcho)
+		}
+
+	case rbcEcho:
+		if inst.echoes[digest] == nil {
+			inst.echoes[digest] = make(map[string]bool)
+		}
+		inst.echoes[digest][sender] = true
+
+		if !inst.readied && len(inst.echoes[digest]) >= (inst.n+inst.f)/2+1 {
+			inst.readied = true
+			out = append(out, rbcReady)
+		}
+
+	case rbcReady:
+		if inst.readies[digest] == nil {
+			inst.readies[digest] = make(map[string]bool)
+		}
+		inst.readies[digest][sender] = true
+
+		readies := len(inst.readies[digest])
+
+		// At least one correct member is ready, safe to join.
+		if !inst.readied && readies >= inst.f+1 {
+			inst.readied = true
+			out = append(out, rbcReady)
+		}
+
+		if !inst.delivered && readies >= 2*inst.f+1 {
+			inst.delivered = true
+			deliver = &ReliableMessage{
+				Origin:  origin,
+				Seq:     msg.GetSeq(),
+				Content: content,
+			}
+		}
+
+	default:
+		n.rbc.mutex.Unlock()
+		return errRbcPhase
+	}
+
+	handler := n.rbc.handler
+
+	n.rbc.mutex.Unlock()
+
+	if deliver != nil && handler != nil {
+		handler(deliver)
+	}
+
+	for _, phase := range out {
+		next := &pb.RbcMsg{
+			Origin:  msg.GetOrigin(),
+			Seq:     msg.GetSeq(),
+			Phase:   phase,
+			Content: content,
+		}
+
+		if err := n.rbcSendAll(next); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the instance of the given broadcast, creating it if it does not exist.
+// Instances are dropped once their sequence number falls behind rbcInstanceTimeout,
+// messages of dropped instances are rejected instead of recreating them.
+// Must hold the rbc lock.
+func (n *Node) rbcInstance(origin string, seq uint64) (*rbcInstance, error) {
+	now := time.Now()
+	horizon := now.Add(-rbcInstanceTimeout)
+
+	n.expireRbc(horizon)
+
+	stamp := time.Unix(0, int64(seq))
+
+	if stamp.Before(horizon) {
+		return nil, errRbcExpired
+	}
+
+	if stamp.After(now.Add(maxClockSkew)) {
+		return nil, errRbcFuture
+	}
+
+	key := rbcKey{origin: origin, seq: seq}
+
+	inst, ok := n.rbc.instances[key]
+	if !ok {
+		size := len(n.view.Full()) + 1
+
+		inst = &rbcInstance{
+			n:       size,
+			f:       (size - 1) / 3,
+			echoes:  make(map[string]map[string]bool),
+			readies: make(map[string]map[string]bool),
+		}
+		n.rbc.instances[key] = inst
+	}
+
+	return inst, nil
+}
+
+// Drops the instances and outgoing messages of broadcasts older than horizon.
+// Must hold the rbc lock.
+func (n *Node) expireRbc(horizon time.Time) {
+	for key := range n.rbc.instances {
+		if time.Unix(0, int64(key.seq)).Before(horizon) {
+			delete(n.rbc.instances, key)
+		}
+	}
+
+	for key := range n.rbc.outbox {
+		if time.Unix(0, int64(key.seq)).Before(horizon) {
+			delete(n.rbc.outbox, key)
+		}
+	}
+}
+
+// Signs the message as the local node, sends it to all members of the full view and processes it locally.
+func (n *Node) rbcSendAll(msg *pb.RbcMsg) error {
+	msg.Sender = []byte(n.self.Id)
+	msg.Signature = nil
+
+	bytes, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	r, s, err := n.cs.Sign(bytes)
+	if err != nil {
+		return err
+	}
+
+	msg.Signature = &pb.Signature{
+		R: r,
+		S: s,
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	out := &rbcOutgoing{
+		data:    data,
+		pending: make(map[string]bool),
+	}
+
+	for _, p := range n.view.Full() {
+		out.pending[p.Id] = true
+	}
+
+	key := rbcKey{origin: string(msg.GetOrigin()), seq: msg.GetSeq()}
+
+	n.rbc.mutex.Lock()
+	n.rbc.outbox[key] = append(n.rbc.outbox[key], out)
+	n.rbc.mutex.Unlock()
+
+	n.rbcTransmit(out)
+
+	return n.processRbc(msg)
+}
+
+// Sends the message to all members which have not acknowledged it yet.
+// Members no longer in the full view are dropped.
+func (n *Node) rbcTransmit(out *rbcOutgoing) {
+	var ids []string
+
+	n.rbc.mutex.Lock()
+	for id := range out.pending {
+		ids = append(ids, id)
+	}
+	n.rbc.mutex.Unlock()
+
+	for _, id := range ids {
+		p := n.view.Peer(id)
+		if p == nil {
+			n.rbc.mutex.Lock()
+			delete(out.pending, id)
+			n.rbc.mutex.Unlock()
+			continue
+		}
+
+		go func(id, addr string) {
+			ctx, cancel := context.WithTimeout(context.Background(), rbcSendTimeout)
+			defer cancel()
+
+			if _, err := n.SendTopicMessageContext(ctx, addr, rbcTopic, out.data); err != nil {
+				log.Debug(err.Error(), "addr", addr)
+				return
+			}
+
+			n.rbc.mutex.Lock()
+			delete(out.pending, id)
+			n.rbc.mutex.Unlock()
+		}(id, p.Addr)
+	}
+}
+
+// Sends all unacknowledged messages of unexpired broadcasts again, invoked periodically by Start.
+func (n *Node) retransmitRbc() {
+	var outs []*rbcOutgoing
+
+	n.rbc.mutex.Lock()
+	n.expireRbc(time.Now().Add(-rbcInstanceTimeout))
+
+	for _, msgs := range n.rbc.outbox {
+		for _, out := range msgs {
+			if len(out.pending) > 0 {
+				outs = append(outs, out)
+			}
+		}
+	}
+	n.rbc.mutex.Unlock()
+
+	for _, out := range outs {
+		n.rbcTransmit(out)
+	}
+}
+
+func (n *Node) rbcLoop() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(rbcRetransmitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.exitChan:
+			return
+		case <-ticker.C:
+			n.retransmitRbc()
+		}
+	}
+}
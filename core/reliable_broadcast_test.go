@@ -0,0 +1,238 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func (suite *HandlerTestSuite) TestReliableBroadcast() {
+	var delivered []*ReliableMessage
+
+	node := suite.n
+	peers := node.view.Full()
+	origin := peers[0]
+	seq := uint64(time.Now().UnixNano())
+
+	node.SetReliableBroadcastHandler(func(m *ReliableMessage) {
+		delivered = append(delivered, m)
+	})
+
+	content := []byte("entry")
+	digest := string(hashContent(content))
+	other := []byte("equivocation")
+
+	size := len(peers) + 1
+	f := (size - 1) / 3
+
+	send := suite.rbcMsg(origin.Id, origin.Id, seq, rbcSend, content)
+	require.NoError(suite.T(), node.evalRbc(send), "Valid send was rejected.")
+
+	inst := node.rbc.instances[rbcKey{origin: origin.Id, seq: seq}]
+	require.NotNil(suite.T(), inst, "Instance was not created.")
+	require.Equal(suite.T(), size, inst.n, "Invalid network size.")
+	require.Equal(suite.T(), f, inst.f, "Invalid number of tolerated faults.")
+	require.True(suite.T(), inst.echoes[digest][node.self.Id], "Send should be echoed.")
+
+	// Equivocating origin, second content must not be echoed.
+	equivocation := suite.rbcMsg(origin.Id, origin.Id, seq, rbcSend, other)
+	require.NoError(suite.T(), node.evalRbc(equivocation), "Valid send was rejected.")
+	require.False(suite.T(), inst.echoes[string(hashContent(other))][node.self.Id],
+		"Only the first send should be echoed.")
+
+	// Local echo is already counted.
+	echoThreshold := (size+f)/2 + 1
+	for _, p := range peers[1 : echoThreshold-1] {
+		require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, p.Id, seq, rbcEcho, content)),
+			"Valid echo was rejected.")
+	}
+	require.False(suite.T(), inst.readied, "Ready sent before reaching echo threshold.")
+
+	require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, peers[echoThreshold].Id, seq, rbcEcho, content)),
+		"Valid echo was rejected.")
+	require.True(suite.T(), inst.readies[digest][node.self.Id], "Ready should be sent after echo threshold.")
+
+	// Local ready is already counted.
+	for _, p := range peers[1 : 2*f] {
+		require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, p.Id, seq, rbcReady, content)),
+			"Valid ready was rejected.")
+	}
+	require.Empty(suite.T(), delivered, "Delivered before reaching ready threshold.")
+
+	for _, p := range peers[2*f : 2*f+2] {
+		require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, p.Id, seq, rbcReady, content)),
+			"Valid ready was rejected.")
+	}
+	require.Len(suite.T(), delivered, 1, "Should deliver exactly once.")
+	require.Equal(suite.T(), origin.Id, delivered[0].Origin, "Invalid origin.")
+	require.Equal(suite.T(), content, delivered[0].Content, "Invalid content.")
+
+	// Replayed messages of a delivered broadcast are not delivered again.
+	require.NoError(suite.T(), node.evalRbc(send), "Replayed send was rejected.")
+	for _, p := range peers[1 : 2*f+2] {
+		require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, p.Id, seq, rbcReady, content)),
+			"Replayed ready was rejected.")
+	}
+	require.Len(suite.T(), delivered, 1, "Replayed broadcast should not be delivered again.")
+}
+
+func (suite *HandlerTestSuite) TestReliableBroadcastExpired() {
+	node := suite.n
+	origin := node.view.Full()[0]
+
+	old := uint64(time.Now().Add(-rbcInstanceTimeout - time.Minute).UnixNano())
+	send := suite.rbcMsg(origin.Id, origin.Id, old, rbcSend, []byte("entry"))
+	require.Equal(suite.T(), errRbcExpired, node.evalRbc(send), "Send older than the instance timeout should be rejected.")
+	require.Empty(suite.T(), node.rbc.instances, "Expired instance should not be created.")
+
+	future := uint64(time.Now().Add(time.Hour).UnixNano())
+	send = suite.rbcMsg(origin.Id, origin.Id, future, rbcSend, []byte("entry"))
+	require.Equal(suite.T(), errRbcFuture, node.evalRbc(send), "Send from the future should be rejected.")
+
+	// Instances are dropped once expired.
+	node.rbc.instances[rbcKey{origin: origin.Id, seq: old}] = &rbcInstance{delivered: true}
+
+	seq := uint64(time.Now().UnixNano())
+	require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, origin.Id, seq, rbcSend, []byte("entry"))),
+		"Valid send was rejected.")
+	require.NotContains(suite.T(), node.rbc.instances, rbcKey{origin: origin.Id, seq: old}, "Expired instance should be dropped.")
+}
+
+func (suite *HandlerTestSuite) TestReliableBroadcastReadyAmplification() {
+	node := suite.n
+	peers := node.view.Full()
+	origin := peers[0]
+	seq := uint64(time.Now().UnixNano())
+
+	f := len(peers) / 3
+	content := []byte("entry")
+
+	for _, p := range peers[1 : f+1] {
+		require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, p.Id, seq, rbcReady, content)),
+			"Valid ready was rejected.")
+	}
+
+	inst := node.rbc.instances[rbcKey{origin: origin.Id, seq: seq}]
+	require.False(suite.T(), inst.readied, "Ready sent after f readies.")
+
+	require.NoError(suite.T(), node.evalRbc(suite.rbcMsg(origin.Id, peers[f+1].Id, seq, rbcReady, content)),
+		"Valid ready was rejected.")
+	require.True(suite.T(), inst.readied, "Ready should be sent after f+1 readies.")
+	require.False(suite.T(), inst.echoed, "Ready amplification should not echo.")
+}
+
+// Fails all sends until healed.
+type flakyComm struct {
+	commStub
+
+	failing bool
+	mutex   sync.Mutex
+}
+
+func (fc *flakyComm) Send(ctx context.Context, addr string, m *pb.Msg) (*pb.MsgResponse, error) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	if fc.failing {
+		return nil, errors.New("Unreachable")
+	}
+
+	return &pb.MsgResponse{}, nil
+}
+
+func (fc *flakyComm) setFailing(failing bool) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	fc.failing = failing
+}
+
+func (suite *HandlerTestSuite) TestReliableBroadcastRetransmit() {
+	node := suite.n
+	full := node.view.Full()
+
+	comm := &flakyComm{failing: true}
+	node.comm = comm
+
+	node.dispatcher.Start()
+	defer node.dispatcher.Stop()
+
+	pending := func() int {
+		var ret int
+
+		node.rbc.mutex.Lock()
+		defer node.rbc.mutex.Unlock()
+
+		for _, msgs := range node.rbc.outbox {
+			for _, out := range msgs {
+				ret += len(out.pending)
+			}
+		}
+
+		return ret
+	}
+
+	require.NoError(suite.T(), node.ReliableBroadcast([]byte("entry")), "Broadcast failed.")
+
+	// Send and local echo, addressed to the full view.
+	require.Equal(suite.T(), 2*len(full), pending(), "Messages should be addressed to the full view.")
+
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(suite.T(), 2*len(full), pending(), "Failed sends should stay pending.")
+
+	// Removed members are no longer sent to.
+	node.view.RemoveTestFull(full[0].Id)
+	comm.setFailing(false)
+
+	node.retransmitRbc()
+	require.Eventually(suite.T(), func() bool { return pending() == 0 }, time.Second*5, time.Millisecond*10,
+		"Acknowledged messages should not be retransmitted.")
+}
+
+func (suite *HandlerTestSuite) TestInvalidRbc() {
+	node := suite.n
+	peers := node.view.Full()
+	seq := uint64(time.Now().UnixNano())
+
+	notOrigin := suite.rbcMsg(peers[0].Id, peers[1].Id, seq, rbcSend, []byte("data"))
+	require.Equal(suite.T(), errRbcOrigin, node.evalRbc(notOrigin), "Send from non-origin should be rejected.")
+
+	tampered := suite.rbcMsg(peers[0].Id, peers[1].Id, seq, rbcEcho, []byte("data"))
+	tampered.Content = []byte("tampered")
+	require.Equal(suite.T(), errInvalidSignature, node.evalRbc(tampered), "Tampered message should be rejected.")
+
+	invalidPhase := suite.rbcMsg(peers[0].Id, peers[1].Id, seq, 10, []byte("data"))
+	require.Equal(suite.T(), errRbcPhase, node.evalRbc(invalidPhase), "Invalid phase should be rejected.")
+
+	raw, err := proto.Marshal(suite.rbcMsg(peers[0].Id, peers[1].Id, seq, rbcEcho, []byte("data")))
+	require.NoError(suite.T(), err, "Failed to marshal message.")
+
+	_, err = node.handleRbc(&Request{SenderId: peers[2].Id, Data: raw})
+	require.Equal(suite.T(), errRbcSender, err, "Relayed message should be rejected.")
+}
+
+func (suite *HandlerTestSuite) rbcMsg(origin, sender string, seq uint64, phase uint32, content []byte) *pb.RbcMsg {
+	msg := &pb.RbcMsg{
+		Origin:  []byte(origin),
+		Seq:     seq,
+		Phase:   phase,
+		Content: content,
+		Sender:  []byte(sender),
+	}
+
+	bytes, err := proto.Marshal(msg)
+	require.NoError(suite.T(), err, "Failed to marshal message.")
+
+	cs := &cryptoStub{priv: suite.privMap[sender]}
+	r, s, err := cs.Sign(bytes)
+	require.NoError(suite.T(), err, "Failed to sign message.")
+
+	msg.Signature = &pb.Signature{R: r, S: s}
+
+	return msg
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"crypto/x509"
+
+	"golang.org/x/net/context"
+)
+
+// Request describes incoming application data together with the identity of its sender.
+type Request struct {
+	// Context of the underlying rpc, cancelled if the sender gives up.
+	Context context.Context
+
+	// Ifrit id and rpc address of the sender, both taken from its certificate.
+	SenderId   string
+	SenderAddr string
+
+	// Certificate presented by the sender during the tls handshake.
+	Certificate *x509.Certificate
+
+	Data []byte
+}
+
+func newRequest(ctx context.Context, cert *x509.Certificate, data []byte) *Request {
+	r := &Request{
+		Context:     ctx,
+		SenderId:    string(cert.SubjectKeyId),
+		Certificate: cert,
+		Data:        data,
+	}
+
+	if len(cert.Subject.Locality) > 0 {
+		r.SenderAddr = cert.Subject.Locality[0]
+	}
+
+	return r
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"crypto/x509"
+	"sync"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit/core/discovery"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"golang.org/x/net/context"
+)
+
+// Handlers and gossip content registered under a topic.
+// The empty topic is never stored here, it refers to the default handlers on the node.
+type topicRegistry struct {
+	msgHandlers      map[string]processMsg
+	gossipHandlers   map[string]processMsg
+	responseHandlers map[string]processResponse
+	gossip           map[string][]byte
+
+	mutex sync.RWMutex
+}
+
+func newTopicRegistry() *topicRegistry {
+	return &topicRegistry{
+		msgHandlers:      make(map[string]processMsg),
+		gossipHandlers:   make(map[string]processMsg),
+		responseHandlers: make(map[string]processResponse),
+		gossip:           make(map[string][]byte),
+	}
+}
+
+// Registers the message handler for the given topic, a nil handler removes it.
+// The empty topic refers to the default message handler.
+func (n *Node) SetTopicMsgHandler(topic string, newHandler processMsg) {
+	if topic == "" {
+		n.SetMsgHandler(newHandler)
+		return
+	}
+
+	n.topics.mutex.Lock()
+	defer n.topics.mutex.Unlock()
+
+	if newHandler == nil {
+		delete(n.topics.msgHandlers, topic)
+	} else {
+		n.topics.msgHandlers[topic] = newHandler
+	}
+}
+
+// Registers the gossip handler for the given topic, a nil handler removes it.
+// The empty topic refers to the default gossip handler.
+func (n *Node) SetTopicGossipHandler(topic string, newHandler processMsg) {
+	if topic == "" {
+		n.SetGossipHandler(newHandler)
+		return
+	}
+
+	n.topics.mutex.Lock()
+	defer n.topics.mutex.Unlock()
+
+	if newHandler == nil {
+		delete(n.topics.gossipHandlers, topic)
+	} else {
+		n.topics.gossipHandlers[topic] = newHandler
+	}
+}
+
+// Registers the gossip response handler for the given topic, a nil handler removes it.
+// The empty topic refers to the default response handler.
+func (n *Node) SetTopicResponseHandler(topic string, newHandler processResponse) {
+	if topic == "" {
+		n.SetResponseHandler(newHandler)
+		return
+	}
+
+	n.topics.mutex.Lock()
+	defer n.topics.mutex.Unlock()
+
+	if newHandler == nil {
+		delete(n.topics.responseHandlers, topic)
+	} else {
+		n.topics.responseHandlers[topic] = newHandler
+	}
+}
+
+// Replaces the gossip content of the given topic, nil data stops gossiping the topic.
+// The empty topic refers to the default gossip content.
+func (n *Node) SetTopicGossipContent(topic string, data []byte) {
+	if topic == "" {
+		n.SetExternalGossipContent(data)
+		return
+	}
+
+	n.topics.mutex.Lock()
+	defer n.topics.mutex.Unlock()
+
+	if data == nil {
+		delete(n.topics.gossip, topic)
+	} else {
+		n.topics.gossip[topic] = data
+	}
+}
+
+// Sends the given data to the handler registered under topic at dest,
+// see SendMessageContext for details.
+// ErrUnknownTopic is returned if dest has no handler registered for the topic.
+func (n *Node) SendTopicMessageContext(ctx context.Context, dest, topic string, data []byte) ([]byte, error) {
+	msg := &pb.Msg{
+		Content: data,
+		Topic:   topic,
+	}
+
+	return n.sendMsgContext(ctx, dest, msg)
+}
+
+func (n *Node) topicMsgHandler(topic string) processMsg {
+	n.topics.mutex.RLock()
+	defer n.topics.mutex.RUnlock()
+
+	return n.topics.msgHandlers[topic]
+}
+
+func (n *Node) topicGossipHandler(topic string) processMsg {
+	n.topics.mutex.RLock()
+	defer n.topics.mutex.RUnlock()
+
+	return n.topics.gossipHandlers[topic]
+}
+
+func (n *Node) topicResponseHandler(topic string) processResponse {
+	n.topics.mutex.RLock()
+	defer n.topics.mutex.RUnlock()
+
+	return n.topics.responseHandlers[topic]
+}
+
+func (n *Node) topicGossipContent() map[string][]byte {
+	n.topics.mutex.RLock()
+	defer n.topics.mutex.RUnlock()
+
+	if len(n.topics.gossip) == 0 {
+		return nil
+	}
+
+	ret := make(map[string][]byte, len(n.topics.gossip))
+	for topic, data := range n.topics.gossip {
+		ret[topic] = data
+	}
+
+	return ret
+}
+
+// Topics without a local handler are ignored,
+// neighbours are not required to run the same applications.
+func (n *Node) handleTopicGossip(ctx context.Context, cert *x509.Certificate, gossip map[string][]byte, reply *pb.StateResponse) {
+	for topic, data := range gossip {
+		handler := n.topicGossipHandler(topic)
+		if handler == nil {
+			continue
+		}
+
+		resp, err := handler(newRequest(ctx, cert, data))
+		if err != nil {
+			log.Error(err.Error(), "topic", topic)
+			continue
+		}
+
+		if resp != nil {
+			if reply.TopicGossip == nil {
+				reply.TopicGossip = make(map[string][]byte)
+			}
+			reply.TopicGossip[topic] = resp
+		}
+	}
+}
+
+func (n *Node) handleTopicResponses(p *discovery.Peer, responses map[string][]byte) {
+	for topic, data := range responses {
+		handler := n.topicResponseHandler(topic)
+		if handler == nil {
+			continue
+		}
+
+		handler(&Request{
+			Context:     context.Background(),
+			SenderId:    p.Id,
+			SenderAddr:  p.Addr,
+			Certificate: p.X509(),
+			Data:        data,
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (suite *HandlerTestSuite) TestTopicMessenger() {
+	node := suite.n
+	p := node.view.Full()[0]
+
+	node.SetMsgHandler(func(r *Request) ([]byte, error) {
+		return []byte("default"), nil
+	})
+	defer node.SetMsgHandler(nil)
+
+	node.SetTopicMsgHandler("kv.put", func(r *Request) ([]byte, error) {
+		require.Equal(suite.T(), p.Id, r.SenderId, "Invalid sender id.")
+		return append([]byte("kv:"), r.Data...), nil
+	})
+	defer node.SetTopicMsgHandler("kv.put", nil)
+
+	resp, err := node.Messenger(peerContext(p), &proto.Msg{Content: []byte("data"), Topic: "kv.put"})
+	require.NoError(suite.T(), err, "Registered topic returned error.")
+	require.Equal(suite.T(), []byte("kv:data"), resp.GetContent(), "Message routed to wrong handler.")
+
+	resp, err = node.Messenger(peerContext(p), &proto.Msg{Content: []byte("data")})
+	require.NoError(suite.T(), err, "Default topic returned error.")
+	require.Equal(suite.T(), []byte("default"), resp.GetContent(), "Message without topic not routed to default handler.")
+
+	_, err = node.Messenger(peerContext(p), &proto.Msg{Content: []byte("data"), Topic: "kv.get"})
+	require.Equal(suite.T(), codes.Unimplemented, status.Code(err), "Unknown topic had invalid code.")
+
+	node.SetTopicMsgHandler("kv.put", nil)
+	_, err = node.Messenger(peerContext(p), &proto.Msg{Content: []byte("data"), Topic: "kv.put"})
+	require.Equal(suite.T(), codes.Unimplemented, status.Code(err), "Removed topic had invalid code.")
+}
+
+func (suite *HandlerTestSuite) TestTopicGossip() {
+	var responses []string
+
+	node := suite.n
+	p := node.view.Full()[0]
+
+	node.SetTopicGossipHandler("a", func(r *Request) ([]byte, error) {
+		return append([]byte("a:"), r.Data...), nil
+	})
+	defer node.SetTopicGossipHandler("a", nil)
+
+	node.SetTopicResponseHandler("a", func(r *Request) {
+		require.Equal(suite.T(), p.Id, r.SenderId, "Invalid sender id.")
+		responses = append(responses, string(r.Data))
+	})
+	defer node.SetTopicResponseHandler("a", nil)
+
+	reply := &proto.StateResponse{}
+	gossip := map[string][]byte{
+		"a": []byte("1"),
+		"b": []byte("2"),
+	}
+
+	node.handleTopicGossip(peerContext(p), p.X509(), gossip, reply)
+	require.Equal(suite.T(), map[string][]byte{"a": []byte("a:1")}, reply.GetTopicGossip(),
+		"Only topics with a handler should be answered.")
+
+	node.handleTopicResponses(p, reply.GetTopicGossip())
+	require.Equal(suite.T(), []string{"a:1"}, responses, "Invalid responses.")
+
+	require.Nil(suite.T(), node.topicGossipContent(), "Gossip content should be empty.")
+
+	node.SetTopicGossipContent("a", []byte("content"))
+	node.SetTopicGossipContent("b", []byte("other"))
+	node.SetTopicGossipContent("b", nil)
+
+	state := node.collectGossipContent()
+	require.Equal(suite.T(), map[string][]byte{"a": []byte("content")}, state.GetTopicGossip(),
+		"Invalid topic gossip content.")
+
+	node.SetTopicGossipContent("a", nil)
+}
@@ -0,0 +1,166 @@
+package crdt
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// GCounter is a grow-only counter, each member increments its own entry
+// and merging keeps the highest entry of each member.
+type GCounter struct {
+	r *Replica
+
+	counts map[string]uint64
+
+	mutex sync.RWMutex
+}
+
+func newGCounter(r *Replica) *GCounter {
+	return &GCounter{
+		r:      r,
+		counts: make(map[string]uint64),
+	}
+}
+
+// Increments the counter by delta.
+func (g *GCounter) Inc(delta uint64) {
+	g.mutex.Lock()
+	g.counts[g.r.id] += delta
+	g.mutex.Unlock()
+
+	g.r.publish()
+}
+
+// Returns the sum of the increments of all members.
+func (g *GCounter) Value() uint64 {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	var sum uint64
+
+	for _, c := range g.counts {
+		sum += c
+	}
+
+	return sum
+}
+
+func (g *GCounter) kind() string {
+	return kindGCounter
+}
+
+func (g *GCounter) marshal() ([]byte, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return json.Marshal(g.counts)
+}
+
+func (g *GCounter) merge(data []byte) (bool, error) {
+	other := make(map[string]uint64)
+
+	if err := json.Unmarshal(data, &other); err != nil {
+		return false, err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return mergeCounts(g.counts, other), nil
+}
+
+// PNCounter is a counter supporting both increments and decrements,
+// kept as a pair of grow-only counters.
+type PNCounter struct {
+	r *Replica
+
+	inc map[string]uint64
+	dec map[string]uint64
+
+	mutex sync.RWMutex
+}
+
+type pnState struct {
+	Inc map[string]uint64 `json:"inc"`
+	Dec map[string]uint64 `json:"dec"`
+}
+
+func newPNCounter(r *Replica) *PNCounter {
+	return &PNCounter{
+		r:   r,
+		inc: make(map[string]uint64),
+		dec: make(map[string]uint64),
+	}
+}
+
+// Adds delta to the counter, negative values decrement it.
+func (p *PNCounter) Add(delta int64) {
+	p.mutex.Lock()
+	if delta >= 0 {
+		p.inc[p.r.id] += uint64(delta)
+	} else {
+		p.dec[p.r.id] += uint64(-delta)
+	}
+	p.mutex.Unlock()
+
+	p.r.publish()
+}
+
+// Returns the sum of the increments minus the sum of the decrements of all members.
+func (p *PNCounter) Value() int64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var sum int64
+
+	for _, c := range p.inc {
+		sum += int64(c)
+	}
+
+	for _, c := range p.dec {
+		sum -= int64(c)
+	}
+
+	return sum
+}
+
+func (p *PNCounter) kind() string {
+	return kindPNCounter
+}
+
+func (p *PNCounter) marshal() ([]byte, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return json.Marshal(&pnState{Inc: p.inc, Dec: p.dec})
+}
+
+func (p *PNCounter) merge(data []byte) (bool, error) {
+	other := &pnState{}
+
+	if err := json.Unmarshal(data, other); err != nil {
+		return false, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	inc := mergeCounts(p.inc, other.Inc)
+	dec := mergeCounts(p.dec, other.Dec)
+
+	return inc || dec, nil
+}
+
+// Keeps the highest count of each member, returns true if local counts changed.
+func mergeCounts(local, other map[string]uint64) bool {
+	var changed bool
+
+	for id, c := range other {
+		if c > local[id] {
+			local[id] = c
+			changed = true
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,229 @@
+package crdt
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/joonnna/ifrit"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Holds the gossip content and handlers registered by a replica.
+type testClient struct {
+	id      string
+	content []byte
+
+	gossipHandler   func(*ifrit.Request) ([]byte, error)
+	responseHandler func(*ifrit.Request)
+}
+
+func (c *testClient) Id() string {
+	return c.id
+}
+
+func (c *testClient) SetGossipContentFor(topic string, data []byte) error {
+	c.content = data
+	return nil
+}
+
+func (c *testClient) RegisterGossipHandlerFor(topic string, handler func(*ifrit.Request) ([]byte, error)) error {
+	c.gossipHandler = handler
+	return nil
+}
+
+func (c *testClient) RegisterResponseHandlerFor(topic string, handler func(*ifrit.Request)) error {
+	c.responseHandler = handler
+	return nil
+}
+
+type CrdtTestSuite struct {
+	suite.Suite
+
+	clients  []*testClient
+	replicas []*Replica
+}
+
+func TestCrdtTestSuite(t *testing.T) {
+	suite.Run(t, new(CrdtTestSuite))
+}
+
+func (suite *CrdtTestSuite) SetupTest() {
+	suite.clients = nil
+	suite.replicas = nil
+
+	for i := 0; i < 3; i++ {
+		// Binary like Ifrit ids, which are not valid UTF-8.
+		id := sha256.Sum256([]byte(fmt.Sprintf("id%d", i)))
+		c := &testClient{id: string(id[:])}
+
+		suite.clients = append(suite.clients, c)
+		r, err := NewReplica(c)
+		require.NoError(suite.T(), err, "Failed to create replica.")
+
+		suite.replicas = append(suite.replicas, r)
+	}
+}
+
+// Performs a push-pull gossip exchange from the replica at index i to the replica at index j.
+func (suite *CrdtTestSuite) exchange(i, j int) {
+	from, to := suite.clients[i], suite.clients[j]
+
+	if from.content == nil {
+		return
+	}
+
+	resp, err := to.gossipHandler(&ifrit.Request{SenderId: from.id, Data: from.content})
+	require.NoError(suite.T(), err, "Gossip handler failed.")
+
+	from.responseHandler(&ifrit.Request{SenderId: to.id, Data: resp})
+}
+
+// Exchanges state between all pairs of replicas.
+func (suite *CrdtTestSuite) converge() {
+	for i := range suite.clients {
+		for j := range suite.clients {
+			if i != j {
+				suite.exchange(i, j)
+			}
+		}
+	}
+}
+
+func (suite *CrdtTestSuite) TestReplica() {
+	r := suite.replicas[0]
+
+	_, err := r.GCounter("")
+	require.Equal(suite.T(), errNoName, err, "Empty name should be rejected.")
+
+	g, err := r.GCounter("counter")
+	require.NoError(suite.T(), err, "Failed to create counter.")
+
+	same, err := r.GCounter("counter")
+	require.NoError(suite.T(), err, "Failed to get counter.")
+	require.Equal(suite.T(), g, same, "Should return existing counter.")
+
+	_, err = r.ORSet("counter")
+	require.Equal(suite.T(), errKindChanged, err, "Should reject name used by another type.")
+
+	_, err = r.merge([]byte("invalid"))
+	require.Error(suite.T(), err, "Should reject malformed state.")
+}
+
+func (suite *CrdtTestSuite) TestGCounter() {
+	var changed []string
+
+	suite.replicas[1].SetChangeHandler(func(name string) {
+		changed = append(changed, name)
+	})
+
+	for i, r := range suite.replicas {
+		g, err := r.GCounter("visits")
+		require.NoError(suite.T(), err, "Failed to create counter.")
+		g.Inc(uint64(i + 1))
+	}
+
+	suite.converge()
+
+	for _, r := range suite.replicas {
+		g, err := r.GCounter("visits")
+		require.NoError(suite.T(), err, "Failed to get counter.")
+		require.Equal(suite.T(), uint64(6), g.Value(), "Counter did not converge.")
+	}
+
+	require.Contains(suite.T(), changed, "visits", "Change handler was not invoked.")
+
+	// Increments after merging the own state back must not be counted twice.
+	g, err := suite.replicas[0].GCounter("visits")
+	require.NoError(suite.T(), err, "Failed to get counter.")
+	g.Inc(1)
+
+	suite.converge()
+	require.Equal(suite.T(), uint64(7), g.Value(), "Own increments were counted twice.")
+
+	// Repeated exchanges without local changes are idempotent.
+	changed = nil
+	suite.converge()
+	require.Empty(suite.T(), changed, "Handler invoked without change.")
+}
+
+func (suite *CrdtTestSuite) TestPNCounter() {
+	p0, err := suite.replicas[0].PNCounter("balance")
+	require.NoError(suite.T(), err, "Failed to create counter.")
+
+	p1, err := suite.replicas[1].PNCounter("balance")
+	require.NoError(suite.T(), err, "Failed to create counter.")
+
+	p0.Add(10)
+	p1.Add(-3)
+	p1.Add(-2)
+
+	suite.converge()
+
+	for _, r := range suite.replicas {
+		p, err := r.PNCounter("balance")
+		require.NoError(suite.T(), err, "Type created by others should exist locally.")
+		require.Equal(suite.T(), int64(5), p.Value(), "Counter did not converge.")
+	}
+}
+
+func (suite *CrdtTestSuite) TestORSet() {
+	s0, err := suite.replicas[0].ORSet("members")
+	require.NoError(suite.T(), err, "Failed to create set.")
+
+	s0.Add("a")
+	s0.Add("b")
+
+	suite.converge()
+
+	s1, err := suite.replicas[1].ORSet("members")
+	require.NoError(suite.T(), err, "Failed to get set.")
+	require.Equal(suite.T(), []string{"a", "b"}, s1.Elements(), "Set did not converge.")
+
+	// Concurrent add and remove of the same element, the add wins.
+	s1.Remove("a")
+	s0.Add("a")
+	s1.Remove("b")
+
+	suite.converge()
+
+	for _, r := range suite.replicas {
+		s, err := r.ORSet("members")
+		require.NoError(suite.T(), err, "Failed to get set.")
+		require.True(suite.T(), s.Contains("a"), "Concurrent add should survive remove.")
+		require.False(suite.T(), s.Contains("b"), "Removed element still present.")
+		require.Equal(suite.T(), []string{"a"}, s.Elements(), "Set did not converge.")
+	}
+}
+
+func (suite *CrdtTestSuite) TestLWWMap() {
+	m0, err := suite.replicas[0].LWWMap("config")
+	require.NoError(suite.T(), err, "Failed to create map.")
+
+	m1, err := suite.replicas[1].LWWMap("config")
+	require.NoError(suite.T(), err, "Failed to create map.")
+
+	m0.Set("mode", []byte("old"))
+	m0.Set("level", []byte("1"))
+
+	suite.converge()
+
+	m1.Set("mode", []byte("new"))
+	m1.Delete("level")
+
+	suite.converge()
+
+	for _, r := range suite.replicas {
+		m, err := r.LWWMap("config")
+		require.NoError(suite.T(), err, "Failed to get map.")
+
+		value, ok := m.Get("mode")
+		require.True(suite.T(), ok, "Key should be set.")
+		require.Equal(suite.T(), []byte("new"), value, "Most recent write should win.")
+
+		_, ok = m.Get("level")
+		require.False(suite.T(), ok, "Deleted key should not be set.")
+		require.Equal(suite.T(), []string{"mode"}, m.Keys(), "Map did not converge.")
+	}
+}
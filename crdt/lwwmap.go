@@ -0,0 +1,141 @@
+package crdt
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LWWMap is a map from strings to values where the most recent write of each key wins.
+// Writes are ordered by the wall clock of their writer, ties are broken by writer id.
+type LWWMap struct {
+	r *Replica
+
+	entries map[string]*lwwEntry
+
+	mutex sync.RWMutex
+}
+
+type lwwEntry struct {
+	Value     []byte `json:"value,omitempty"`
+	Timestamp int64  `json:"ts"`
+	Writer    string `json:"writer"`
+	Deleted   bool   `json:"deleted,omitempty"`
+}
+
+func newLWWMap(r *Replica) *LWWMap {
+	return &LWWMap{
+		r:       r,
+		entries: make(map[string]*lwwEntry),
+	}
+}
+
+// Sets the value of key.
+func (m *LWWMap) Set(key string, value []byte) {
+	m.write(key, value, false)
+}
+
+// Deletes key, the deletion is kept so it overrides older writes of other members.
+func (m *LWWMap) Delete(key string) {
+	m.write(key, nil, true)
+}
+
+// Returns the value of key and whether it is set.
+func (m *LWWMap) Get(key string) ([]byte, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	e, ok := m.entries[key]
+	if !ok || e.Deleted {
+		return nil, false
+	}
+
+	return e.Value, true
+}
+
+// Returns all keys that are set in sorted order.
+func (m *LWWMap) Keys() []string {
+	var ret []string
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for key, e := range m.entries {
+		if !e.Deleted {
+			ret = append(ret, key)
+		}
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+func (m *LWWMap) write(key string, value []byte, deleted bool) {
+	m.mutex.Lock()
+
+	e := &lwwEntry{
+		Value:     value,
+		Timestamp: time.Now().UnixNano(),
+		Writer:    m.r.id,
+		Deleted:   deleted,
+	}
+
+	// Local writes must win over every write already observed,
+	// even if the clock of another member is ahead of ours.
+	if prev, ok := m.entries[key]; ok && prev.Timestamp >= e.Timestamp {
+		e.Timestamp = prev.Timestamp + 1
+	}
+
+	m.entries[key] = e
+
+	m.mutex.Unlock()
+
+	m.r.publish()
+}
+
+func (m *LWWMap) kind() string {
+	return kindLWWMap
+}
+
+func (m *LWWMap) marshal() ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return json.Marshal(m.entries)
+}
+
+func (m *LWWMap) merge(data []byte) (bool, error) {
+	var changed bool
+
+	other := make(map[string]*lwwEntry)
+
+	if err := json.Unmarshal(data, &other); err != nil {
+		return false, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for key, e := range other {
+		if e == nil {
+			continue
+		}
+
+		if prev, ok := m.entries[key]; !ok || e.newer(prev) {
+			m.entries[key] = e
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+func (e *lwwEntry) newer(other *lwwEntry) bool {
+	if e.Timestamp != other.Timestamp {
+		return e.Timestamp > other.Timestamp
+	}
+
+	return e.Writer > other.Writer
+}
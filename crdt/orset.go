@@ -0,0 +1,165 @@
+package crdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ORSet is an observed-remove set of strings.
+// Each add is tagged uniquely and a remove only covers the tags observed locally,
+// an element added concurrently with its removal therefore stays in the set.
+type ORSet struct {
+	r *Replica
+
+	// Tags of each element and the tags of all removed adds.
+	adds    map[string]map[string]bool
+	removed map[string]bool
+
+	seq uint64
+
+	mutex sync.RWMutex
+}
+
+type orSetState struct {
+	Adds    map[string][]string `json:"adds"`
+	Removed []string            `json:"removed"`
+}
+
+func newORSet(r *Replica) *ORSet {
+	return &ORSet{
+		r:       r,
+		adds:    make(map[string]map[string]bool),
+		removed: make(map[string]bool),
+	}
+}
+
+// Adds elem to the set.
+func (s *ORSet) Add(elem string) {
+	s.mutex.Lock()
+
+	s.seq++
+	// The time component keeps tags unique across restarts of the same member.
+	tag := fmt.Sprintf("%s/%d/%d", s.r.id, time.Now().UnixNano(), s.seq)
+
+	if _, ok := s.adds[elem]; !ok {
+		s.adds[elem] = make(map[string]bool)
+	}
+	s.adds[elem][tag] = true
+
+	s.mutex.Unlock()
+
+	s.r.publish()
+}
+
+// Removes elem from the set, adds of other members not yet observed are kept.
+func (s *ORSet) Remove(elem string) {
+	s.mutex.Lock()
+
+	for tag := range s.adds[elem] {
+		s.removed[tag] = true
+	}
+
+	s.mutex.Unlock()
+
+	s.r.publish()
+}
+
+// Returns true if the set holds elem.
+func (s *ORSet) Contains(elem string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.contains(elem)
+}
+
+// Returns all elements of the set in sorted order.
+func (s *ORSet) Elements() []string {
+	var ret []string
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for elem := range s.adds {
+		if s.contains(elem) {
+			ret = append(ret, elem)
+		}
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+// Must hold the set lock.
+func (s *ORSet) contains(elem string) bool {
+	for tag := range s.adds[elem] {
+		if !s.removed[tag] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *ORSet) kind() string {
+	return kindORSet
+}
+
+func (s *ORSet) marshal() ([]byte, error) {
+	state := &orSetState{
+		Adds: make(map[string][]string),
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for elem, tags := range s.adds {
+		for tag := range tags {
+			state.Adds[elem] = append(state.Adds[elem], tag)
+		}
+	}
+
+	for tag := range s.removed {
+		state.Removed = append(state.Removed, tag)
+	}
+
+	return json.Marshal(state)
+}
+
+func (s *ORSet) merge(data []byte) (bool, error) {
+	var changed bool
+
+	other := &orSetState{}
+
+	if err := json.Unmarshal(data, other); err != nil {
+		return false, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for elem, tags := range other.Adds {
+		if _, ok := s.adds[elem]; !ok {
+			s.adds[elem] = make(map[string]bool)
+		}
+
+		for _, tag := range tags {
+			if !s.adds[elem][tag] {
+				s.adds[elem][tag] = true
+				changed = true
+			}
+		}
+	}
+
+	for _, tag := range other.Removed {
+		if !s.removed[tag] {
+			s.removed[tag] = true
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,281 @@
+package crdt
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit"
+)
+
+const (
+	// Topic under which replica state is gossiped.
+	Topic = "crdt"
+)
+
+const (
+	kindGCounter  = "gcounter"
+	kindPNCounter = "pncounter"
+	kindORSet     = "orset"
+	kindLWWMap    = "lwwmap"
+)
+
+var (
+	errNoName      = errors.New("Name is empty")
+	errKindChanged = errors.New("Name is already used by a different type")
+	errUnknownKind = errors.New("Unknown replicated type")
+)
+
+// Client is the part of the Ifrit client used by a replica, satisfied by *ifrit.Client.
+type Client interface {
+	Id() string
+	SetGossipContentFor(topic string, data []byte) error
+	RegisterGossipHandlerFor(topic string, handler func(*ifrit.Request) ([]byte, error)) error
+	RegisterResponseHandlerFor(topic string, handler func(*ifrit.Request)) error
+}
+
+// Replica holds named replicated types and keeps them in sync with the replicas of other members.
+// The state of all types is gossiped to neighbours in every gossip round, both sides of an
+// exchange merge the state of the other. Types created by other members are created locally
+// on receipt, so all members eventually hold the same state.
+type Replica struct {
+	c Client
+
+	// Hex encoded Ifrit id of the local member, ids are binary and the state is JSON encoded.
+	id string
+
+	objects map[string]object
+	handler func(string)
+
+	mutex sync.RWMutex
+}
+
+type object interface {
+	kind() string
+	marshal() ([]byte, error)
+
+	// Returns true if the local state changed.
+	merge(data []byte) (bool, error)
+}
+
+type wireObject struct {
+	Kind  string          `json:"kind"`
+	State json.RawMessage `json:"state"`
+}
+
+// Creates a replica on top of the given client and registers its gossip handlers.
+func NewReplica(c Client) (*Replica, error) {
+	r := &Replica{
+		c:       c,
+		id:      hex.EncodeToString([]byte(c.Id())),
+		objects: make(map[string]object),
+	}
+
+	if err := c.RegisterGossipHandlerFor(Topic, r.handleGossip); err != nil {
+		return nil, err
+	}
+
+	if err := c.RegisterResponseHandlerFor(Topic, r.handleResponse); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Registers the handler invoked with the name of a type each time merging
+// the state of another member changed it. Local changes do not invoke the handler.
+func (r *Replica) SetChangeHandler(handler func(name string)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handler = handler
+}
+
+// Returns the grow-only counter with the given name, creating it if it does not exist.
+func (r *Replica) GCounter(name string) (*GCounter, error) {
+	o, err := r.get(name, kindGCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.(*GCounter), nil
+}
+
+// Returns the counter with the given name, creating it if it does not exist.
+func (r *Replica) PNCounter(name string) (*PNCounter, error) {
+	o, err := r.get(name, kindPNCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.(*PNCounter), nil
+}
+
+// Returns the set with the given name, creating it if it does not exist.
+func (r *Replica) ORSet(name string) (*ORSet, error) {
+	o, err := r.get(name, kindORSet)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.(*ORSet), nil
+}
+
+// Returns the map with the given name, creating it if it does not exist.
+func (r *Replica) LWWMap(name string) (*LWWMap, error) {
+	o, err := r.get(name, kindLWWMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.(*LWWMap), nil
+}
+
+func (r *Replica) get(name, kind string) (object, error) {
+	if name == "" {
+		return nil, errNoName
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if o, ok := r.objects[name]; ok {
+		if o.kind() != kind {
+			return nil, errKindChanged
+		}
+		return o, nil
+	}
+
+	o, err := r.newObject(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	r.objects[name] = o
+
+	return o, nil
+}
+
+// Must hold the replica lock.
+func (r *Replica) newObject(kind string) (object, error) {
+	switch kind {
+	case kindGCounter:
+		return newGCounter(r), nil
+	case kindPNCounter:
+		return newPNCounter(r), nil
+	case kindORSet:
+		return newORSet(r), nil
+	case kindLWWMap:
+		return newLWWMap(r), nil
+	default:
+		return nil, errUnknownKind
+	}
+}
+
+// Replaces the gossiped state with the current state of all types, invoked after every local change.
+func (r *Replica) publish() {
+	data, err := r.state()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	if err := r.c.SetGossipContentFor(Topic, data); err != nil {
+		log.Error(err.Error())
+	}
+}
+
+func (r *Replica) state() ([]byte, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	all := make(map[string]wireObject, len(r.objects))
+
+	for name, o := range r.objects {
+		state, err := o.marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		all[name] = wireObject{
+			Kind:  o.kind(),
+			State: state,
+		}
+	}
+
+	return json.Marshal(all)
+}
+
+// Merges the state of another member into the local types,
+// returns the names of all types that changed.
+func (r *Replica) merge(data []byte) ([]string, error) {
+	var changed []string
+
+	all := make(map[string]wireObject)
+
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	for name, w := range all {
+		o, err := r.get(name, w.Kind)
+		if err != nil {
+			log.Debug(err.Error(), "name", name, "kind", w.Kind)
+			continue
+		}
+
+		updated, err := o.merge(w.State)
+		if err != nil {
+			log.Debug(err.Error(), "name", name)
+			continue
+		}
+
+		if updated {
+			changed = append(changed, name)
+		}
+	}
+
+	return changed, nil
+}
+
+// Merges the state of the gossiping neighbour and replies with the merged local state.
+func (r *Replica) handleGossip(req *ifrit.Request) ([]byte, error) {
+	if err := r.mergeAndNotify(req.Data); err != nil {
+		return nil, err
+	}
+
+	return r.state()
+}
+
+func (r *Replica) handleResponse(req *ifrit.Request) {
+	if err := r.mergeAndNotify(req.Data); err != nil {
+		log.Debug(err.Error(), "sender", req.SenderAddr)
+	}
+}
+
+func (r *Replica) mergeAndNotify(data []byte) error {
+	changed, err := r.merge(data)
+	if err != nil {
+		return err
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	r.publish()
+
+	r.mutex.RLock()
+	handler := r.handler
+	r.mutex.RUnlock()
+
+	if handler != nil {
+		for _, name := range changed {
+			handler(name)
+		}
+	}
+
+	return nil
+}
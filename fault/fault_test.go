@@ -0,0 +1,257 @@
+package fault
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+var errStub = errors.New("Stub error")
+
+// Records the destinations of all calls and pings that got through.
+type stubService struct {
+	addr  string
+	calls []string
+
+	mutex sync.Mutex
+}
+
+func (s *stubService) record(addr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, addr)
+}
+
+func (s *stubService) numCalls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.calls)
+}
+
+func (s *stubService) Register(pb.GossipServer) {}
+func (s *stubService) CloseConn(string)         {}
+func (s *stubService) Addr() string             { return s.addr }
+func (s *stubService) Start()                   {}
+func (s *stubService) Stop()                    {}
+func (s *stubService) Pause(time.Duration)      {}
+
+func (s *stubService) Gossip(addr string, args *pb.State) (*pb.StateResponse, error) {
+	s.record(addr)
+	return &pb.StateResponse{}, nil
+}
+
+func (s *stubService) Send(ctx context.Context, addr string, args *pb.Msg) (*pb.MsgResponse, error) {
+	s.record(addr)
+	return &pb.MsgResponse{}, nil
+}
+
+func (s *stubService) StreamMessenger(addr string, input, reply chan []byte) error {
+	s.record(addr)
+	return errStub
+}
+
+func (s *stubService) Ping(addr string, p *pb.Ping) (*pb.Pong, error) {
+	s.record(addr)
+	return &pb.Pong{}, nil
+}
+
+// Clock advanced by the test, After fires immediately and records the requested duration.
+type testClock struct {
+	now    time.Time
+	waited []time.Duration
+
+	mutex sync.Mutex
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.waited = append(c.waited, d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+
+	return ch
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newTestInjector() (*Injector, *testClock) {
+	in := NewInjector(1)
+	c := &testClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+
+	in.SetClock(c)
+
+	in.AddNode("a", "a:rpc", "a:ping")
+	in.AddNode("b", "b:rpc", "b:ping")
+	in.AddNode("c", "c:rpc", "c:ping")
+
+	return in, c
+}
+
+func TestPartition(t *testing.T) {
+	in, _ := newTestInjector()
+
+	require.Equal(t, []string{"a", "b", "c"}, in.Nodes(), "Invalid registered nodes.")
+
+	stubA := &stubService{addr: "a:rpc"}
+	stubB := &stubService{addr: "b:rpc"}
+
+	a := in.Transport("a", stubA)
+	b := in.Transport("b", stubB)
+	pingA := in.Pinger("a", stubA)
+
+	in.Partition([]string{"a"}, []string{"b"})
+
+	_, err := a.Gossip("b:rpc", &pb.State{})
+	require.Equal(t, ErrDropped, err, "Partitioned call should be dropped.")
+
+	_, err = pingA.Ping("b:ping", &pb.Ping{})
+	require.Equal(t, ErrDropped, err, "Partitioned ping should be dropped.")
+
+	_, err = a.Send(context.Background(), "c:rpc", &pb.Msg{})
+	require.NoError(t, err, "Other links should not be affected.")
+
+	_, err = a.Gossip("unknown:rpc", &pb.State{})
+	require.NoError(t, err, "Unregistered addresses should not be affected.")
+
+	// Partitions are asymmetric.
+	_, err = b.Gossip("a:rpc", &pb.State{})
+	require.NoError(t, err, "Reverse direction should not be affected.")
+
+	in.Split([]string{"a"}, []string{"b"})
+
+	_, err = b.Gossip("a:rpc", &pb.State{})
+	require.Equal(t, ErrDropped, err, "Split should drop both directions.")
+
+	in.Heal([]string{"b"}, []string{"a"})
+
+	_, err = b.Gossip("a:rpc", &pb.State{})
+	require.NoError(t, err, "Healed link should not drop calls.")
+
+	_, err = a.Gossip("b:rpc", &pb.State{})
+	require.Equal(t, ErrDropped, err, "Healing one direction should not affect the other.")
+
+	in.HealAll()
+
+	_, err = a.Gossip("b:rpc", &pb.State{})
+	require.NoError(t, err, "All links should be healed.")
+
+	require.Equal(t, []string{"c:rpc", "unknown:rpc", "b:rpc"}, stubA.calls, "Dropped calls should not reach the transport.")
+}
+
+func TestLoss(t *testing.T) {
+	in, _ := newTestInjector()
+
+	stub := &stubService{addr: "a:rpc"}
+	a := in.Transport("a", stub)
+
+	require.Error(t, in.SetLoss([]string{"a"}, []string{"b"}, 1.5), "Invalid probability should be rejected.")
+
+	require.NoError(t, in.SetLoss([]string{"a"}, []string{"b"}, 0.5), "Failed to set loss.")
+
+	for i := 0; i < 1000; i++ {
+		a.Gossip("b:rpc", &pb.State{})
+	}
+
+	require.InDelta(t, 500, stub.numCalls(), 100, "Roughly half of all calls should be dropped.")
+
+	require.NoError(t, in.SetLoss([]string{"a"}, []string{"b"}, 1), "Failed to set loss.")
+
+	_, err := a.Gossip("b:rpc", &pb.State{})
+	require.Equal(t, ErrDropped, err, "All calls should be dropped.")
+}
+
+func TestLatency(t *testing.T) {
+	in, c := newTestInjector()
+
+	a := in.Transport("a", &stubService{addr: "a:rpc"})
+
+	in.SetLatency([]string{"a"}, []string{"b", "c"}, Fixed(time.Millisecond*20))
+
+	_, err := a.Gossip("b:rpc", &pb.State{})
+	require.NoError(t, err, "Delayed call should succeed.")
+
+	_, err = a.Send(context.Background(), "c:rpc", &pb.Msg{})
+	require.NoError(t, err, "Delayed call should succeed.")
+
+	require.Equal(t, []time.Duration{time.Millisecond * 20, time.Millisecond * 20}, c.waited, "Calls were not delayed.")
+
+	in.SetLatency([]string{"a"}, []string{"b"}, Uniform(time.Millisecond, time.Millisecond*2))
+
+	for i := 0; i < 100; i++ {
+		d, err := in.Check("a", "b")
+		require.NoError(t, err, "Check failed.")
+		require.True(t, d >= time.Millisecond && d < time.Millisecond*2, "Sample out of range.")
+	}
+
+	in.SetLatency([]string{"a"}, []string{"b"}, Normal(0, time.Millisecond))
+
+	for i := 0; i < 100; i++ {
+		d, _ := in.Check("a", "b")
+		require.True(t, d >= 0, "Negative samples should be clamped.")
+	}
+
+	// Cancelled calls return while waiting for the latency to pass.
+	in.SetClock(clockFunc(func(d time.Duration) <-chan time.Time {
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = a.Send(ctx, "c:rpc", &pb.Msg{})
+	require.Equal(t, context.Canceled, err, "Cancelled call should return context error.")
+}
+
+func TestFlap(t *testing.T) {
+	in, c := newTestInjector()
+
+	a := in.Pinger("a", &stubService{addr: "a:rpc"})
+
+	require.Error(t, in.Flap([]string{"a"}, []string{"b"}, 0, time.Second), "Invalid periods should be rejected.")
+	require.NoError(t, in.Flap([]string{"a"}, []string{"b"}, time.Second*2, time.Second), "Failed to set flapping.")
+
+	expected := []bool{true, true, false, true, true, false}
+
+	for i, up := range expected {
+		_, err := a.Ping("b:ping", &pb.Ping{})
+		if up {
+			require.NoErrorf(t, err, "Link should be up after %d seconds.", i)
+		} else {
+			require.Equalf(t, ErrDropped, err, "Link should be down after %d seconds.", i)
+		}
+
+		c.advance(time.Second)
+	}
+}
+
+type clockFunc func(time.Duration) <-chan time.Time
+
+func (f clockFunc) Now() time.Time {
+	return time.Time{}
+}
+
+func (f clockFunc) After(d time.Duration) <-chan time.Time {
+	return f(d)
+}
@@ -0,0 +1,264 @@
+package fault
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joonnna/ifrit/core/discovery"
+)
+
+var (
+	// Returned for calls and pings dropped by the injector.
+	ErrDropped = errors.New("Dropped by fault injection")
+
+	errInvalidLoss = errors.New("Loss probability must be within [0, 1]")
+	errInvalidFlap = errors.New("Flapping periods must be positive")
+)
+
+// Injector holds the faults of all links between named nodes and decides the fate of each call.
+// Faults apply in one direction, to calls and pings from a node to another.
+// Calls to addresses that are not registered, see AddNode, are never affected.
+// All methods can be invoked at runtime while the wrapped services are in use.
+type Injector struct {
+	// Names of the nodes indexed by their addresses.
+	nodes map[string]string
+	links map[link]*faults
+
+	clock discovery.Clock
+	rand  *rand.Rand
+
+	mutex sync.Mutex
+}
+
+type link struct {
+	from string
+	to   string
+}
+
+type faults struct {
+	loss    float64
+	latency Distribution
+	down    bool
+
+	// Link alternates between being up and down, starting up at flapStart.
+	flapUp    time.Duration
+	flapDown  time.Duration
+	flapStart time.Time
+}
+
+// Creates an injector without faults, seed determines which calls are dropped and their latency.
+func NewInjector(seed int64) *Injector {
+	return &Injector{
+		nodes: make(map[string]string),
+		links: make(map[link]*faults),
+		clock: discovery.RealClock(),
+		rand:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Replaces the clock used for latency and flapping, e.g with the virtual clock of a simulation.
+func (in *Injector) SetClock(c discovery.Clock) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	in.clock = c
+}
+
+// Registers the addresses (rpc and ping) the named node is reached at.
+func (in *Injector) AddNode(name string, addrs ...string) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	for _, addr := range addrs {
+		in.nodes[addr] = name
+	}
+}
+
+// Returns the names of all registered nodes in sorted order.
+func (in *Injector) Nodes() []string {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	exists := make(map[string]bool)
+
+	for _, name := range in.nodes {
+		exists[name] = true
+	}
+
+	ret := make([]string, 0, len(exists))
+
+	for name := range exists {
+		ret = append(ret, name)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+// Drops calls and pings between the given nodes with probability p.
+func (in *Injector) SetLoss(from, to []string, p float64) error {
+	if p < 0 || p > 1 {
+		return errInvalidLoss
+	}
+
+	in.update(from, to, func(f *faults) {
+		f.loss = p
+	})
+
+	return nil
+}
+
+// Delays calls and pings between the given nodes by samples of d, nil removes the delay.
+func (in *Injector) SetLatency(from, to []string, d Distribution) {
+	in.update(from, to, func(f *faults) {
+		f.latency = d
+	})
+}
+
+// Drops all calls and pings from the first set of nodes to the second, but not the other way around.
+func (in *Injector) Partition(from, to []string) {
+	in.update(from, to, func(f *faults) {
+		f.down = true
+	})
+}
+
+// Drops all calls and pings between the two sets of nodes in both directions.
+func (in *Injector) Split(a, b []string) {
+	in.Partition(a, b)
+	in.Partition(b, a)
+}
+
+// Lets the links between the given nodes alternate between being up for up and down for down,
+// starting with the up period.
+func (in *Injector) Flap(from, to []string, up, down time.Duration) error {
+	if up <= 0 || down <= 0 {
+		return errInvalidFlap
+	}
+
+	now := in.now()
+
+	in.update(from, to, func(f *faults) {
+		f.flapUp = up
+		f.flapDown = down
+		f.flapStart = now
+	})
+
+	return nil
+}
+
+// Removes all faults from the links between the given nodes.
+func (in *Injector) Heal(from, to []string) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	for _, src := range from {
+		for _, dst := range to {
+			delete(in.links, link{from: src, to: dst})
+		}
+	}
+}
+
+// Removes all faults.
+func (in *Injector) HealAll() {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	in.links = make(map[link]*faults)
+}
+
+// Decides the fate of a call or ping from the named node to another.
+// Returns the latency to apply, or ErrDropped if it is lost.
+func (in *Injector) Check(from, to string) (time.Duration, error) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	f, ok := in.links[link{from: from, to: to}]
+	if !ok {
+		return 0, nil
+	}
+
+	if f.down {
+		return 0, ErrDropped
+	}
+
+	if f.flapUp > 0 {
+		phase := in.clock.Now().Sub(f.flapStart) % (f.flapUp + f.flapDown)
+		if phase >= f.flapUp {
+			return 0, ErrDropped
+		}
+	}
+
+	if f.loss > 0 && in.rand.Float64() < f.loss {
+		return 0, ErrDropped
+	}
+
+	if f.latency == nil {
+		return 0, nil
+	}
+
+	d := f.latency.Sample(in.rand)
+	if d < 0 {
+		d = 0
+	}
+
+	return d, nil
+}
+
+// Returns the name of the node reached at addr, empty if not registered.
+func (in *Injector) name(addr string) string {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	return in.nodes[addr]
+}
+
+// Waits for d on the clock of the injector.
+func (in *Injector) sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	<-in.after(d)
+}
+
+func (in *Injector) after(d time.Duration) <-chan time.Time {
+	in.mutex.Lock()
+	c := in.clock
+	in.mutex.Unlock()
+
+	return c.After(d)
+}
+
+func (in *Injector) now() time.Time {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	return in.clock.Now()
+}
+
+func (in *Injector) update(from, to []string, apply func(*faults)) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	for _, src := range from {
+		for _, dst := range to {
+			if src == dst {
+				continue
+			}
+
+			l := link{from: src, to: dst}
+
+			f, ok := in.links[l]
+			if !ok {
+				f = &faults{}
+				in.links[l] = f
+			}
+
+			apply(f)
+		}
+	}
+}
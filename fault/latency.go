@@ -0,0 +1,54 @@
+package fault
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Distribution draws the latency of single calls and pings, negative samples are treated as zero.
+type Distribution interface {
+	Sample(r *rand.Rand) time.Duration
+}
+
+type fixed time.Duration
+
+type uniform struct {
+	min time.Duration
+	max time.Duration
+}
+
+type normal struct {
+	mean   time.Duration
+	stddev time.Duration
+}
+
+// Returns a distribution always yielding d.
+func Fixed(d time.Duration) Distribution {
+	return fixed(d)
+}
+
+// Returns a distribution yielding durations uniformly distributed within [min, max).
+func Uniform(min, max time.Duration) Distribution {
+	return uniform{min: min, max: max}
+}
+
+// Returns a normal distribution with the given mean and standard deviation.
+func Normal(mean, stddev time.Duration) Distribution {
+	return normal{mean: mean, stddev: stddev}
+}
+
+func (f fixed) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(f)
+}
+
+func (u uniform) Sample(r *rand.Rand) time.Duration {
+	if u.max <= u.min {
+		return u.min
+	}
+
+	return u.min + time.Duration(r.Int63n(int64(u.max-u.min)))
+}
+
+func (n normal) Sample(r *rand.Rand) time.Duration {
+	return n.mean + time.Duration(r.NormFloat64()*float64(n.stddev))
+}
@@ -0,0 +1,111 @@
+package fault
+
+import (
+	"time"
+
+	pb "github.com/joonnna/ifrit/protobuf"
+	"golang.org/x/net/context"
+)
+
+// Transport is the rpc service of a node, implemented by comm.Comm.
+type Transport interface {
+	Register(pb.GossipServer)
+	CloseConn(string)
+	Addr() string
+	Start()
+	Stop()
+
+	Gossip(string, *pb.State) (*pb.StateResponse, error)
+	Send(context.Context, string, *pb.Msg) (*pb.MsgResponse, error)
+	StreamMessenger(string, chan []byte, chan []byte) error
+}
+
+// Pinger is the ping service of a node, implemented by comm.UDPServer.
+type Pinger interface {
+	Pause(time.Duration)
+	Ping(string, *pb.Ping) (*pb.Pong, error)
+	Start()
+	Stop()
+}
+
+type transport struct {
+	Transport
+
+	in   *Injector
+	name string
+}
+
+type pinger struct {
+	Pinger
+
+	in   *Injector
+	name string
+}
+
+// Wraps the rpc service of the named node, outgoing calls are subject to the faults of the injector.
+func (in *Injector) Transport(name string, t Transport) Transport {
+	return &transport{
+		Transport: t,
+		in:        in,
+		name:      name,
+	}
+}
+
+// Wraps the ping service of the named node, outgoing pings are subject to the faults of the injector.
+func (in *Injector) Pinger(name string, p Pinger) Pinger {
+	return &pinger{
+		Pinger: p,
+		in:     in,
+		name:   name,
+	}
+}
+
+func (t *transport) Gossip(addr string, args *pb.State) (*pb.StateResponse, error) {
+	d, err := t.in.Check(t.name, t.in.name(addr))
+	if err != nil {
+		return nil, err
+	}
+
+	t.in.sleep(d)
+
+	return t.Transport.Gossip(addr, args)
+}
+
+func (t *transport) Send(ctx context.Context, addr string, args *pb.Msg) (*pb.MsgResponse, error) {
+	d, err := t.in.Check(t.name, t.in.name(addr))
+	if err != nil {
+		return nil, err
+	}
+
+	if d > 0 {
+		select {
+		case <-t.in.after(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return t.Transport.Send(ctx, addr, args)
+}
+
+func (t *transport) StreamMessenger(addr string, input, reply chan []byte) error {
+	d, err := t.in.Check(t.name, t.in.name(addr))
+	if err != nil {
+		return err
+	}
+
+	t.in.sleep(d)
+
+	return t.Transport.StreamMessenger(addr, input, reply)
+}
+
+func (p *pinger) Ping(addr string, ping *pb.Ping) (*pb.Pong, error) {
+	d, err := p.in.Check(p.name, p.in.name(addr))
+	if err != nil {
+		return nil, err
+	}
+
+	p.in.sleep(d)
+
+	return p.Pinger.Ping(addr, ping)
+}
@@ -0,0 +1,373 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit"
+	pb "github.com/joonnna/ifrit/protobuf"
+)
+
+// Message handler of the store topic, invoked when other members store or fetch records.
+func (s *Store) handleMsg(r *ifrit.Request) ([]byte, error) {
+	req := &pb.KvRequest{}
+
+	if err := proto.Unmarshal(r.Data, req); err != nil {
+		return nil, err
+	}
+
+	switch req.GetOp() {
+	case opStore:
+		if req.GetRecord() == nil {
+			return nil, errNoRecord
+		}
+
+		return nil, s.apply(req.GetRecord(), req.GetHint())
+
+	case opFetch:
+		resp := &pb.KvResponse{
+			Record: s.local(req.GetKey()),
+		}
+
+		return proto.Marshal(resp)
+
+	default:
+		return nil, errUnknownOp
+	}
+}
+
+// Stores the record on the member with the given address, on behalf of hint if non-empty.
+func (s *Store) store(ctx context.Context, addr string, rec *pb.KvRecord, hint string) error {
+	if addr == s.c.Addr() {
+		return s.apply(rec, hint)
+	}
+
+	req := &pb.KvRequest{
+		Op:     opStore,
+		Key:    rec.GetKey(),
+		Record: rec,
+		Hint:   hint,
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.conf.Timeout)
+	defer cancel()
+
+	_, err = s.c.SendToTopic(ctx, addr, Topic, data)
+
+	return err
+}
+
+// Returns the record of key held by the member with the given address, nil if it has none.
+// Records with an invalid signature are discarded.
+func (s *Store) fetch(ctx context.Context, addr string, key []byte) (*pb.KvRecord, error) {
+	if addr == s.c.Addr() {
+		return s.local(key), nil
+	}
+
+	req := &pb.KvRequest{
+		Op:  opFetch,
+		Key: key,
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.conf.Timeout)
+	defer cancel()
+
+	reply, err := s.c.SendToTopic(ctx, addr, Topic, data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.KvResponse{}
+
+	if err := proto.Unmarshal(reply, resp); err != nil {
+		return nil, err
+	}
+
+	rec := resp.GetRecord()
+	if rec == nil {
+		return nil, nil
+	}
+
+	if !s.valid(rec) || !bytes.Equal(rec.GetKey(), key) {
+		log.Debug(errInvalidRecord.Error(), "addr", addr)
+		return nil, nil
+	}
+
+	if future(rec) {
+		log.Debug(errFutureRecord.Error(), "addr", addr)
+		return nil, nil
+	}
+
+	return rec, nil
+}
+
+func (s *Store) local(key []byte) *pb.KvRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if e, ok := s.records[string(key)]; ok {
+		return e.rec
+	}
+
+	return nil
+}
+
+// Stores the record locally if it is more recent than the one held,
+// as a hint if it is destined to another member. Hints are only accepted
+// for current owners of the key, and only up to maxHints.
+func (s *Store) apply(rec *pb.KvRecord, hint string) error {
+	if !s.valid(rec) {
+		return errInvalidRecord
+	}
+
+	if future(rec) {
+		return errFutureRecord
+	}
+
+	key := string(rec.GetKey())
+
+	owners, err := s.c.Lookup(rec.GetKey(), s.conf.Replicas)
+	if err != nil {
+		return err
+	}
+
+	if hint != "" && hint != s.c.Addr() {
+		if !contains(owners, hint) {
+			return errInvalidHint
+		}
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		prev, exists := s.hints[hint][key]
+		if !exists && s.numHints >= maxHints {
+			return errHintsFull
+		}
+
+		if !newer(rec, prev) {
+			return nil
+		}
+
+		hinted, ok := s.hints[hint]
+		if !ok {
+			hinted = make(map[string]*pb.KvRecord)
+			s.hints[hint] = hinted
+		}
+
+		hinted[key] = rec
+		if !exists {
+			s.numHints++
+		}
+
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.records[key]; !ok || newer(rec, e.rec) {
+		s.records[key] = &entry{
+			rec:    rec,
+			owners: owners,
+		}
+	}
+
+	return nil
+}
+
+// Hands hinted records over to their intended owner, hints are dropped once delivered.
+func (s *Store) handoff() {
+	type hinted struct {
+		owner string
+		rec   *pb.KvRecord
+	}
+
+	var pending []hinted
+
+	s.mutex.RLock()
+	for owner, recs := range s.hints {
+		for _, rec := range recs {
+			pending = append(pending, hinted{owner: owner, rec: rec})
+		}
+	}
+	s.mutex.RUnlock()
+
+	for _, h := range pending {
+		if err := s.store(context.Background(), h.owner, h.rec, ""); err != nil {
+			log.Debug(err.Error(), "addr", h.owner)
+			continue
+		}
+
+		key := string(h.rec.GetKey())
+
+		s.mutex.Lock()
+		if s.hints[h.owner][key] == h.rec {
+			delete(s.hints[h.owner], key)
+			s.numHints--
+			if len(s.hints[h.owner]) == 0 {
+				delete(s.hints, h.owner)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// Pushes local records to members which became owners since the record was last replicated.
+// Records for which the local member is no longer an owner are dropped once all owners hold them.
+func (s *Store) rebalance() {
+	type moved struct {
+		key    string
+		rec    *pb.KvRecord
+		owners []string
+		added  []string
+	}
+
+	var changed []moved
+
+	self := s.c.Addr()
+
+	s.mutex.RLock()
+	for key, e := range s.records {
+		owners, err := s.c.Lookup(e.rec.GetKey(), s.conf.Replicas)
+		if err != nil || equalAddrs(owners, e.owners) {
+			continue
+		}
+
+		var added []string
+		for _, addr := range owners {
+			if addr != self && !contains(e.owners, addr) {
+				added = append(added, addr)
+			}
+		}
+
+		changed = append(changed, moved{key: key, rec: e.rec, owners: owners, added: added})
+	}
+	s.mutex.RUnlock()
+
+	for _, m := range changed {
+		complete := true
+
+		for _, addr := range m.added {
+			if err := s.store(context.Background(), addr, m.rec, ""); err != nil {
+				log.Debug(err.Error(), "addr", addr)
+				complete = false
+			}
+		}
+
+		// Retried on the next membership change or handoff interval.
+		if !complete {
+			continue
+		}
+
+		s.mutex.Lock()
+		if e, ok := s.records[m.key]; ok && e.rec == m.rec {
+			if contains(m.owners, self) {
+				e.owners = m.owners
+			} else {
+				delete(s.records, m.key)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// Verifies the writer's signature.
+func (s *Store) valid(rec *pb.KvRecord) bool {
+	sign := rec.GetSignature()
+	if sign == nil {
+		return false
+	}
+
+	return s.c.VerifySignature(sign.GetR(), sign.GetS(), recordContent(rec), string(rec.GetWriter()))
+}
+
+// Returns true if the version of the record is further ahead of the local clock than maxClockSkew.
+func future(rec *pb.KvRecord) bool {
+	return time.Unix(0, int64(rec.GetVersion())).After(time.Now().Add(maxClockSkew))
+}
+
+// Returns the record marshaled without its signature, the content signed by the writer.
+func recordContent(rec *pb.KvRecord) []byte {
+	unsigned := &pb.KvRecord{
+		Key:     rec.GetKey(),
+		Value:   rec.GetValue(),
+		Version: rec.GetVersion(),
+		Writer:  rec.GetWriter(),
+		Deleted: rec.GetDeleted(),
+	}
+
+	b, err := proto.Marshal(unsigned)
+	if err != nil {
+		log.Error(err.Error())
+	}
+
+	return b
+}
+
+func newRecord(rec *pb.KvRecord) *Record {
+	sign := rec.GetSignature()
+
+	return &Record{
+		Key:     rec.GetKey(),
+		Value:   rec.GetValue(),
+		Version: rec.GetVersion(),
+		Writer:  string(rec.GetWriter()),
+		R:       sign.GetR(),
+		S:       sign.GetS(),
+		content: recordContent(rec),
+	}
+}
+
+// Returns true if a is more recent than b, writer ids break ties between equal versions.
+func newer(a, b *pb.KvRecord) bool {
+	if b == nil {
+		return a != nil
+	}
+
+	if a == nil {
+		return false
+	}
+
+	if a.GetVersion() != b.GetVersion() {
+		return a.GetVersion() > b.GetVersion()
+	}
+
+	return bytes.Compare(a.GetWriter(), b.GetWriter()) > 0
+}
+
+func equalAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+
+	return false
+}
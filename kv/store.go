@@ -0,0 +1,372 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit"
+	pb "github.com/joonnna/ifrit/protobuf"
+)
+
+const (
+	// Topic on which replicas exchange records.
+	Topic = "kv"
+
+	// How far the version of a record may be ahead of the local clock.
+	// Later versions are rejected, a writer could otherwise prevail over all future writes.
+	maxClockSkew = time.Second * 30
+
+	// Maximum number of hinted records held for other members.
+	maxHints = 10000
+)
+
+const (
+	opStore uint32 = iota + 1
+	opFetch
+)
+
+var (
+	// Returned by Get if no replica holds a value for the key, or the key was deleted.
+	ErrNotFound = errors.New("Key not found")
+
+	// Returned if fewer replicas than the configured quorum acknowledged an operation.
+	ErrQuorum = errors.New("Too few replicas responded")
+
+	errNoKey         = errors.New("Key is empty")
+	errNoRecord      = errors.New("Store request carries no record")
+	errInvalidRecord = errors.New("Record signature is invalid")
+	errFutureRecord  = errors.New("Record version is in the future")
+	errInvalidHint   = errors.New("Hinted member is not an owner of the key")
+	errHintsFull     = errors.New("Too many hinted records held")
+	errUnknownOp     = errors.New("Unknown key-value operation")
+	errInvalidConfig = errors.New("Quorums must be between 1 and the number of replicas")
+)
+
+// Client is the subset of the Ifrit client used by the store, satisfied by *ifrit.Client.
+type Client interface {
+	Id() string
+	Addr() string
+	Lookup(key []byte, n int) ([]string, error)
+	Subscribe() (<-chan ifrit.Event, func())
+	Sign(content []byte) ([]byte, []byte, error)
+	VerifySignature(r, s, content []byte, id string) bool
+	SendToTopic(ctx context.Context, dest, topic string, data []byte) ([]byte, error)
+	RegisterMsgHandlerFor(topic string, handler func(*ifrit.Request) ([]byte, error)) error
+}
+
+type Config struct {
+	// Number of ring successors of a key storing its value.
+	Replicas int
+
+	// Replicas which must acknowledge a write or answer a read.
+	WriteQuorum int
+	ReadQuorum  int
+
+	// Timeout of each replica operation.
+	Timeout time.Duration
+
+	// How often hinted records are handed to their intended owner
+	// and ownership of local records is re-evaluated.
+	HandoffInterval time.Duration
+}
+
+// Returns a config with 3 replicas and majority quorums.
+func DefaultConfig() *Config {
+	return &Config{
+		Replicas:        3,
+		WriteQuorum:     2,
+		ReadQuorum:      2,
+		Timeout:         time.Second * 5,
+		HandoffInterval: time.Second * 10,
+	}
+}
+
+// Record is the most recent value written to a key.
+type Record struct {
+	Key   []byte
+	Value []byte
+
+	// Write time of the writer in nanoseconds, the most recent write wins.
+	// Versions further ahead of the local clock than the tolerated clock skew are rejected.
+	Version uint64
+
+	// Ifrit id of the member that wrote the value.
+	Writer string
+
+	// Signature of the writer over Content().
+	R []byte
+	S []byte
+
+	content []byte
+}
+
+// Returns the bytes signed by the writer, the record can be verified through
+// client.VerifySignature(rec.R, rec.S, rec.Content(), rec.Writer).
+func (r *Record) Content() []byte {
+	return r.content
+}
+
+// Store is a key-value store replicating signed values on the ring successors of each key.
+// Writes which can not reach an owner are stored as hints on the next successor, which
+// hands them over once the owner is reachable again. When membership changes move a key,
+// its replicas push the value to the new owners.
+type Store struct {
+	c    Client
+	conf *Config
+
+	records map[string]*entry
+
+	// Hinted records indexed by the address of their intended owner and key.
+	hints    map[string]map[string]*pb.KvRecord
+	numHints int
+
+	mutex sync.RWMutex
+
+	exitChan chan bool
+	stopOnce sync.Once
+}
+
+type entry struct {
+	rec *pb.KvRecord
+
+	// Owners the record was last replicated to.
+	owners []string
+}
+
+// Creates a store on top of the given client and registers its message handler.
+// Start has to be invoked for hinted handoff and re-replication to take place.
+func NewStore(c Client, conf *Config) (*Store, error) {
+	if conf.Replicas <= 0 || conf.WriteQuorum <= 0 || conf.ReadQuorum <= 0 ||
+		conf.WriteQuorum > conf.Replicas || conf.ReadQuorum > conf.Replicas {
+		return nil, errInvalidConfig
+	}
+
+	s := &Store{
+		c:        c,
+		conf:     conf,
+		records:  make(map[string]*entry),
+		hints:    make(map[string]map[string]*pb.KvRecord),
+		exitChan: make(chan bool),
+	}
+
+	if err := c.RegisterMsgHandlerFor(Topic, s.handleMsg); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Hands over hints and re-replicates records on membership changes, blocks until Stop is invoked.
+func (s *Store) Start() {
+	events, cancel := s.c.Subscribe()
+	defer cancel()
+
+	ticker := time.NewTicker(s.conf.HandoffInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.exitChan:
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			switch e.Type {
+			case ifrit.PeerJoined, ifrit.PeerRemoved, ifrit.PeerLeft:
+				s.rebalance()
+			}
+		case <-ticker.C:
+			s.handoff()
+			s.rebalance()
+		}
+	}
+}
+
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.exitChan)
+	})
+}
+
+// Writes value to the owners of key, returns ErrQuorum if fewer than WriteQuorum acknowledged it.
+func (s *Store) Put(ctx context.Context, key, value []byte) error {
+	rec, err := s.newRecord(key, value, false)
+	if err != nil {
+		return err
+	}
+
+	return s.write(ctx, rec)
+}
+
+// Deletes key by writing a signed tombstone to its owners.
+func (s *Store) Delete(ctx context.Context, key []byte) error {
+	rec, err := s.newRecord(key, nil, true)
+	if err != nil {
+		return err
+	}
+
+	return s.write(ctx, rec)
+}
+
+// Returns the most recent value of key held by its owners, after verifying the writer's signature.
+// Owners holding an older value are updated in the background.
+func (s *Store) Get(ctx context.Context, key []byte) (*Record, error) {
+	type reply struct {
+		addr string
+		rec  *pb.KvRecord
+		err  error
+	}
+
+	if len(key) == 0 {
+		return nil, errNoKey
+	}
+
+	owners, err := s.c.Lookup(key, s.conf.Replicas)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *reply, len(owners))
+
+	for _, addr := range owners {
+		go func(addr string) {
+			rec, err := s.fetch(ctx, addr, key)
+			ch <- &reply{addr: addr, rec: rec, err: err}
+		}(addr)
+	}
+
+	var newest *pb.KvRecord
+	var replies []*reply
+
+	for range owners {
+		r := <-ch
+		if r.err != nil {
+			log.Debug(r.err.Error(), "addr", r.addr)
+			continue
+		}
+
+		replies = append(replies, r)
+
+		if r.rec != nil && newer(r.rec, newest) {
+			newest = r.rec
+		}
+	}
+
+	if len(replies) < s.conf.ReadQuorum {
+		return nil, ErrQuorum
+	}
+
+	if newest == nil {
+		return nil, ErrNotFound
+	}
+
+	// Read repair
+	for _, r := range replies {
+		if newer(newest, r.rec) {
+			go func(addr string) {
+				if err := s.store(context.Background(), addr, newest, ""); err != nil {
+					log.Debug(err.Error(), "addr", addr)
+				}
+			}(r.addr)
+		}
+	}
+
+	if newest.GetDeleted() {
+		return nil, ErrNotFound
+	}
+
+	return newRecord(newest), nil
+}
+
+func (s *Store) newRecord(key, value []byte, deleted bool) (*pb.KvRecord, error) {
+	if len(key) == 0 {
+		return nil, errNoKey
+	}
+
+	rec := &pb.KvRecord{
+		Key:     key,
+		Value:   value,
+		Version: uint64(time.Now().UnixNano()),
+		Writer:  []byte(s.c.Id()),
+		Deleted: deleted,
+	}
+
+	r, sign, err := s.c.Sign(recordContent(rec))
+	if err != nil {
+		return nil, err
+	}
+
+	rec.Signature = &pb.Signature{
+		R: r,
+		S: sign,
+	}
+
+	return rec, nil
+}
+
+// Stores the record on the owners of its key. Owners which can not be reached are
+// replaced by the next successors, which hold the record as a hint for the owner.
+func (s *Store) write(ctx context.Context, rec *pb.KvRecord) error {
+	candidates, err := s.c.Lookup(rec.GetKey(), s.conf.Replicas*2)
+	if err != nil {
+		return err
+	}
+
+	owners := candidates
+	var fallbacks []string
+
+	if len(candidates) > s.conf.Replicas {
+		owners = candidates[:s.conf.Replicas]
+		fallbacks = candidates[s.conf.Replicas:]
+	}
+
+	type result struct {
+		addr string
+		err  error
+	}
+
+	ch := make(chan *result, len(owners))
+
+	for _, addr := range owners {
+		go func(addr string) {
+			ch <- &result{addr: addr, err: s.store(ctx, addr, rec, "")}
+		}(addr)
+	}
+
+	acks := 0
+	var failed []string
+
+	for range owners {
+		r := <-ch
+		if r.err != nil {
+			log.Debug(r.err.Error(), "addr", r.addr)
+			failed = append(failed, r.addr)
+		} else {
+			acks++
+		}
+	}
+
+	// Hinted handoff, each failed owner is replaced by the next reachable successor.
+	for _, addr := range failed {
+		for len(fallbacks) > 0 {
+			next := fallbacks[0]
+			fallbacks = fallbacks[1:]
+
+			if err := s.store(ctx, next, rec, addr); err == nil {
+				acks++
+				break
+			}
+		}
+	}
+
+	if acks < s.conf.WriteQuorum {
+		return ErrQuorum
+	}
+
+	return nil
+}
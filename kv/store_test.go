@@ -0,0 +1,400 @@
+package kv
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/joonnna/ifrit"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+var errUnreachable = errors.New("Destination unreachable")
+
+// In-memory network of clients, members are placed on a single hash ring.
+type testNet struct {
+	clients map[string]*testClient
+
+	mutex sync.RWMutex
+}
+
+type testClient struct {
+	net *testNet
+
+	id   string
+	addr string
+	priv *ecdsa.PrivateKey
+
+	// Unreachable clients are still members, removed clients are not.
+	unreachable bool
+	removed     bool
+
+	handler func(*ifrit.Request) ([]byte, error)
+}
+
+func newTestNet(size int) (*testNet, error) {
+	tn := &testNet{
+		clients: make(map[string]*testClient),
+	}
+
+	for i := 0; i < size; i++ {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &testClient{
+			net:  tn,
+			id:   fmt.Sprintf("id%d", i),
+			addr: fmt.Sprintf("addr%d", i),
+			priv: priv,
+		}
+
+		tn.clients[c.addr] = c
+	}
+
+	return tn, nil
+}
+
+func (tn *testNet) set(addr string, unreachable, removed bool) {
+	tn.mutex.Lock()
+	defer tn.mutex.Unlock()
+
+	tn.clients[addr].unreachable = unreachable
+	tn.clients[addr].removed = removed
+}
+
+func (c *testClient) Id() string {
+	return c.id
+}
+
+func (c *testClient) Addr() string {
+	return c.addr
+}
+
+func (c *testClient) Lookup(key []byte, n int) ([]string, error) {
+	var ring []string
+
+	c.net.mutex.RLock()
+	for addr, other := range c.net.clients {
+		if !other.removed {
+			ring = append(ring, addr)
+		}
+	}
+	c.net.mutex.RUnlock()
+
+	pos := func(addr string) string {
+		h := sha256.Sum256([]byte(addr))
+		return string(h[:])
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		return pos(ring[i]) < pos(ring[j])
+	})
+
+	k := sha256.Sum256(key)
+	idx := sort.Search(len(ring), func(i int) bool {
+		return pos(ring[i]) >= string(k[:])
+	})
+
+	if n > len(ring) {
+		n = len(ring)
+	}
+
+	ret := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, ring[(idx+i)%len(ring)])
+	}
+
+	return ret, nil
+}
+
+func (c *testClient) Subscribe() (<-chan ifrit.Event, func()) {
+	return make(chan ifrit.Event), func() {}
+}
+
+func (c *testClient) Sign(content []byte) ([]byte, []byte, error) {
+	h := sha256.Sum256(content)
+
+	r, s, err := ecdsa.Sign(rand.Reader, c.priv, h[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.Bytes(), s.Bytes(), nil
+}
+
+func (c *testClient) VerifySignature(r, s, content []byte, id string) bool {
+	c.net.mutex.RLock()
+	defer c.net.mutex.RUnlock()
+
+	for _, other := range c.net.clients {
+		if other.id == id {
+			h := sha256.Sum256(content)
+			return ecdsa.Verify(&other.priv.PublicKey, h[:], new(big.Int).SetBytes(r), new(big.Int).SetBytes(s))
+		}
+	}
+
+	return false
+}
+
+func (c *testClient) SendToTopic(ctx context.Context, dest, topic string, data []byte) ([]byte, error) {
+	c.net.mutex.RLock()
+	other, ok := c.net.clients[dest]
+	down := !ok || other.unreachable || other.removed
+	c.net.mutex.RUnlock()
+
+	if down || other.handler == nil {
+		return nil, errUnreachable
+	}
+
+	return other.handler(&ifrit.Request{
+		Context:    ctx,
+		SenderId:   c.id,
+		SenderAddr: c.addr,
+		Data:       data,
+	})
+}
+
+func (c *testClient) RegisterMsgHandlerFor(topic string, handler func(*ifrit.Request) ([]byte, error)) error {
+	c.handler = handler
+	return nil
+}
+
+type StoreTestSuite struct {
+	suite.Suite
+
+	net    *testNet
+	stores map[string]*Store
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
+
+func (suite *StoreTestSuite) SetupTest() {
+	tn, err := newTestNet(6)
+	require.NoError(suite.T(), err, "Failed to create network.")
+
+	suite.net = tn
+	suite.stores = make(map[string]*Store)
+
+	for addr, c := range tn.clients {
+		s, err := NewStore(c, DefaultConfig())
+		require.NoError(suite.T(), err, "Failed to create store.")
+		suite.stores[addr] = s
+	}
+}
+
+func (suite *StoreTestSuite) owners(key []byte, n int) []string {
+	for _, c := range suite.net.clients {
+		if !c.removed {
+			owners, err := c.Lookup(key, n)
+			require.NoError(suite.T(), err, "Lookup failed.")
+			return owners
+		}
+	}
+
+	return nil
+}
+
+func (suite *StoreTestSuite) TestNewStore() {
+	c := suite.net.clients["addr0"]
+
+	conf := DefaultConfig()
+	conf.WriteQuorum = conf.Replicas + 1
+
+	_, err := NewStore(c, conf)
+	require.Equal(suite.T(), errInvalidConfig, err, "Should reject quorum larger than replicas.")
+
+	conf = DefaultConfig()
+	conf.ReadQuorum = 0
+
+	_, err = NewStore(c, conf)
+	require.Equal(suite.T(), errInvalidConfig, err, "Should reject empty quorum.")
+}
+
+func (suite *StoreTestSuite) TestPutGetDelete() {
+	ctx := context.Background()
+	key := []byte("config")
+
+	writer := suite.stores["addr0"]
+	reader := suite.stores["addr1"]
+
+	_, err := reader.Get(ctx, key)
+	require.Equal(suite.T(), ErrNotFound, err, "Should not find unwritten key.")
+
+	require.Equal(suite.T(), errNoKey, writer.Put(ctx, nil, []byte("value")), "Should reject empty key.")
+
+	require.NoError(suite.T(), writer.Put(ctx, key, []byte("first")), "Put failed.")
+	require.NoError(suite.T(), writer.Put(ctx, key, []byte("second")), "Put failed.")
+
+	rec, err := reader.Get(ctx, key)
+	require.NoError(suite.T(), err, "Get failed.")
+	require.Equal(suite.T(), []byte("second"), rec.Value, "Should return most recent value.")
+	require.Equal(suite.T(), "id0", rec.Writer, "Wrong writer.")
+
+	c := suite.net.clients["addr1"]
+	require.True(suite.T(), c.VerifySignature(rec.R, rec.S, rec.Content(), rec.Writer), "Writer signature should be valid.")
+
+	for _, addr := range suite.owners(key, 3) {
+		require.NotNil(suite.T(), suite.stores[addr].local(key), "Owner does not hold the record.")
+	}
+
+	require.NoError(suite.T(), writer.Delete(ctx, key), "Delete failed.")
+
+	_, err = reader.Get(ctx, key)
+	require.Equal(suite.T(), ErrNotFound, err, "Should not find deleted key.")
+}
+
+func (suite *StoreTestSuite) TestInvalidRecord() {
+	key := []byte("config")
+
+	s := suite.stores["addr0"]
+
+	rec, err := s.newRecord(key, []byte("value"), false)
+	require.NoError(suite.T(), err, "Failed to create record.")
+
+	rec.Value = []byte("tampered")
+
+	data, err := proto.Marshal(&pb.KvRequest{Op: opStore, Key: key, Record: rec})
+	require.NoError(suite.T(), err, "Failed to marshal request.")
+
+	_, err = suite.net.clients["addr1"].SendToTopic(context.Background(), "addr2", Topic, data)
+	require.Equal(suite.T(), errInvalidRecord, err, "Should reject record with invalid signature.")
+	require.Nil(suite.T(), suite.stores["addr2"].local(key), "Invalid record was stored.")
+}
+
+func (suite *StoreTestSuite) TestFutureRecord() {
+	key := []byte("config")
+
+	s := suite.stores["addr0"]
+
+	rec, err := s.newRecord(key, []byte("value"), false)
+	require.NoError(suite.T(), err, "Failed to create record.")
+
+	rec.Version = uint64(time.Now().Add(time.Hour).UnixNano())
+
+	r, sign, err := s.c.Sign(recordContent(rec))
+	require.NoError(suite.T(), err, "Failed to sign record.")
+
+	rec.Signature = &pb.Signature{R: r, S: sign}
+
+	require.Equal(suite.T(), errFutureRecord, suite.stores["addr2"].apply(rec, ""), "Should reject record from the future.")
+	require.Nil(suite.T(), suite.stores["addr2"].local(key), "Record from the future was stored.")
+}
+
+func (suite *StoreTestSuite) TestInvalidHint() {
+	key := []byte("config")
+
+	candidates := suite.owners(key, 5)
+	fallback := suite.stores[candidates[3]]
+
+	rec, err := suite.stores[candidates[0]].newRecord(key, []byte("value"), false)
+	require.NoError(suite.T(), err, "Failed to create record.")
+
+	require.Equal(suite.T(), errInvalidHint, fallback.apply(rec, candidates[4]), "Should reject hint for non-owner.")
+	require.Equal(suite.T(), errInvalidHint, fallback.apply(rec, "unknown"), "Should reject hint for unknown member.")
+	require.Zero(suite.T(), len(fallback.hints), "Invalid hint was stored.")
+
+	require.NoError(suite.T(), fallback.apply(rec, candidates[1]), "Should accept hint for owner.")
+	require.NoError(suite.T(), fallback.apply(rec, candidates[1]), "Should accept repeated hint.")
+	require.Equal(suite.T(), 1, fallback.numHints, "Repeated hint should not be counted twice.")
+
+	fallback.numHints = maxHints
+	require.Equal(suite.T(), errHintsFull, fallback.apply(rec, candidates[2]), "Should reject hints beyond the limit.")
+}
+
+func (suite *StoreTestSuite) TestQuorum() {
+	ctx := context.Background()
+	key := []byte("config")
+
+	// Without enough members to hold hints, writes can not reach a quorum.
+	for addr := range suite.net.clients {
+		suite.net.set(addr, true, false)
+	}
+
+	owners := suite.owners(key, 3)
+	writer := suite.stores[owners[0]]
+	suite.net.set(owners[0], false, false)
+
+	require.Equal(suite.T(), ErrQuorum, writer.Put(ctx, key, []byte("value")), "Write should not reach quorum.")
+
+	_, err := writer.Get(ctx, key)
+	require.Equal(suite.T(), ErrQuorum, err, "Read should not reach quorum.")
+}
+
+func (suite *StoreTestSuite) TestHintedHandoff() {
+	ctx := context.Background()
+	key := []byte("config")
+
+	candidates := suite.owners(key, 4)
+	down := candidates[1]
+	fallback := candidates[3]
+
+	suite.net.set(down, true, false)
+
+	require.NoError(suite.T(), suite.stores[candidates[0]].Put(ctx, key, []byte("value")), "Put should succeed with hint.")
+	require.Nil(suite.T(), suite.stores[down].local(key), "Unreachable owner should not hold the record.")
+	require.Nil(suite.T(), suite.stores[fallback].local(key), "Hint should not be stored as a regular record.")
+	require.Equal(suite.T(), 1, len(suite.stores[fallback].hints[down]), "Fallback should hold hint for owner.")
+
+	suite.stores[fallback].handoff()
+	require.Equal(suite.T(), 1, len(suite.stores[fallback].hints), "Hint dropped while owner unreachable.")
+
+	suite.net.set(down, false, false)
+
+	suite.stores[fallback].handoff()
+	require.Zero(suite.T(), len(suite.stores[fallback].hints), "Hint not dropped after handoff.")
+	require.NotNil(suite.T(), suite.stores[down].local(key), "Owner did not receive hinted record.")
+}
+
+func (suite *StoreTestSuite) TestRebalance() {
+	ctx := context.Background()
+	key := []byte("config")
+
+	candidates := suite.owners(key, 4)
+	removed := candidates[0]
+	next := candidates[3]
+
+	require.NoError(suite.T(), suite.stores[removed].Put(ctx, key, []byte("value")), "Put failed.")
+	require.Nil(suite.T(), suite.stores[next].local(key), "Non-owner should not hold the record.")
+
+	suite.net.set(removed, false, true)
+
+	for addr, s := range suite.stores {
+		if addr != removed {
+			s.rebalance()
+		}
+	}
+
+	require.NotNil(suite.T(), suite.stores[next].local(key), "New owner did not receive the record.")
+
+	rec, err := suite.stores[next].Get(ctx, key)
+	require.NoError(suite.T(), err, "Get failed after re-replication.")
+	require.Equal(suite.T(), []byte("value"), rec.Value, "Wrong value after re-replication.")
+
+	// Owners that rejoin take the key back, the member no longer owning it drops its copy.
+	suite.net.set(removed, false, false)
+
+	for addr, s := range suite.stores {
+		if addr != removed {
+			s.rebalance()
+		}
+	}
+
+	require.NotNil(suite.T(), suite.stores[removed].local(key), "Rejoined owner did not receive the record.")
+	require.Nil(suite.T(), suite.stores[next].local(key), "Former owner should drop the record.")
+}
@@ -140,6 +140,21 @@ func LocalIP() (string, error) {
 	return addrs[0].String(), nil
 }
 
+// Listens on the exact given address (ip:port), used to reclaim the addresses of a resumed identity.
+func ListenOnAddr(addr string) (net.Listener, error) {
+	return net.Listen("tcp4", addr)
+}
+
+// Same as ListenOnAddr, but for udp.
+func ListenUdpOnAddr(addr string) (*net.UDPConn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.ListenUDP("udp", udpAddr)
+}
+
 func ListenUdp() (*net.UDPConn, string, error) {
 	h, _ := os.Hostname()
 
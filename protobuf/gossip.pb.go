@@ -20,6 +20,20 @@ It has these top-level messages:
 	Ping
 	Pong
 	Test
+	GossipEntry
+	GossipDigest
+	Rumor
+	RbcMsg
+	KvRecord
+	KvRequest
+	KvResponse
+	RpcRequest
+	RpcResponse
+	Publication
+	Subscriptions
+	Lease
+	Envelope
+	PingRequest
 */
 package proto
 
@@ -48,6 +62,9 @@ type State struct {
 	ExistingHosts  map[string]uint64 `protobuf:"bytes,1,rep,name=existingHosts" json:"existingHosts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
 	OwnNote        *Note             `protobuf:"bytes,2,opt,name=ownNote" json:"ownNote,omitempty"`
 	ExternalGossip []byte            `protobuf:"bytes,3,opt,name=externalGossip,proto3" json:"externalGossip,omitempty"`
+	TopicGossip    map[string][]byte `protobuf:"bytes,4,rep,name=topicGossip" json:"topicGossip,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	GossipDigest   []*GossipDigest   `protobuf:"bytes,5,rep,name=gossipDigest" json:"gossipDigest,omitempty"`
+	Rumors         []*Rumor          `protobuf:"bytes,6,rep,name=rumors" json:"rumors,omitempty"`
 }
 
 func (m *State) Reset()                    { *m = State{} }
@@ -76,9 +93,31 @@ func (m *State) GetExternalGossip() []byte {
 	return nil
 }
 
+func (m *State) GetTopicGossip() map[string][]byte {
+	if m != nil {
+		return m.TopicGossip
+	}
+	return nil
+}
+
+func (m *State) GetGossipDigest() []*GossipDigest {
+	if m != nil {
+		return m.GossipDigest
+	}
+	return nil
+}
+
+func (m *State) GetRumors() []*Rumor {
+	if m != nil {
+		return m.Rumors
+	}
+	return nil
+}
+
 // Application message
 type Msg struct {
 	Content []byte `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	Topic   string `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
 }
 
 func (m *Msg) Reset()                    { *m = Msg{} }
@@ -93,6 +132,13 @@ func (m *Msg) GetContent() []byte {
 	return nil
 }
 
+func (m *Msg) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
 // Application response
 type MsgResponse struct {
 	Content []byte `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
@@ -111,10 +157,12 @@ func (m *MsgResponse) GetContent() []byte {
 }
 
 type StateResponse struct {
-	Certificates   []*Certificate `protobuf:"bytes,1,rep,name=certificates" json:"certificates,omitempty"`
-	Notes          []*Note        `protobuf:"bytes,2,rep,name=notes" json:"notes,omitempty"`
-	Accusations    []*Accusation  `protobuf:"bytes,3,rep,name=accusations" json:"accusations,omitempty"`
-	ExternalGossip []byte         `protobuf:"bytes,4,opt,name=externalGossip,proto3" json:"externalGossip,omitempty"`
+	Certificates   []*Certificate    `protobuf:"bytes,1,rep,name=certificates" json:"certificates,omitempty"`
+	Notes          []*Note           `protobuf:"bytes,2,rep,name=notes" json:"notes,omitempty"`
+	Accusations    []*Accusation     `protobuf:"bytes,3,rep,name=accusations" json:"accusations,omitempty"`
+	ExternalGossip []byte            `protobuf:"bytes,4,opt,name=externalGossip,proto3" json:"externalGossip,omitempty"`
+	TopicGossip    map[string][]byte `protobuf:"bytes,5,rep,name=topicGossip" json:"topicGossip,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	GossipEntries  []*GossipEntry    `protobuf:"bytes,6,rep,name=gossipEntries" json:"gossipEntries,omitempty"`
 }
 
 func (m *StateResponse) Reset()                    { *m = StateResponse{} }
@@ -150,6 +198,20 @@ func (m *StateResponse) GetExternalGossip() []byte {
 	return nil
 }
 
+func (m *StateResponse) GetTopicGossip() map[string][]byte {
+	if m != nil {
+		return m.TopicGossip
+	}
+	return nil
+}
+
+func (m *StateResponse) GetGossipEntries() []*GossipEntry {
+	if m != nil {
+		return m.GossipEntries
+	}
+	return nil
+}
+
 // Raw certificate
 type Certificate struct {
 	Raw []byte `protobuf:"bytes,1,opt,name=raw,proto3" json:"raw,omitempty"`
@@ -221,6 +283,7 @@ type Note struct {
 	Id        []byte     `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
 	Mask      uint32     `protobuf:"varint,3,opt,name=mask" json:"mask,omitempty"`
 	Signature *Signature `protobuf:"bytes,4,opt,name=signature" json:"signature,omitempty"`
+	Leaving   bool       `protobuf:"varint,5,opt,name=leaving" json:"leaving,omitempty"`
 }
 
 func (m *Note) Reset()                    { *m = Note{} }
@@ -256,6 +319,13 @@ func (m *Note) GetSignature() *Signature {
 	return nil
 }
 
+func (m *Note) GetLeaving() bool {
+	if m != nil {
+		return m.Leaving
+	}
+	return false
+}
+
 // Raw elliptic signature
 type Signature struct {
 	R []byte `protobuf:"bytes,1,opt,name=r,proto3" json:"r,omitempty"`
@@ -361,6 +431,546 @@ func (m *Test) GetNums() []int32 {
 	return nil
 }
 
+// Keyed gossip value, origin is the node id of the owner
+type GossipEntry struct {
+	Origin    []byte     `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Key       string     `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Version   uint64     `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+	Value     []byte     `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Signature *Signature `protobuf:"bytes,5,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *GossipEntry) Reset()                    { *m = GossipEntry{} }
+func (m *GossipEntry) String() string            { return proto1.CompactTextString(m) }
+func (*GossipEntry) ProtoMessage()               {}
+func (*GossipEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *GossipEntry) GetOrigin() []byte {
+	if m != nil {
+		return m.Origin
+	}
+	return nil
+}
+
+func (m *GossipEntry) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *GossipEntry) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *GossipEntry) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *GossipEntry) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// Version of a keyed gossip value held by the sender
+type GossipDigest struct {
+	Origin  []byte `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Key     string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Version uint64 `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *GossipDigest) Reset()                    { *m = GossipDigest{} }
+func (m *GossipDigest) String() string            { return proto1.CompactTextString(m) }
+func (*GossipDigest) ProtoMessage()               {}
+func (*GossipDigest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *GossipDigest) GetOrigin() []byte {
+	if m != nil {
+		return m.Origin
+	}
+	return nil
+}
+
+func (m *GossipDigest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *GossipDigest) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+// Broadcast message, the signature covers origin, content, seq and hops but not the ttl decremented by relays
+type Rumor struct {
+	Origin    []byte     `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Content   []byte     `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Ttl       uint32     `protobuf:"varint,3,opt,name=ttl" json:"ttl,omitempty"`
+	Signature *Signature `protobuf:"bytes,4,opt,name=signature" json:"signature,omitempty"`
+	Seq       uint64     `protobuf:"varint,5,opt,name=seq" json:"seq,omitempty"`
+	Hops      uint32     `protobuf:"varint,6,opt,name=hops" json:"hops,omitempty"`
+}
+
+func (m *Rumor) Reset()                    { *m = Rumor{} }
+func (m *Rumor) String() string            { return proto1.CompactTextString(m) }
+func (*Rumor) ProtoMessage()               {}
+func (*Rumor) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *Rumor) GetOrigin() []byte {
+	if m != nil {
+		return m.Origin
+	}
+	return nil
+}
+
+func (m *Rumor) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+func (m *Rumor) GetTtl() uint32 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+func (m *Rumor) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *Rumor) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Rumor) GetHops() uint32 {
+	if m != nil {
+		return m.Hops
+	}
+	return 0
+}
+
+// Reliable broadcast phase message, signed by the sender
+type RbcMsg struct {
+	Origin    []byte     `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Seq       uint64     `protobuf:"varint,2,opt,name=seq" json:"seq,omitempty"`
+	Phase     uint32     `protobuf:"varint,3,opt,name=phase" json:"phase,omitempty"`
+	Content   []byte     `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	Sender    []byte     `protobuf:"bytes,5,opt,name=sender,proto3" json:"sender,omitempty"`
+	Signature *Signature `protobuf:"bytes,6,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *RbcMsg) Reset()                    { *m = RbcMsg{} }
+func (m *RbcMsg) String() string            { return proto1.CompactTextString(m) }
+func (*RbcMsg) ProtoMessage()               {}
+func (*RbcMsg) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *RbcMsg) GetOrigin() []byte {
+	if m != nil {
+		return m.Origin
+	}
+	return nil
+}
+
+func (m *RbcMsg) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *RbcMsg) GetPhase() uint32 {
+	if m != nil {
+		return m.Phase
+	}
+	return 0
+}
+
+func (m *RbcMsg) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+func (m *RbcMsg) GetSender() []byte {
+	if m != nil {
+		return m.Sender
+	}
+	return nil
+}
+
+func (m *RbcMsg) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type KvRecord struct {
+	Key       []byte     `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value     []byte     `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Version   uint64     `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+	Writer    []byte     `protobuf:"bytes,4,opt,name=writer,proto3" json:"writer,omitempty"`
+	Deleted   bool       `protobuf:"varint,5,opt,name=deleted" json:"deleted,omitempty"`
+	Signature *Signature `protobuf:"bytes,6,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *KvRecord) Reset()                    { *m = KvRecord{} }
+func (m *KvRecord) String() string            { return proto1.CompactTextString(m) }
+func (*KvRecord) ProtoMessage()               {}
+func (*KvRecord) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *KvRecord) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *KvRecord) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *KvRecord) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *KvRecord) GetWriter() []byte {
+	if m != nil {
+		return m.Writer
+	}
+	return nil
+}
+
+func (m *KvRecord) GetDeleted() bool {
+	if m != nil {
+		return m.Deleted
+	}
+	return false
+}
+
+func (m *KvRecord) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type KvRequest struct {
+	Op     uint32    `protobuf:"varint,1,opt,name=op" json:"op,omitempty"`
+	Key    []byte    `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Record *KvRecord `protobuf:"bytes,3,opt,name=record" json:"record,omitempty"`
+	Hint   string    `protobuf:"bytes,4,opt,name=hint" json:"hint,omitempty"`
+}
+
+func (m *KvRequest) Reset()                    { *m = KvRequest{} }
+func (m *KvRequest) String() string            { return proto1.CompactTextString(m) }
+func (*KvRequest) ProtoMessage()               {}
+func (*KvRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *KvRequest) GetOp() uint32 {
+	if m != nil {
+		return m.Op
+	}
+	return 0
+}
+
+func (m *KvRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *KvRequest) GetRecord() *KvRecord {
+	if m != nil {
+		return m.Record
+	}
+	return nil
+}
+
+func (m *KvRequest) GetHint() string {
+	if m != nil {
+		return m.Hint
+	}
+	return ""
+}
+
+type KvResponse struct {
+	Record *KvRecord `protobuf:"bytes,1,opt,name=record" json:"record,omitempty"`
+}
+
+func (m *KvResponse) Reset()                    { *m = KvResponse{} }
+func (m *KvResponse) String() string            { return proto1.CompactTextString(m) }
+func (*KvResponse) ProtoMessage()               {}
+func (*KvResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *KvResponse) GetRecord() *KvRecord {
+	if m != nil {
+		return m.Record
+	}
+	return nil
+}
+
+type RpcRequest struct {
+	Method  string `protobuf:"bytes,1,opt,name=method" json:"method,omitempty"`
+	Codec   string `protobuf:"bytes,2,opt,name=codec" json:"codec,omitempty"`
+	Payload []byte `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *RpcRequest) Reset()                    { *m = RpcRequest{} }
+func (m *RpcRequest) String() string            { return proto1.CompactTextString(m) }
+func (*RpcRequest) ProtoMessage()               {}
+func (*RpcRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *RpcRequest) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+func (m *RpcRequest) GetCodec() string {
+	if m != nil {
+		return m.Codec
+	}
+	return ""
+}
+
+func (m *RpcRequest) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+type RpcResponse struct {
+	Code    uint32 `protobuf:"varint,1,opt,name=code" json:"code,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Payload []byte `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *RpcResponse) Reset()                    { *m = RpcResponse{} }
+func (m *RpcResponse) String() string            { return proto1.CompactTextString(m) }
+func (*RpcResponse) ProtoMessage()               {}
+func (*RpcResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *RpcResponse) GetCode() uint32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *RpcResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *RpcResponse) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+type Publication struct {
+	Origin    []byte     `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Topic     string     `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	Seq       uint64     `protobuf:"varint,3,opt,name=seq" json:"seq,omitempty"`
+	Content   []byte     `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	Signature *Signature `protobuf:"bytes,5,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *Publication) Reset()                    { *m = Publication{} }
+func (m *Publication) String() string            { return proto1.CompactTextString(m) }
+func (*Publication) ProtoMessage()               {}
+func (*Publication) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *Publication) GetOrigin() []byte {
+	if m != nil {
+		return m.Origin
+	}
+	return nil
+}
+
+func (m *Publication) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *Publication) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Publication) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+func (m *Publication) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type Subscriptions struct {
+	Topics []string `protobuf:"bytes,1,rep,name=topics" json:"topics,omitempty"`
+}
+
+func (m *Subscriptions) Reset()                    { *m = Subscriptions{} }
+func (m *Subscriptions) String() string            { return proto1.CompactTextString(m) }
+func (*Subscriptions) ProtoMessage()               {}
+func (*Subscriptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *Subscriptions) GetTopics() []string {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+type Lease struct {
+	Group     string     `protobuf:"bytes,1,opt,name=group" json:"group,omitempty"`
+	Leader    []byte     `protobuf:"bytes,2,opt,name=leader,proto3" json:"leader,omitempty"`
+	Expires   int64      `protobuf:"varint,3,opt,name=expires" json:"expires,omitempty"`
+	Signature *Signature `protobuf:"bytes,4,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *Lease) Reset()                    { *m = Lease{} }
+func (m *Lease) String() string            { return proto1.CompactTextString(m) }
+func (*Lease) ProtoMessage()               {}
+func (*Lease) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *Lease) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *Lease) GetLeader() []byte {
+	if m != nil {
+		return m.Leader
+	}
+	return nil
+}
+
+func (m *Lease) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
+func (m *Lease) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type Envelope struct {
+	Payload     []byte     `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	Certificate []byte     `protobuf:"bytes,2,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Timestamp   int64      `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	Signature   *Signature `protobuf:"bytes,4,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *Envelope) Reset()                    { *m = Envelope{} }
+func (m *Envelope) String() string            { return proto1.CompactTextString(m) }
+func (*Envelope) ProtoMessage()               {}
+func (*Envelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *Envelope) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Envelope) GetCertificate() []byte {
+	if m != nil {
+		return m.Certificate
+	}
+	return nil
+}
+
+func (m *Envelope) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *Envelope) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type PingRequest struct {
+	Target []byte `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Nonce  []byte `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *PingRequest) Reset()                    { *m = PingRequest{} }
+func (m *PingRequest) String() string            { return proto1.CompactTextString(m) }
+func (*PingRequest) ProtoMessage()               {}
+func (*PingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *PingRequest) GetTarget() []byte {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+func (m *PingRequest) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
 func init() {
 	proto1.RegisterType((*State)(nil), "proto.State")
 	proto1.RegisterType((*Msg)(nil), "proto.Msg")
@@ -374,6 +984,20 @@ func init() {
 	proto1.RegisterType((*Ping)(nil), "proto.Ping")
 	proto1.RegisterType((*Pong)(nil), "proto.Pong")
 	proto1.RegisterType((*Test)(nil), "proto.Test")
+	proto1.RegisterType((*GossipEntry)(nil), "proto.GossipEntry")
+	proto1.RegisterType((*GossipDigest)(nil), "proto.GossipDigest")
+	proto1.RegisterType((*Rumor)(nil), "proto.Rumor")
+	proto1.RegisterType((*RbcMsg)(nil), "proto.RbcMsg")
+	proto1.RegisterType((*KvRecord)(nil), "proto.KvRecord")
+	proto1.RegisterType((*KvRequest)(nil), "proto.KvRequest")
+	proto1.RegisterType((*KvResponse)(nil), "proto.KvResponse")
+	proto1.RegisterType((*RpcRequest)(nil), "proto.RpcRequest")
+	proto1.RegisterType((*RpcResponse)(nil), "proto.RpcResponse")
+	proto1.RegisterType((*Publication)(nil), "proto.Publication")
+	proto1.RegisterType((*Subscriptions)(nil), "proto.Subscriptions")
+	proto1.RegisterType((*Lease)(nil), "proto.Lease")
+	proto1.RegisterType((*Envelope)(nil), "proto.Envelope")
+	proto1.RegisterType((*PingRequest)(nil), "proto.PingRequest")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -550,39 +1174,76 @@ var _Gossip_serviceDesc = grpc.ServiceDesc{
 func init() { proto1.RegisterFile("gossip.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 544 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xd1, 0x6e, 0xd3, 0x30,
-	0x14, 0xc5, 0x69, 0xb2, 0xa9, 0x37, 0xe9, 0x34, 0xac, 0x3d, 0x44, 0x15, 0x52, 0x4b, 0x24, 0x58,
-	0x5e, 0xa8, 0xa6, 0x4e, 0x42, 0x88, 0x27, 0x10, 0x54, 0xf0, 0x40, 0xa7, 0xc9, 0xe3, 0x07, 0x4c,
-	0x7a, 0x09, 0xd6, 0x56, 0x3b, 0xb2, 0x5d, 0xb6, 0x7d, 0x09, 0xaf, 0xfc, 0x0d, 0x9f, 0xc1, 0xaf,
-	0x20, 0x3b, 0x49, 0x9b, 0x8e, 0x8e, 0x89, 0xa7, 0xde, 0xe3, 0x7b, 0xae, 0xef, 0xb9, 0xa7, 0x37,
-	0x86, 0xa4, 0x54, 0xc6, 0x88, 0x6a, 0x52, 0x69, 0x65, 0x15, 0x8d, 0xfc, 0x4f, 0xf6, 0x9b, 0x40,
-	0x74, 0x61, 0xb9, 0x45, 0x3a, 0x83, 0x01, 0xde, 0x08, 0x63, 0x85, 0x2c, 0x3f, 0x2a, 0x63, 0x4d,
-	0x4a, 0xc6, 0xbd, 0x3c, 0x9e, 0x8e, 0x6a, 0xfe, 0xc4, 0x93, 0x26, 0xb3, 0x2e, 0x63, 0x26, 0xad,
-	0xbe, 0x65, 0xdb, 0x55, 0xf4, 0x19, 0xec, 0xab, 0x6b, 0x79, 0xa6, 0x2c, 0xa6, 0xc1, 0x98, 0xe4,
-	0xf1, 0x34, 0x6e, 0x2e, 0x70, 0x47, 0xac, 0xcd, 0xd1, 0xe7, 0x70, 0x80, 0x37, 0x16, 0xb5, 0xe4,
-	0x57, 0x1f, 0xbc, 0xac, 0xb4, 0x37, 0x26, 0x79, 0xc2, 0xee, 0x9c, 0x0e, 0xdf, 0x00, 0xfd, 0xbb,
-	0x27, 0x3d, 0x84, 0xde, 0x25, 0xde, 0xa6, 0x64, 0x4c, 0xf2, 0x3e, 0x73, 0x21, 0x3d, 0x82, 0xe8,
-	0x3b, 0xbf, 0x5a, 0xd5, 0x4d, 0x43, 0x56, 0x83, 0xd7, 0xc1, 0x2b, 0x92, 0x8d, 0xa0, 0x37, 0x37,
-	0x25, 0x4d, 0x61, 0xbf, 0x50, 0xd2, 0xa2, 0xb4, 0xbe, 0x2c, 0x61, 0x2d, 0xcc, 0x8e, 0x21, 0x9e,
-	0x9b, 0x92, 0xa1, 0xa9, 0x94, 0x34, 0xf8, 0x0f, 0xe2, 0x2f, 0x02, 0x03, 0x6f, 0xc3, 0x9a, 0xfb,
-	0x12, 0x92, 0x02, 0xb5, 0x15, 0x5f, 0x45, 0xc1, 0x2d, 0xb6, 0x96, 0xd1, 0x66, 0xe2, 0x77, 0x9b,
-	0x14, 0xdb, 0xe2, 0xd1, 0xa7, 0x10, 0x49, 0xe5, 0x0a, 0x02, 0x5f, 0xb0, 0x65, 0x51, 0x9d, 0xa1,
-	0xa7, 0x10, 0xf3, 0xa2, 0x58, 0x19, 0x6e, 0x85, 0x92, 0x26, 0xed, 0x79, 0xe2, 0xe3, 0x86, 0xf8,
-	0x76, 0x9d, 0x61, 0x5d, 0xd6, 0x0e, 0x57, 0xc3, 0x5d, 0xae, 0x66, 0x23, 0x88, 0x3b, 0xe2, 0x9c,
-	0x9d, 0x9a, 0x5f, 0x37, 0xe3, 0xba, 0x30, 0xfb, 0x49, 0x00, 0x36, 0x4d, 0x9c, 0xbb, 0x58, 0xa9,
-	0xe2, 0x9b, 0xa7, 0x84, 0xac, 0x06, 0xce, 0x29, 0xdf, 0x1c, 0xb5, 0x77, 0x3d, 0x61, 0x2d, 0xdc,
-	0x64, 0x16, 0xcd, 0xdf, 0xda, 0x42, 0x3a, 0x81, 0xbe, 0x11, 0xa5, 0xe4, 0x76, 0xa5, 0xd1, 0x8b,
-	0x8b, 0xa7, 0x87, 0xed, 0x86, 0xb5, 0xe7, 0x6c, 0x43, 0x71, 0x37, 0x69, 0x21, 0xcb, 0xb3, 0xd5,
-	0x32, 0x8d, 0xc6, 0x24, 0x1f, 0xb0, 0x16, 0x66, 0x15, 0x84, 0x7e, 0x93, 0x76, 0x6b, 0x3b, 0x80,
-	0x40, 0x2c, 0x1a, 0x59, 0x81, 0x58, 0x50, 0x0a, 0xe1, 0x92, 0x9b, 0x4b, 0x2f, 0x67, 0xc0, 0x7c,
-	0xfc, 0xbf, 0x5a, 0xb2, 0x63, 0xe8, 0xaf, 0xcf, 0x69, 0x02, 0x44, 0x37, 0x8e, 0x11, 0xed, 0x90,
-	0x69, 0xba, 0x11, 0x93, 0x9d, 0x40, 0xf8, 0x9e, 0x5b, 0x7e, 0xff, 0x2a, 0xdd, 0x95, 0x97, 0x3d,
-	0x81, 0xf0, 0x5c, 0xc8, 0xd2, 0x0d, 0x23, 0x95, 0x2c, 0xb0, 0xe1, 0xd7, 0x20, 0xfb, 0x04, 0xe1,
-	0xb9, 0xba, 0x2f, 0xbb, 0x3d, 0x46, 0xf0, 0xf0, 0x18, 0x43, 0x08, 0x3f, 0xa3, 0xb1, 0xce, 0x12,
-	0xb9, 0x5a, 0xd6, 0x4b, 0x1b, 0x31, 0x1f, 0x4f, 0x7f, 0x10, 0xd8, 0xab, 0x9f, 0x09, 0x3a, 0x81,
-	0xbd, 0x8b, 0x4a, 0x23, 0x5f, 0xd0, 0xa4, 0xfb, 0x04, 0x0c, 0x8f, 0xba, 0xa8, 0xfd, 0x12, 0xb2,
-	0x47, 0xf4, 0x05, 0xf4, 0xe7, 0x68, 0x0c, 0xca, 0x12, 0x35, 0x85, 0x86, 0x34, 0x37, 0xe5, 0x90,
-	0x6e, 0xe2, 0x0e, 0xdd, 0x5d, 0x6f, 0x35, 0xf2, 0xe5, 0xc3, 0xdc, 0x9c, 0x9c, 0x90, 0x2f, 0x7b,
-	0x3e, 0x71, 0xfa, 0x27, 0x00, 0x00, 0xff, 0xff, 0x4b, 0x51, 0xad, 0xc8, 0xc6, 0x04, 0x00, 0x00,
+	// 1127 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xdd, 0x6e, 0x1b, 0x45,
+	0x14, 0x66, 0x7f, 0x13, 0x9f, 0xb5, 0x43, 0x18, 0xa2, 0xc8, 0x8a, 0x8a, 0x6a, 0x56, 0x94, 0xf8,
+	0x86, 0xa8, 0x4a, 0x55, 0xa8, 0x40, 0xe2, 0x47, 0x34, 0x0a, 0x52, 0x9b, 0x2a, 0x9a, 0x84, 0x07,
+	0xd8, 0xec, 0x1e, 0x36, 0x43, 0xed, 0x9d, 0xed, 0xcc, 0xd8, 0x49, 0xae, 0xb8, 0xef, 0x35, 0x52,
+	0x6f, 0xe1, 0x96, 0x0b, 0x5e, 0x81, 0x57, 0x43, 0x33, 0x3b, 0xeb, 0xdd, 0x0d, 0x8e, 0xad, 0x00,
+	0x57, 0x9e, 0x6f, 0xcf, 0x8f, 0xbf, 0x73, 0xe6, 0x9b, 0x33, 0x03, 0xfd, 0x9c, 0x4b, 0xc9, 0xca,
+	0x83, 0x52, 0x70, 0xc5, 0x49, 0x60, 0x7e, 0xe2, 0xbf, 0x3c, 0x08, 0xce, 0x54, 0xa2, 0x90, 0x1c,
+	0xc1, 0x00, 0xaf, 0x99, 0x54, 0xac, 0xc8, 0x7f, 0xe0, 0x52, 0xc9, 0xa1, 0x33, 0xf2, 0xc6, 0xd1,
+	0xe1, 0xc3, 0xca, 0xff, 0xc0, 0x38, 0x1d, 0x1c, 0xb5, 0x3d, 0x8e, 0x0a, 0x25, 0x6e, 0x68, 0x37,
+	0x8a, 0x3c, 0x82, 0x0d, 0x7e, 0x55, 0xbc, 0xe2, 0x0a, 0x87, 0xee, 0xc8, 0x19, 0x47, 0x87, 0x91,
+	0x4d, 0xa0, 0x3f, 0xd1, 0xda, 0x46, 0x3e, 0x85, 0x2d, 0xbc, 0x56, 0x28, 0x8a, 0x64, 0x72, 0x6c,
+	0x68, 0x0d, 0xbd, 0x91, 0x33, 0xee, 0xd3, 0x5b, 0x5f, 0xc9, 0x37, 0x10, 0x29, 0x5e, 0xb2, 0xd4,
+	0x3a, 0xf9, 0x86, 0xd3, 0x47, 0x1d, 0x4e, 0xe7, 0x8d, 0xbd, 0x62, 0xd4, 0x8e, 0x20, 0x5f, 0xd4,
+	0x75, 0x3f, 0x67, 0x39, 0x4a, 0x35, 0x0c, 0x4c, 0x86, 0x0f, 0x6d, 0x86, 0xe3, 0x96, 0x89, 0x76,
+	0x1c, 0xc9, 0x27, 0x10, 0x8a, 0xd9, 0x94, 0x0b, 0x39, 0x0c, 0x4d, 0x48, 0xdf, 0x86, 0x50, 0xfd,
+	0x91, 0x5a, 0xdb, 0xde, 0xb7, 0x40, 0xfe, 0xd9, 0x13, 0xb2, 0x0d, 0xde, 0x6b, 0xbc, 0x19, 0x3a,
+	0x23, 0x67, 0xdc, 0xa3, 0x7a, 0x49, 0x76, 0x20, 0x98, 0x27, 0x93, 0x59, 0xd5, 0x14, 0x9f, 0x56,
+	0xe0, 0x4b, 0xf7, 0x99, 0xb3, 0xf7, 0x35, 0x6c, 0xdf, 0xae, 0x60, 0x5d, 0x7c, 0xbf, 0x15, 0x1f,
+	0x3f, 0x05, 0xef, 0x44, 0xe6, 0x64, 0x08, 0x1b, 0x29, 0x2f, 0x14, 0x16, 0xca, 0x84, 0xf5, 0x69,
+	0x0d, 0x75, 0xa8, 0x69, 0x88, 0x09, 0xed, 0xd1, 0x0a, 0xc4, 0xfb, 0x10, 0x9d, 0xc8, 0x9c, 0xa2,
+	0x2c, 0x79, 0x21, 0xf1, 0xee, 0xf0, 0xf8, 0xad, 0x07, 0x03, 0xd3, 0xe8, 0x85, 0xef, 0xe7, 0xd0,
+	0x4f, 0x51, 0x28, 0xf6, 0x13, 0x4b, 0x13, 0x85, 0xb5, 0x50, 0x88, 0xed, 0xcf, 0xf7, 0x8d, 0x89,
+	0x76, 0xfc, 0xc8, 0xc7, 0x10, 0x14, 0x5c, 0x07, 0xb8, 0x23, 0xef, 0xb6, 0x30, 0x2a, 0x0b, 0x79,
+	0x02, 0x51, 0x92, 0xa6, 0x33, 0x99, 0x28, 0xc6, 0x0b, 0x39, 0xf4, 0x8c, 0xe3, 0x07, 0xd6, 0xf1,
+	0xbb, 0x85, 0x85, 0xb6, 0xbd, 0x96, 0x68, 0xc9, 0x5f, 0xaa, 0xa5, 0xe3, 0xae, 0x96, 0x2a, 0x25,
+	0x3c, 0x6a, 0x6b, 0xa9, 0x2e, 0x71, 0x8d, 0xa6, 0x9e, 0xc1, 0x20, 0x5f, 0xd8, 0x18, 0xd6, 0x0a,
+	0x21, 0x1d, 0x51, 0xd9, 0xd3, 0xd1, 0x71, 0xfc, 0xcf, 0x9b, 0xfd, 0x10, 0xa2, 0x56, 0x7f, 0x75,
+	0xa8, 0x48, 0xae, 0xec, 0x8e, 0xe9, 0x65, 0xfc, 0x9b, 0x03, 0xd0, 0xf4, 0x49, 0x67, 0xc2, 0x92,
+	0xa7, 0x97, 0xc6, 0xc5, 0xa7, 0x15, 0xd0, 0x9b, 0x6d, 0xfa, 0x87, 0xc2, 0xfe, 0x43, 0x0d, 0x1b,
+	0x4b, 0x66, 0xcf, 0x63, 0x0d, 0xc9, 0x01, 0xf4, 0x24, 0xcb, 0x8b, 0x44, 0xcd, 0x04, 0x9a, 0xfe,
+	0x46, 0x87, 0xdb, 0x75, 0xeb, 0xea, 0xef, 0xb4, 0x71, 0xd1, 0x99, 0x04, 0x2b, 0xf2, 0x57, 0xb3,
+	0xe9, 0x30, 0x18, 0x39, 0xe3, 0x01, 0xad, 0x61, 0xfc, 0xd6, 0x01, 0xdf, 0xcc, 0x80, 0xe5, 0xe4,
+	0xb6, 0xc0, 0x65, 0x99, 0xe5, 0xe5, 0xb2, 0x8c, 0x10, 0xf0, 0xa7, 0x89, 0x7c, 0x6d, 0xf8, 0x0c,
+	0xa8, 0x59, 0xff, 0x1b, 0x32, 0x13, 0x4c, 0xe6, 0xac, 0xc8, 0x0d, 0x99, 0x4d, 0x5a, 0xc3, 0x78,
+	0x1f, 0x7a, 0x8b, 0x08, 0xd2, 0x07, 0x47, 0xd8, 0x66, 0x3a, 0x42, 0x23, 0x69, 0x79, 0x38, 0x32,
+	0x7e, 0x0c, 0xfe, 0xf3, 0x44, 0x25, 0x2b, 0xce, 0xd9, 0x2d, 0xe2, 0xf1, 0x03, 0xf0, 0x4f, 0x59,
+	0x91, 0xeb, 0x32, 0x0b, 0x5e, 0xa4, 0x68, 0xfd, 0x2b, 0x10, 0xbf, 0x04, 0xff, 0x94, 0xdf, 0x65,
+	0xed, 0x16, 0xe8, 0xae, 0x2d, 0x30, 0xde, 0x03, 0xff, 0x5c, 0x0f, 0x2d, 0x02, 0x7e, 0x31, 0x9b,
+	0x56, 0x47, 0x32, 0xa0, 0x66, 0x1d, 0xbf, 0x73, 0x20, 0x6a, 0xeb, 0x6d, 0x17, 0x42, 0x2e, 0x58,
+	0xce, 0x0a, 0xfb, 0x97, 0x16, 0xd5, 0x3a, 0x74, 0x1b, 0x1d, 0x0e, 0x61, 0x63, 0x8e, 0x42, 0x32,
+	0x5e, 0x98, 0xee, 0xfb, 0xb4, 0x86, 0x8d, 0x42, 0xfd, 0x96, 0x42, 0xbb, 0xac, 0x83, 0xf5, 0xac,
+	0x29, 0xf4, 0xdb, 0x03, 0xf8, 0xff, 0x60, 0x16, 0xff, 0xee, 0x40, 0x60, 0x46, 0xf4, 0x9d, 0xd9,
+	0x5a, 0x3b, 0xe8, 0x76, 0x77, 0x70, 0x1b, 0x3c, 0xa5, 0x26, 0x56, 0x69, 0x7a, 0x79, 0x6f, 0xa1,
+	0x6d, 0x83, 0x27, 0xf1, 0x8d, 0xa9, 0xdd, 0xa7, 0x7a, 0xa9, 0x77, 0xe4, 0x92, 0x97, 0x7a, 0x44,
+	0x18, 0xf9, 0xea, 0x75, 0xfc, 0x87, 0x03, 0x21, 0xbd, 0x48, 0xf5, 0xd8, 0x5e, 0x51, 0xb2, 0x4e,
+	0xe4, 0x36, 0x89, 0x76, 0x20, 0x28, 0x2f, 0x13, 0x89, 0x96, 0x5e, 0x05, 0xda, 0xc5, 0xf8, 0xdd,
+	0x62, 0x76, 0x21, 0x94, 0x58, 0x64, 0x28, 0x0c, 0x9b, 0x3e, 0xb5, 0xa8, 0x5b, 0x52, 0xb8, 0x7e,
+	0x93, 0xfe, 0x74, 0x60, 0xf3, 0xc5, 0x9c, 0x62, 0xca, 0x45, 0xd6, 0x9e, 0x55, 0xfd, 0x15, 0xb3,
+	0x6a, 0x85, 0x72, 0x76, 0x21, 0xbc, 0x12, 0x4c, 0xa1, 0xb0, 0x7c, 0x2d, 0xd2, 0x11, 0x19, 0x4e,
+	0x50, 0x61, 0x56, 0x1f, 0x51, 0x0b, 0xef, 0x4d, 0xf8, 0x67, 0xe8, 0x69, 0xbe, 0x6f, 0x66, 0x5a,
+	0x52, 0x5b, 0xe0, 0xf2, 0xd2, 0xf0, 0x1d, 0x50, 0x97, 0x97, 0x6d, 0x29, 0xd9, 0x02, 0xf6, 0x21,
+	0x14, 0xa6, 0x38, 0xc3, 0x34, 0x3a, 0x7c, 0xdf, 0xe6, 0xae, 0x6b, 0xa6, 0xd6, 0x6c, 0x76, 0x92,
+	0xd9, 0x3e, 0xf7, 0xa8, 0x59, 0xc7, 0x4f, 0x01, 0x5e, 0xcc, 0xeb, 0x5b, 0xa3, 0x95, 0xca, 0x59,
+	0x99, 0x2a, 0x3e, 0x07, 0xa0, 0x65, 0x5a, 0x73, 0xdc, 0x85, 0x70, 0x8a, 0xea, 0x92, 0x67, 0xf6,
+	0x0e, 0xb0, 0x48, 0xb7, 0x36, 0xe5, 0x19, 0x2e, 0x2e, 0x6e, 0x03, 0x74, 0xa3, 0xca, 0xe4, 0x66,
+	0xc2, 0x93, 0xc5, 0x88, 0xb6, 0x30, 0xfe, 0x11, 0x22, 0x93, 0xd5, 0xb2, 0x21, 0xe0, 0xeb, 0x08,
+	0x5b, 0xbc, 0x59, 0xeb, 0xe0, 0x29, 0x4a, 0x99, 0xe4, 0x68, 0x93, 0xd6, 0x70, 0x45, 0x5a, 0x3d,
+	0x3f, 0x4e, 0x67, 0x17, 0x13, 0x7d, 0xe5, 0xd8, 0x1d, 0x5c, 0x2a, 0xd9, 0xa5, 0xef, 0x8c, 0x5a,
+	0xc8, 0x5e, 0x23, 0xe4, 0xbb, 0x25, 0x7b, 0xdf, 0xf9, 0xb1, 0x0f, 0x83, 0xb3, 0xd9, 0x85, 0x4c,
+	0x05, 0x2b, 0xab, 0x97, 0xc0, 0x2e, 0x84, 0xe6, 0x5f, 0xab, 0x01, 0xd8, 0xa3, 0x16, 0xc5, 0xbf,
+	0x40, 0xf0, 0x12, 0xf5, 0x71, 0xd9, 0x81, 0x20, 0x17, 0x7c, 0x56, 0xda, 0x4e, 0x57, 0x40, 0x87,
+	0x4d, 0x30, 0xc9, 0x16, 0xd7, 0xa1, 0x45, 0x9a, 0x29, 0x5e, 0x97, 0x4c, 0xa0, 0x34, 0xfc, 0x3d,
+	0x5a, 0xc3, 0xfb, 0xce, 0x85, 0xf8, 0x57, 0x07, 0x36, 0x8f, 0x8a, 0x39, 0x4e, 0x78, 0xd9, 0x69,
+	0xb5, 0xd3, 0x69, 0x35, 0x19, 0x41, 0xd4, 0x7a, 0x31, 0x59, 0x36, 0xed, 0x4f, 0xe4, 0x01, 0xf4,
+	0x14, 0x9b, 0xa2, 0x54, 0xc9, 0xb4, 0xb4, 0xa4, 0x9a, 0x0f, 0xf7, 0xa6, 0xf5, 0x15, 0x44, 0xfa,
+	0x8a, 0x6a, 0x09, 0x51, 0x25, 0x22, 0xc7, 0xfa, 0x6a, 0xb3, 0xa8, 0xb9, 0xa3, 0xdc, 0xd6, 0x1d,
+	0x75, 0xf8, 0xce, 0x81, 0xb0, 0x7a, 0xdd, 0x90, 0x03, 0x08, 0xcf, 0x4a, 0x81, 0x49, 0x46, 0xfa,
+	0xed, 0xe7, 0xd4, 0xde, 0xce, 0xb2, 0xc7, 0x55, 0xfc, 0x1e, 0xf9, 0x0c, 0x7a, 0x27, 0x28, 0x25,
+	0x16, 0x39, 0x0a, 0x02, 0xd6, 0xe9, 0x44, 0xe6, 0x7b, 0xa4, 0x59, 0xb7, 0xdc, 0x75, 0x7a, 0x25,
+	0x30, 0x99, 0xae, 0xf7, 0x1d, 0x3b, 0x8f, 0x9d, 0x8b, 0xd0, 0x18, 0x9e, 0xfc, 0x3d, 0x00, 0xf8,
+	0xd5, 0xff, 0x99, 0xf2, 0x0c, 0x00, 0x00,
 }
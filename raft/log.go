@@ -0,0 +1,117 @@
+package raft
+
+// Entry is a single command of the replicated log.
+type Entry struct {
+	Index uint64
+	Term  uint64
+
+	// Nil for the entry appended by a new leader, which is not applied.
+	Data []byte
+}
+
+// Log entries following the most recent snapshot, indexes start at 1.
+type raftLog struct {
+	entries []Entry
+
+	// Index and term of the last entry covered by the snapshot.
+	snapIndex uint64
+	snapTerm  uint64
+	snapshot  []byte
+}
+
+func (l *raftLog) lastIndex() uint64 {
+	if len(l.entries) == 0 {
+		return l.snapIndex
+	}
+
+	return l.entries[len(l.entries)-1].Index
+}
+
+func (l *raftLog) lastTerm() uint64 {
+	if len(l.entries) == 0 {
+		return l.snapTerm
+	}
+
+	return l.entries[len(l.entries)-1].Term
+}
+
+// Returns the term of the entry at index, false if the entry is compacted or does not exist.
+func (l *raftLog) term(index uint64) (uint64, bool) {
+	if index == l.snapIndex {
+		return l.snapTerm, true
+	}
+
+	if index < l.snapIndex || index > l.lastIndex() {
+		return 0, false
+	}
+
+	return l.entries[index-l.snapIndex-1].Term, true
+}
+
+// Index must be between the snapshot and the last entry.
+func (l *raftLog) entry(index uint64) Entry {
+	return l.entries[index-l.snapIndex-1]
+}
+
+// Returns at most max entries starting at index.
+func (l *raftLog) from(index uint64, max int) []Entry {
+	if index <= l.snapIndex || index > l.lastIndex() {
+		return nil
+	}
+
+	start := index - l.snapIndex - 1
+	end := uint64(len(l.entries))
+
+	if end-start > uint64(max) {
+		end = start + uint64(max)
+	}
+
+	ret := make([]Entry, end-start)
+	copy(ret, l.entries[start:end])
+
+	return ret
+}
+
+func (l *raftLog) append(entries ...Entry) {
+	l.entries = append(l.entries, entries...)
+}
+
+// Removes the entry at index and all following entries.
+func (l *raftLog) truncate(index uint64) {
+	if index <= l.snapIndex {
+		l.entries = nil
+		return
+	}
+
+	if index > l.lastIndex() {
+		return
+	}
+
+	l.entries = l.entries[:index-l.snapIndex-1]
+}
+
+// Replaces all entries up to and including index with the given snapshot.
+func (l *raftLog) compact(index uint64, data []byte) {
+	term, ok := l.term(index)
+	if !ok || index <= l.snapIndex {
+		return
+	}
+
+	l.entries = append([]Entry(nil), l.entries[index-l.snapIndex:]...)
+	l.snapIndex = index
+	l.snapTerm = term
+	l.snapshot = data
+}
+
+// Installs a snapshot received from the leader, entries following it are kept if they match.
+func (l *raftLog) install(index, term uint64, data []byte) {
+	if t, ok := l.term(index); ok && t == term && index > l.snapIndex {
+		l.entries = append([]Entry(nil), l.entries[index-l.snapIndex:]...)
+	} else {
+		l.entries = nil
+	}
+
+	l.snapIndex = index
+	l.snapTerm = term
+	l.snapshot = data
+}
@@ -0,0 +1,933 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit"
+)
+
+const (
+	// Upper bound of entries sent in a single append call.
+	maxAppendEntries = 64
+)
+
+var (
+	// Returned by Propose if the local member is not the leader, see Leader.
+	ErrNotLeader = errors.New("Local member is not the leader")
+
+	// Returned by Propose if leadership was lost before the command committed,
+	// the command might still be committed by the next leader.
+	ErrLeadershipLost = errors.New("Leadership lost before the command was committed")
+
+	// Returned by Propose if the node was stopped before the command committed.
+	ErrStopped = errors.New("Raft node stopped")
+
+	errNoCommand      = errors.New("Command is empty")
+	errNotMember      = errors.New("Local member is not part of the group")
+	errUnknownSender  = errors.New("Sender is not part of the group")
+	errSenderMismatch = errors.New("Sender does not match the member named in the request")
+	errInvalidConfig  = errors.New("Heartbeat interval must be positive and shorter than the election timeout")
+)
+
+// Client is the part of the Ifrit client used by a raft node, satisfied by *ifrit.Client.
+type Client interface {
+	Id() string
+	Subscribe() (<-chan ifrit.Event, func())
+}
+
+// StateMachine is the replicated state, commands are applied in log order on every member.
+type StateMachine interface {
+	// Applies a committed command and returns the result handed to the proposer.
+	Apply(cmd []byte) []byte
+
+	// Returns the serialized state covering all applied commands.
+	Snapshot() ([]byte, error)
+
+	// Replaces the state with a snapshot.
+	Restore(data []byte) error
+}
+
+type Config struct {
+	// Ifrit ids of all members of the group, the local member included.
+	Members []string
+
+	// Followers start an election if they have not heard from the leader
+	// for a random duration between one and two election timeouts.
+	ElectionTimeout time.Duration
+
+	// How often the leader replicates its log to followers.
+	HeartbeatInterval time.Duration
+
+	// Number of applied entries after which the log is compacted into a snapshot, zero disables snapshots.
+	SnapshotThreshold uint64
+
+	// Persists term, vote, log and snapshot, nil keeps them in memory only.
+	Storage Storage
+}
+
+// Returns a config for the given members with a 1 second election timeout.
+func DefaultConfig(members []string) *Config {
+	return &Config{
+		Members:           members,
+		ElectionTimeout:   time.Second,
+		HeartbeatInterval: time.Millisecond * 100,
+		SnapshotThreshold: 1000,
+	}
+}
+
+type role uint8
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// Node is a member of a raft group replicating a log of commands among a fixed subset of the Ifrit network.
+// Members are identified by their Ifrit ids, calls are authenticated through Ifrit certificates.
+// Accusations, removals and departures of the leader observed by Ifrit's failure detector are used
+// as hints to start an election early, instead of waiting for the election timeout to expire.
+// Term, vote and log are saved to the configured storage before they are acted upon,
+// a member restarting with the same storage rejoins the group where it left off.
+type Node struct {
+	c       Client
+	t       Transport
+	sm      StateMachine
+	conf    *Config
+	storage Storage
+
+	id    string
+	peers []string
+
+	role     role
+	term     uint64
+	votedFor string
+	leader   string
+
+	// Term and vote as last saved to storage.
+	savedTerm uint64
+	savedVote string
+
+	log *raftLog
+
+	commitIndex uint64
+	lastApplied uint64
+
+	// Replication state of each follower, only used by the leader.
+	nextIndex  map[string]uint64
+	matchIndex map[string]uint64
+	inflight   map[string]bool
+
+	electionDeadline time.Time
+	rand             *rand.Rand
+
+	// Pending proposals indexed by the index of their entry.
+	waiters map[uint64]*waiter
+
+	mutex sync.Mutex
+
+	exitChan chan bool
+	stopOnce sync.Once
+}
+
+type waiter struct {
+	term uint64
+	ch   chan *result
+}
+
+type result struct {
+	data []byte
+	err  error
+}
+
+// Creates a raft node, restores the state saved in the configured storage and registers its handlers
+// on the transport. Start has to be invoked for the node to take part in elections and replication.
+func NewNode(c Client, t Transport, sm StateMachine, conf *Config) (*Node, error) {
+	if conf.HeartbeatInterval <= 0 || conf.HeartbeatInterval >= conf.ElectionTimeout {
+		return nil, errInvalidConfig
+	}
+
+	storage := conf.Storage
+	if storage == nil {
+		storage = NewMemoryStorage()
+	}
+
+	n := &Node{
+		c:          c,
+		t:          t,
+		sm:         sm,
+		conf:       conf,
+		storage:    storage,
+		id:         c.Id(),
+		log:        &raftLog{},
+		nextIndex:  make(map[string]uint64),
+		matchIndex: make(map[string]uint64),
+		inflight:   make(map[string]bool),
+		waiters:    make(map[uint64]*waiter),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		exitChan:   make(chan bool),
+	}
+
+	member := false
+
+	for _, id := range conf.Members {
+		if id == n.id {
+			member = true
+		} else {
+			n.peers = append(n.peers, id)
+		}
+	}
+
+	if !member {
+		return nil, errNotMember
+	}
+
+	if err := n.restore(); err != nil {
+		return nil, err
+	}
+
+	if err := t.Serve(handler{n}); err != nil {
+		return nil, err
+	}
+
+	n.resetDeadline()
+
+	return n, nil
+}
+
+// Takes part in elections and replication, blocks until Stop is invoked.
+func (n *Node) Start() {
+	events, cancel := n.c.Subscribe()
+	defer cancel()
+
+	ticker := time.NewTicker(n.conf.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.exitChan:
+			return
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+
+			switch e.Type {
+			case ifrit.PeerAccused, ifrit.PeerRemoved, ifrit.PeerLeft:
+				n.suspect(e.Id)
+			}
+		case <-ticker.C:
+			n.tick()
+		}
+	}
+}
+
+// Restores term, vote, log and snapshot from storage, the state machine is restored from the snapshot.
+// Committed entries following it are applied again once the leader reports them as committed.
+func (n *Node) restore() error {
+	state, err := n.storage.Load()
+	if err != nil {
+		return err
+	}
+
+	n.term = state.Term
+	n.votedFor = state.VotedFor
+	n.savedTerm = state.Term
+	n.savedVote = state.VotedFor
+
+	if state.SnapshotIndex > 0 {
+		if err := n.sm.Restore(state.Snapshot); err != nil {
+			return err
+		}
+
+		n.log.install(state.SnapshotIndex, state.SnapshotTerm, state.Snapshot)
+		n.commitIndex = state.SnapshotIndex
+		n.lastApplied = state.SnapshotIndex
+	}
+
+	for _, e := range state.Entries {
+		if e.Index > n.log.snapIndex {
+			n.log.append(e)
+		}
+	}
+
+	return nil
+}
+
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.exitChan)
+	})
+}
+
+// Appends cmd to the replicated log and blocks until it is committed and applied locally,
+// returns the result of applying it. Only the leader accepts proposals, others return ErrNotLeader.
+// Commands are linearizable, a command proposed after another returned observes its effects.
+func (n *Node) Propose(ctx context.Context, cmd []byte) ([]byte, error) {
+	if len(cmd) == 0 {
+		return nil, errNoCommand
+	}
+
+	n.mutex.Lock()
+
+	if n.role != leader {
+		n.mutex.Unlock()
+		return nil, ErrNotLeader
+	}
+
+	e := Entry{
+		Index: n.log.lastIndex() + 1,
+		Term:  n.term,
+		Data:  cmd,
+	}
+
+	w := &waiter{
+		term: n.term,
+		ch:   make(chan *result, 1),
+	}
+
+	if err := n.storage.SaveEntries(e.Index-1, []Entry{e}); err != nil {
+		n.mutex.Unlock()
+		return nil, err
+	}
+
+	n.log.append(e)
+	n.waiters[e.Index] = w
+	n.advanceCommit()
+
+	n.mutex.Unlock()
+
+	n.replicate()
+
+	select {
+	case r := <-w.ch:
+		return r.data, r.err
+	case <-ctx.Done():
+		n.mutex.Lock()
+		if n.waiters[e.Index] == w {
+			delete(n.waiters, e.Index)
+		}
+		n.mutex.Unlock()
+		return nil, ctx.Err()
+	case <-n.exitChan:
+		return nil, ErrStopped
+	}
+}
+
+// Returns the Ifrit id of the current leader, empty if unknown.
+func (n *Node) Leader() string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.leader
+}
+
+// Returns the current term.
+func (n *Node) Term() uint64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.term
+}
+
+func (n *Node) tick() {
+	n.mutex.Lock()
+	isLeader := n.role == leader
+	expired := time.Now().After(n.electionDeadline)
+	n.mutex.Unlock()
+
+	if isLeader {
+		n.replicate()
+	} else if expired {
+		n.startElection()
+	}
+}
+
+// The leader was reported as failed by Ifrit, start an election without awaiting the timeout.
+// Followers all receive the report, elections are staggered by member order to avoid split votes.
+func (n *Node) suspect(id string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.role == leader || id == "" || id != n.leader {
+		return
+	}
+
+	rank := 0
+	for _, m := range n.conf.Members {
+		if m == n.id {
+			break
+		}
+		if m != id {
+			rank++
+		}
+	}
+
+	log.Debug("Leader suspected, starting election", "leader", id, "term", n.term, "rank", rank)
+
+	n.leader = ""
+	n.electionDeadline = time.Now().Add(time.Duration(rank) * n.conf.HeartbeatInterval * 2)
+}
+
+func (n *Node) startElection() {
+	n.mutex.Lock()
+
+	n.role = candidate
+	n.term++
+	n.votedFor = n.id
+	n.leader = ""
+	n.resetDeadline()
+
+	if err := n.persistState(); err != nil {
+		log.Error(err.Error())
+		n.role = follower
+		n.mutex.Unlock()
+		return
+	}
+
+	req := &VoteRequest{
+		Term:         n.term,
+		Candidate:    n.id,
+		LastLogIndex: n.log.lastIndex(),
+		LastLogTerm:  n.log.lastTerm(),
+	}
+
+	if n.quorum() == 1 {
+		n.becomeLeader()
+		n.mutex.Unlock()
+		return
+	}
+
+	n.mutex.Unlock()
+
+	ch := make(chan *VoteResponse, len(n.peers))
+
+	for _, p := range n.peers {
+		go func(p string) {
+			ctx, cancel := context.WithTimeout(context.Background(), n.conf.ElectionTimeout)
+			defer cancel()
+
+			resp, err := n.t.RequestVote(ctx, p, req)
+			if err != nil {
+				log.Debug(err.Error(), "member", p)
+			}
+			ch <- resp
+		}(p)
+	}
+
+	go n.countVotes(req.Term, ch)
+}
+
+func (n *Node) countVotes(term uint64, ch chan *VoteResponse) {
+	votes := 1
+
+	for range n.peers {
+		resp := <-ch
+		if resp == nil {
+			continue
+		}
+
+		n.mutex.Lock()
+
+		if resp.Term > n.term {
+			n.stepDown(resp.Term)
+			n.mutex.Unlock()
+			return
+		}
+
+		if n.role != candidate || n.term != term {
+			n.mutex.Unlock()
+			return
+		}
+
+		if resp.Granted {
+			votes++
+		}
+
+		if votes >= n.quorum() {
+			n.becomeLeader()
+			n.mutex.Unlock()
+			n.replicate()
+			return
+		}
+
+		n.mutex.Unlock()
+	}
+}
+
+// Must hold the node lock.
+func (n *Node) becomeLeader() {
+	next := n.log.lastIndex() + 1
+	e := Entry{Index: next, Term: n.term}
+
+	if err := n.storage.SaveEntries(next-1, []Entry{e}); err != nil {
+		log.Error(err.Error())
+		n.stepDown(n.term)
+		return
+	}
+
+	log.Debug("Elected leader", "term", n.term)
+
+	n.role = leader
+	n.leader = n.id
+
+	for _, p := range n.peers {
+		n.nextIndex[p] = next
+		n.matchIndex[p] = 0
+		n.inflight[p] = false
+	}
+
+	// Entries of previous terms are only committed along with an entry of the current term.
+	n.log.append(e)
+	n.advanceCommit()
+}
+
+// Must hold the node lock.
+func (n *Node) stepDown(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+
+		if err := n.persistState(); err != nil {
+			log.Error(err.Error())
+		}
+	}
+
+	if n.role == leader {
+		for index, w := range n.waiters {
+			w.ch <- &result{err: ErrLeadershipLost}
+			delete(n.waiters, index)
+		}
+	}
+
+	n.role = follower
+	n.resetDeadline()
+}
+
+// Saves term and vote if they changed since the last save.
+// Must hold the node lock.
+func (n *Node) persistState() error {
+	if n.term == n.savedTerm && n.votedFor == n.savedVote {
+		return nil
+	}
+
+	if err := n.storage.SaveState(n.term, n.votedFor); err != nil {
+		return err
+	}
+
+	n.savedTerm = n.term
+	n.savedVote = n.votedFor
+
+	return nil
+}
+
+// Must hold the node lock.
+func (n *Node) resetDeadline() {
+	timeout := n.conf.ElectionTimeout + time.Duration(n.rand.Int63n(int64(n.conf.ElectionTimeout)))
+	n.electionDeadline = time.Now().Add(timeout)
+}
+
+func (n *Node) quorum() int {
+	return (len(n.peers)+1)/2 + 1
+}
+
+// Sends the entries missing on each follower, or a snapshot if they were compacted.
+// Followers with an outstanding call are skipped until it returns.
+func (n *Node) replicate() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.role != leader {
+		return
+	}
+
+	for _, p := range n.peers {
+		if n.inflight[p] {
+			continue
+		}
+
+		n.inflight[p] = true
+
+		next := n.nextIndex[p]
+
+		if next <= n.log.snapIndex {
+			req := &SnapshotRequest{
+				Term:      n.term,
+				Leader:    n.id,
+				LastIndex: n.log.snapIndex,
+				LastTerm:  n.log.snapTerm,
+				Data:      n.log.snapshot,
+			}
+
+			go n.sendSnapshot(p, req)
+			continue
+		}
+
+		prevTerm, _ := n.log.term(next - 1)
+
+		req := &AppendRequest{
+			Term:         n.term,
+			Leader:       n.id,
+			PrevLogIndex: next - 1,
+			PrevLogTerm:  prevTerm,
+			Entries:      n.log.from(next, maxAppendEntries),
+			LeaderCommit: n.commitIndex,
+		}
+
+		go n.sendAppend(p, req)
+	}
+}
+
+func (n *Node) sendAppend(p string, req *AppendRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.conf.ElectionTimeout)
+	defer cancel()
+
+	resp, err := n.t.AppendEntries(ctx, p, req)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.inflight[p] = false
+
+	if err != nil {
+		log.Debug(err.Error(), "member", p)
+		return
+	}
+
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return
+	}
+
+	if n.role != leader || n.term != req.Term {
+		return
+	}
+
+	if resp.Success {
+		match := req.PrevLogIndex + uint64(len(req.Entries))
+		if match > n.matchIndex[p] {
+			n.matchIndex[p] = match
+		}
+		n.nextIndex[p] = n.matchIndex[p] + 1
+		n.advanceCommit()
+		return
+	}
+
+	next := resp.LastIndex + 1
+	if next > req.PrevLogIndex {
+		next = req.PrevLogIndex
+	}
+	if next < 1 {
+		next = 1
+	}
+
+	n.nextIndex[p] = next
+}
+
+func (n *Node) sendSnapshot(p string, req *SnapshotRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.conf.ElectionTimeout)
+	defer cancel()
+
+	resp, err := n.t.InstallSnapshot(ctx, p, req)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.inflight[p] = false
+
+	if err != nil {
+		log.Debug(err.Error(), "member", p)
+		return
+	}
+
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return
+	}
+
+	if n.role != leader || n.term != req.Term {
+		return
+	}
+
+	if req.LastIndex > n.matchIndex[p] {
+		n.matchIndex[p] = req.LastIndex
+	}
+	n.nextIndex[p] = n.matchIndex[p] + 1
+	n.advanceCommit()
+}
+
+// Commits the most recent entry of the current term replicated on a majority.
+// Must hold the node lock.
+func (n *Node) advanceCommit() {
+	for index := n.log.lastIndex(); index > n.commitIndex; index-- {
+		if term, _ := n.log.term(index); term != n.term {
+			break
+		}
+
+		count := 1
+		for _, p := range n.peers {
+			if n.matchIndex[p] >= index {
+				count++
+			}
+		}
+
+		if count >= n.quorum() {
+			n.commitIndex = index
+			n.apply()
+			break
+		}
+	}
+}
+
+// Applies all committed entries and compacts the log once enough entries were applied.
+// Must hold the node lock.
+func (n *Node) apply() {
+	for n.lastApplied < n.commitIndex {
+		n.lastApplied++
+
+		e := n.log.entry(n.lastApplied)
+
+		var data []byte
+		if e.Data != nil {
+			data = n.sm.Apply(e.Data)
+		}
+
+		if w, ok := n.waiters[e.Index]; ok {
+			if w.term == e.Term {
+				w.ch <- &result{data: data}
+			} else {
+				w.ch <- &result{err: ErrLeadershipLost}
+			}
+			delete(n.waiters, e.Index)
+		}
+	}
+
+	if threshold := n.conf.SnapshotThreshold; threshold > 0 && n.lastApplied-n.log.snapIndex >= threshold {
+		data, err := n.sm.Snapshot()
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+
+		term, _ := n.log.term(n.lastApplied)
+
+		if err := n.storage.SaveSnapshot(n.lastApplied, term, data); err != nil {
+			log.Error(err.Error())
+			return
+		}
+
+		n.log.compact(n.lastApplied, data)
+	}
+}
+
+// Serves incoming calls, kept separate so the handler methods are not part of the node's api.
+type handler struct {
+	n *Node
+}
+
+func (h handler) HandleVote(sender string, req *VoteRequest) (*VoteResponse, error) {
+	n := h.n
+
+	if err := n.checkSender(sender, req.Candidate); err != nil {
+		return nil, err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if req.Term > n.term {
+		n.stepDown(req.Term)
+	}
+
+	resp := &VoteResponse{
+		Term: n.term,
+	}
+
+	if req.Term < n.term {
+		return resp, nil
+	}
+
+	upToDate := req.LastLogTerm > n.log.lastTerm() ||
+		(req.LastLogTerm == n.log.lastTerm() && req.LastLogIndex >= n.log.lastIndex())
+
+	if (n.votedFor == "" || n.votedFor == req.Candidate) && upToDate {
+		n.votedFor = req.Candidate
+		n.resetDeadline()
+		resp.Granted = true
+	}
+
+	if err := n.persistState(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h handler) HandleAppend(sender string, req *AppendRequest) (*AppendResponse, error) {
+	n := h.n
+
+	if err := n.checkSender(sender, req.Leader); err != nil {
+		return nil, err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	resp := &AppendResponse{
+		Term:      n.term,
+		LastIndex: n.log.lastIndex(),
+	}
+
+	if req.Term < n.term {
+		return resp, nil
+	}
+
+	if req.Term > n.term || n.role != follower {
+		n.stepDown(req.Term)
+	}
+
+	n.leader = req.Leader
+	n.resetDeadline()
+
+	if err := n.persistState(); err != nil {
+		return nil, err
+	}
+
+	resp.Term = n.term
+
+	if req.PrevLogIndex > n.log.lastIndex() {
+		return resp, nil
+	}
+
+	// Entries up to the snapshot are committed and therefore match the leader's log.
+	if req.PrevLogIndex >= n.log.snapIndex {
+		if term, _ := n.log.term(req.PrevLogIndex); term != req.PrevLogTerm {
+			resp.LastIndex = req.PrevLogIndex - 1
+			if resp.LastIndex < n.log.snapIndex {
+				resp.LastIndex = n.log.snapIndex
+			}
+			return resp, nil
+		}
+	}
+
+	var appended []Entry
+
+	for _, e := range req.Entries {
+		if e.Index <= n.log.snapIndex {
+			continue
+		}
+
+		if term, ok := n.log.term(e.Index); ok {
+			if term == e.Term {
+				continue
+			}
+			n.log.truncate(e.Index)
+		}
+
+		n.log.append(e)
+		appended = append(appended, e)
+	}
+
+	// Appended entries follow each other, entries before them matched and were kept.
+	if len(appended) > 0 {
+		first := appended[0].Index
+
+		if err := n.storage.SaveEntries(first-1, appended); err != nil {
+			n.log.truncate(first)
+			return nil, err
+		}
+	}
+
+	if req.LeaderCommit > n.commitIndex {
+		last := req.PrevLogIndex + uint64(len(req.Entries))
+		if req.LeaderCommit < last {
+			last = req.LeaderCommit
+		}
+
+		if last > n.commitIndex {
+			n.commitIndex = last
+			n.apply()
+		}
+	}
+
+	resp.Success = true
+	resp.LastIndex = n.log.lastIndex()
+
+	return resp, nil
+}
+
+func (h handler) HandleSnapshot(sender string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	n := h.n
+
+	if err := n.checkSender(sender, req.Leader); err != nil {
+		return nil, err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	resp := &SnapshotResponse{
+		Term: n.term,
+	}
+
+	if req.Term < n.term {
+		return resp, nil
+	}
+
+	if req.Term > n.term || n.role != follower {
+		n.stepDown(req.Term)
+	}
+
+	n.leader = req.Leader
+	n.resetDeadline()
+
+	if err := n.persistState(); err != nil {
+		return nil, err
+	}
+
+	resp.Term = n.term
+
+	if req.LastIndex <= n.commitIndex {
+		return resp, nil
+	}
+
+	if err := n.storage.SaveSnapshot(req.LastIndex, req.LastTerm, req.Data); err != nil {
+		return nil, err
+	}
+
+	if err := n.sm.Restore(req.Data); err != nil {
+		return nil, err
+	}
+
+	n.log.install(req.LastIndex, req.LastTerm, req.Data)
+
+	// Entries following a conflicting snapshot were discarded.
+	if err := n.storage.SaveEntries(req.LastIndex, n.log.from(req.LastIndex+1, len(n.log.entries))); err != nil {
+		return nil, err
+	}
+	n.commitIndex = req.LastIndex
+	n.lastApplied = req.LastIndex
+
+	return resp, nil
+}
+
+// Calls must originate from a member and concern the sender itself.
+func (n *Node) checkSender(sender, claimed string) error {
+	if sender != claimed {
+		return errSenderMismatch
+	}
+
+	for _, p := range n.peers {
+		if p == sender {
+			return nil
+		}
+	}
+
+	return errUnknownSender
+}
@@ -0,0 +1,615 @@
+package raft
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joonnna/ifrit"
+	"github.com/joonnna/ifrit/rpc"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+var errUnreachable = errors.New("Member unreachable")
+
+// In-memory network delivering calls directly to the handler of the destination.
+type testNet struct {
+	handlers map[string]Handler
+	down     map[string]bool
+
+	mutex sync.RWMutex
+}
+
+type testTransport struct {
+	net *testNet
+	id  string
+}
+
+func (t *testTransport) handler(dest string) (Handler, error) {
+	t.net.mutex.RLock()
+	defer t.net.mutex.RUnlock()
+
+	h, ok := t.net.handlers[dest]
+	if !ok || t.net.down[dest] || t.net.down[t.id] {
+		return nil, errUnreachable
+	}
+
+	return h, nil
+}
+
+func (t *testTransport) RequestVote(ctx context.Context, dest string, req *VoteRequest) (*VoteResponse, error) {
+	h, err := t.handler(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.HandleVote(t.id, req)
+}
+
+func (t *testTransport) AppendEntries(ctx context.Context, dest string, req *AppendRequest) (*AppendResponse, error) {
+	h, err := t.handler(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.HandleAppend(t.id, req)
+}
+
+func (t *testTransport) InstallSnapshot(ctx context.Context, dest string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	h, err := t.handler(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.HandleSnapshot(t.id, req)
+}
+
+func (t *testTransport) Serve(h Handler) error {
+	t.net.mutex.Lock()
+	defer t.net.mutex.Unlock()
+
+	t.net.handlers[t.id] = h
+
+	return nil
+}
+
+type testClient struct {
+	id     string
+	events chan ifrit.Event
+}
+
+func (c *testClient) Id() string {
+	return c.id
+}
+
+func (c *testClient) Subscribe() (<-chan ifrit.Event, func()) {
+	return c.events, func() {}
+}
+
+// Appends commands to a list, results are the length of the list.
+type testSM struct {
+	cmds []string
+
+	mutex sync.Mutex
+}
+
+func (sm *testSM) Apply(cmd []byte) []byte {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.cmds = append(sm.cmds, string(cmd))
+
+	return []byte(fmt.Sprintf("%d", len(sm.cmds)))
+}
+
+func (sm *testSM) Snapshot() ([]byte, error) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	return json.Marshal(sm.cmds)
+}
+
+func (sm *testSM) Restore(data []byte) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.cmds = nil
+
+	return json.Unmarshal(data, &sm.cmds)
+}
+
+func (sm *testSM) state() []string {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	return append([]string(nil), sm.cmds...)
+}
+
+type RaftTestSuite struct {
+	suite.Suite
+
+	net      *testNet
+	ids      []string
+	clients  map[string]*testClient
+	nodes    map[string]*Node
+	sms      map[string]*testSM
+	confs    map[string]*Config
+	storages map[string]Storage
+}
+
+func TestRaftTestSuite(t *testing.T) {
+	suite.Run(t, new(RaftTestSuite))
+}
+
+func (suite *RaftTestSuite) TearDownTest() {
+	for _, n := range suite.nodes {
+		n.Stop()
+	}
+}
+
+// Creates and starts a group of size members.
+func (suite *RaftTestSuite) startGroup(size int, electionTimeout time.Duration, threshold uint64) {
+	suite.net = &testNet{
+		handlers: make(map[string]Handler),
+		down:     make(map[string]bool),
+	}
+
+	suite.ids = nil
+	suite.clients = make(map[string]*testClient)
+	suite.nodes = make(map[string]*Node)
+	suite.sms = make(map[string]*testSM)
+	suite.confs = make(map[string]*Config)
+	suite.storages = make(map[string]Storage)
+
+	for i := 0; i < size; i++ {
+		suite.ids = append(suite.ids, fmt.Sprintf("id%d", i))
+	}
+
+	for _, id := range suite.ids {
+		conf := &Config{
+			Members:           suite.ids,
+			ElectionTimeout:   electionTimeout,
+			HeartbeatInterval: time.Millisecond * 10,
+			SnapshotThreshold: threshold,
+			Storage:           NewMemoryStorage(),
+		}
+
+		c := &testClient{id: id, events: make(chan ifrit.Event, 10)}
+		sm := &testSM{}
+
+		n, err := NewNode(c, &testTransport{net: suite.net, id: id}, sm, conf)
+		require.NoError(suite.T(), err, "Failed to create node.")
+
+		suite.clients[id] = c
+		suite.nodes[id] = n
+		suite.sms[id] = sm
+		suite.confs[id] = conf
+
+		go n.Start()
+	}
+}
+
+// Stops the member and replaces it with a new node restoring from the same storage.
+func (suite *RaftTestSuite) restart(id string) {
+	suite.nodes[id].Stop()
+
+	sm := &testSM{}
+
+	n, err := NewNode(suite.clients[id], &testTransport{net: suite.net, id: id}, sm, suite.confs[id])
+	require.NoError(suite.T(), err, "Failed to restart node.")
+
+	suite.nodes[id] = n
+	suite.sms[id] = sm
+
+	go n.Start()
+}
+
+func (suite *RaftTestSuite) setDown(id string, down bool) {
+	suite.net.mutex.Lock()
+	defer suite.net.mutex.Unlock()
+
+	suite.net.down[id] = down
+}
+
+func (suite *RaftTestSuite) isDown(id string) bool {
+	suite.net.mutex.RLock()
+	defer suite.net.mutex.RUnlock()
+
+	return suite.net.down[id]
+}
+
+// Waits for a reachable member other than exclude to become leader.
+func (suite *RaftTestSuite) waitLeader(exclude string, timeout time.Duration) string {
+	var ret string
+
+	require.Eventually(suite.T(), func() bool {
+		for id, n := range suite.nodes {
+			if id != exclude && !suite.isDown(id) && n.Leader() == id {
+				ret = id
+				return true
+			}
+		}
+		return false
+	}, timeout, time.Millisecond*5, "No leader elected.")
+
+	return ret
+}
+
+func (suite *RaftTestSuite) propose(id, cmd string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	resp, err := suite.nodes[id].Propose(ctx, []byte(cmd))
+	require.NoError(suite.T(), err, "Propose failed.")
+
+	return string(resp)
+}
+
+func (suite *RaftTestSuite) waitState(id string, expected []string) {
+	require.Eventually(suite.T(), func() bool {
+		return fmt.Sprint(suite.sms[id].state()) == fmt.Sprint(expected)
+	}, time.Second*2, time.Millisecond*5, "State of %s did not converge.", id)
+}
+
+func (suite *RaftTestSuite) TestNewNode() {
+	net := &testNet{handlers: make(map[string]Handler), down: make(map[string]bool)}
+	c := &testClient{id: "id0", events: make(chan ifrit.Event)}
+
+	conf := DefaultConfig([]string{"id1", "id2"})
+	_, err := NewNode(c, &testTransport{net: net, id: "id0"}, &testSM{}, conf)
+	require.Equal(suite.T(), errNotMember, err, "Should reject node outside the group.")
+
+	conf = DefaultConfig([]string{"id0"})
+	conf.HeartbeatInterval = conf.ElectionTimeout
+	_, err = NewNode(c, &testTransport{net: net, id: "id0"}, &testSM{}, conf)
+	require.Equal(suite.T(), errInvalidConfig, err, "Should reject heartbeat longer than election timeout.")
+}
+
+func (suite *RaftTestSuite) TestReplication() {
+	suite.startGroup(3, time.Millisecond*50, 0)
+
+	leader := suite.waitLeader("", time.Second*2)
+
+	for _, id := range suite.ids {
+		if id != leader {
+			_, err := suite.nodes[id].Propose(context.Background(), []byte("cmd"))
+			require.Equal(suite.T(), ErrNotLeader, err, "Followers should reject proposals.")
+		}
+	}
+
+	_, err := suite.nodes[leader].Propose(context.Background(), nil)
+	require.Equal(suite.T(), errNoCommand, err, "Empty command should be rejected.")
+
+	require.Equal(suite.T(), "1", suite.propose(leader, "a"), "Invalid apply result.")
+	require.Equal(suite.T(), "2", suite.propose(leader, "b"), "Invalid apply result.")
+	require.Equal(suite.T(), "3", suite.propose(leader, "c"), "Invalid apply result.")
+
+	for _, id := range suite.ids {
+		suite.waitState(id, []string{"a", "b", "c"})
+	}
+}
+
+func (suite *RaftTestSuite) TestLeaderFailure() {
+	suite.startGroup(3, time.Millisecond*50, 0)
+
+	old := suite.waitLeader("", time.Second*2)
+	suite.propose(old, "a")
+
+	suite.setDown(old, true)
+
+	leader := suite.waitLeader(old, time.Second*2)
+	require.NotEqual(suite.T(), old, leader, "Unreachable member should not remain leader.")
+
+	suite.propose(leader, "b")
+
+	for _, id := range suite.ids {
+		if id != old {
+			suite.waitState(id, []string{"a", "b"})
+		}
+	}
+
+	suite.setDown(old, false)
+
+	suite.waitState(old, []string{"a", "b"})
+
+	require.Eventually(suite.T(), func() bool {
+		return suite.nodes[old].Leader() != old
+	}, time.Second*2, time.Millisecond*5, "Former leader did not step down.")
+}
+
+func (suite *RaftTestSuite) TestSnapshot() {
+	var expected []string
+
+	suite.startGroup(3, time.Millisecond*50, 5)
+
+	leader := suite.waitLeader("", time.Second*2)
+
+	var lagging string
+	for _, id := range suite.ids {
+		if id != leader {
+			lagging = id
+			break
+		}
+	}
+
+	suite.setDown(lagging, true)
+
+	for i := 0; i < 20; i++ {
+		cmd := fmt.Sprintf("cmd%d", i)
+		expected = append(expected, cmd)
+		suite.propose(leader, cmd)
+	}
+
+	n := suite.nodes[leader]
+	n.mutex.Lock()
+	snapIndex := n.log.snapIndex
+	n.mutex.Unlock()
+
+	require.True(suite.T(), snapIndex > 0, "Leader did not compact its log.")
+
+	suite.setDown(lagging, false)
+
+	suite.waitState(lagging, expected)
+
+	n = suite.nodes[lagging]
+	n.mutex.Lock()
+	require.True(suite.T(), n.log.snapIndex > 0, "Lagging member should have installed a snapshot.")
+	n.mutex.Unlock()
+}
+
+func (suite *RaftTestSuite) TestSuspectLeader() {
+	// Elections are only started through suspicion within the test timeout.
+	suite.startGroup(3, time.Second*5, 0)
+
+	old := suite.ids[0]
+	suite.nodes[old].startElection()
+	suite.waitLeader("", time.Second)
+
+	suite.propose(old, "a")
+	for _, id := range suite.ids {
+		suite.waitState(id, []string{"a"})
+	}
+
+	suite.setDown(old, true)
+
+	for _, id := range suite.ids[1:] {
+		suite.clients[id].events <- ifrit.Event{Type: ifrit.PeerAccused, Id: old}
+	}
+
+	leader := suite.waitLeader(old, time.Second*2)
+	require.NotEqual(suite.T(), old, leader, "Suspected leader should be replaced.")
+}
+
+func (suite *RaftTestSuite) TestRestart() {
+	// Elections are not started within the test timeout, calls are made directly.
+	suite.startGroup(3, time.Second*5, 0)
+
+	suite.confs["id0"].Storage = NewFileStorage(suite.T().TempDir())
+	suite.restart("id0")
+
+	h := handler{suite.nodes["id0"]}
+
+	vote, err := h.HandleVote("id1", &VoteRequest{Term: 1, Candidate: "id1"})
+	require.NoError(suite.T(), err, "Vote failed.")
+	require.True(suite.T(), vote.Granted, "Vote should be granted.")
+
+	entries := []Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1, Data: []byte("a")}}
+
+	resp, err := h.HandleAppend("id1", &AppendRequest{Term: 1, Leader: "id1", Entries: entries, LeaderCommit: 2})
+	require.NoError(suite.T(), err, "Append failed.")
+	require.True(suite.T(), resp.Success, "Append should succeed.")
+
+	suite.restart("id0")
+
+	n := suite.nodes["id0"]
+	h = handler{n}
+
+	require.Equal(suite.T(), uint64(1), n.Term(), "Term should be restored.")
+
+	vote, err = h.HandleVote("id2", &VoteRequest{Term: 1, Candidate: "id2", LastLogIndex: 2, LastLogTerm: 1})
+	require.NoError(suite.T(), err, "Vote failed.")
+	require.False(suite.T(), vote.Granted, "Member should not vote twice in the same term after a restart.")
+
+	n.mutex.Lock()
+	require.Equal(suite.T(), uint64(2), n.log.lastIndex(), "Log should be restored.")
+	n.mutex.Unlock()
+
+	// Committed entries are applied again once the leader reports them.
+	_, err = h.HandleAppend("id1", &AppendRequest{Term: 1, Leader: "id1", PrevLogIndex: 2, PrevLogTerm: 1, LeaderCommit: 2})
+	require.NoError(suite.T(), err, "Append failed.")
+	require.Equal(suite.T(), []string{"a"}, suite.sms["id0"].state(), "Committed entries should be applied.")
+
+	snap, err := json.Marshal([]string{"a", "b", "c"})
+	require.NoError(suite.T(), err, "Failed to marshal snapshot.")
+
+	_, err = h.HandleSnapshot("id1", &SnapshotRequest{Term: 2, Leader: "id1", LastIndex: 5, LastTerm: 2, Data: snap})
+	require.NoError(suite.T(), err, "Snapshot failed.")
+
+	suite.restart("id0")
+
+	n = suite.nodes["id0"]
+
+	require.Equal(suite.T(), uint64(2), n.Term(), "Term should be restored.")
+	require.Equal(suite.T(), []string{"a", "b", "c"}, suite.sms["id0"].state(), "Snapshot should be restored.")
+
+	n.mutex.Lock()
+	require.Equal(suite.T(), uint64(5), n.log.lastIndex(), "Log should follow the snapshot.")
+	n.mutex.Unlock()
+}
+
+func (suite *RaftTestSuite) TestCheckSender() {
+	suite.startGroup(3, time.Second*5, 0)
+
+	h := handler{suite.nodes["id0"]}
+
+	_, err := h.HandleVote("id1", &VoteRequest{Term: 1, Candidate: "id2"})
+	require.Equal(suite.T(), errSenderMismatch, err, "Should reject votes on behalf of others.")
+
+	_, err = h.HandleAppend("id9", &AppendRequest{Term: 1, Leader: "id9"})
+	require.Equal(suite.T(), errUnknownSender, err, "Should reject calls from non-members.")
+
+	_, err = h.HandleSnapshot("id1", &SnapshotRequest{Term: 1, Leader: "id2"})
+	require.Equal(suite.T(), errSenderMismatch, err, "Should reject snapshots on behalf of others.")
+}
+
+func TestLog(t *testing.T) {
+	l := &raftLog{}
+
+	for i := uint64(1); i <= 10; i++ {
+		l.append(Entry{Index: i, Term: (i + 1) / 2})
+	}
+
+	require.Equal(t, uint64(10), l.lastIndex(), "Invalid last index.")
+	require.Equal(t, uint64(5), l.lastTerm(), "Invalid last term.")
+	require.Len(t, l.from(3, 4), 4, "Should cap number of entries.")
+
+	l.truncate(9)
+	require.Equal(t, uint64(8), l.lastIndex(), "Truncate should remove following entries.")
+
+	l.compact(4, []byte("snap"))
+	require.Equal(t, uint64(4), l.snapIndex, "Invalid snapshot index.")
+	require.Equal(t, uint64(2), l.snapTerm, "Invalid snapshot term.")
+
+	term, ok := l.term(4)
+	require.True(t, ok, "Snapshot term should be known.")
+	require.Equal(t, uint64(2), term, "Invalid snapshot term.")
+
+	_, ok = l.term(3)
+	require.False(t, ok, "Compacted entries should be unknown.")
+	require.Nil(t, l.from(4, 10), "Compacted entries should not be returned.")
+	require.Equal(t, uint64(5), l.entry(5).Index, "Invalid entry after compaction.")
+
+	// Matching snapshot keeps following entries, conflicting snapshot discards them.
+	l.install(6, 3, []byte("snap"))
+	require.Equal(t, uint64(8), l.lastIndex(), "Matching entries should be kept.")
+
+	l.install(7, 9, []byte("snap"))
+	require.Equal(t, uint64(7), l.lastIndex(), "Conflicting entries should be discarded.")
+	require.Equal(t, uint64(9), l.lastTerm(), "Invalid term after install.")
+}
+
+func TestStorage(t *testing.T) {
+	for name, storage := range map[string]Storage{"memory": NewMemoryStorage(), "file": NewFileStorage(t.TempDir())} {
+		state, err := storage.Load()
+		require.NoError(t, err, "Load of empty %s storage failed.", name)
+		require.Equal(t, &State{}, state, "Empty %s storage should return the zero state.", name)
+
+		require.NoError(t, storage.SaveState(3, "id1"), "Failed to save state.")
+
+		for i := uint64(1); i <= 5; i++ {
+			require.NoError(t, storage.SaveEntries(i-1, []Entry{{Index: i, Term: 1}}), "Failed to save entry.")
+		}
+
+		require.NoError(t, storage.SaveEntries(3, []Entry{{Index: 4, Term: 2}}), "Failed to replace entries.")
+		require.NoError(t, storage.SaveSnapshot(2, 1, []byte("snap")), "Failed to save snapshot.")
+
+		state, err = storage.Load()
+		require.NoError(t, err, "Load of %s storage failed.", name)
+		require.Equal(t, uint64(3), state.Term, "Invalid term.")
+		require.Equal(t, "id1", state.VotedFor, "Invalid vote.")
+		require.Equal(t, uint64(2), state.SnapshotIndex, "Invalid snapshot index.")
+		require.Equal(t, []byte("snap"), state.Snapshot, "Invalid snapshot.")
+		require.Equal(t, []Entry{{Index: 3, Term: 1}, {Index: 4, Term: 2}}, state.Entries,
+			"Entries should follow the snapshot, replaced entries should be discarded.")
+	}
+}
+
+// Ifrit client delivering rpc calls to other in-memory clients.
+type testIfrit struct {
+	id      string
+	addr    string
+	peers   map[string]*testIfrit
+	handler func(*ifrit.Request) ([]byte, error)
+}
+
+func (c *testIfrit) Member(id string) (*ifrit.Member, error) {
+	for _, p := range c.peers {
+		if p.id == id {
+			return &ifrit.Member{Id: p.id, Addr: p.addr}, nil
+		}
+	}
+
+	return nil, ifrit.ErrUnknownPeer
+}
+
+func (c *testIfrit) SendToTopic(ctx context.Context, dest, topic string, data []byte) ([]byte, error) {
+	return c.peers[dest].handler(&ifrit.Request{Context: ctx, SenderId: c.id, SenderAddr: c.addr, Data: data})
+}
+
+func (c *testIfrit) RegisterMsgHandlerFor(topic string, handler func(*ifrit.Request) ([]byte, error)) error {
+	c.handler = handler
+	return nil
+}
+
+type recordingHandler struct {
+	sender  string
+	claimed string
+}
+
+func (h *recordingHandler) HandleVote(sender string, req *VoteRequest) (*VoteResponse, error) {
+	h.sender, h.claimed = sender, req.Candidate
+	return &VoteResponse{Term: req.Term, Granted: true}, nil
+}
+
+func (h *recordingHandler) HandleAppend(sender string, req *AppendRequest) (*AppendResponse, error) {
+	h.sender, h.claimed = sender, req.Leader
+	return &AppendResponse{Term: req.Term, Success: true, LastIndex: uint64(len(req.Entries))}, nil
+}
+
+func (h *recordingHandler) HandleSnapshot(sender string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	h.sender, h.claimed = sender, req.Leader
+	return &SnapshotResponse{Term: req.Term}, nil
+}
+
+// Returns a binary id shaped like an Ifrit id.
+func binaryId(name string) string {
+	h := sha256.Sum256([]byte(name))
+	return string(h[:])
+}
+
+func TestTransport(t *testing.T) {
+	peers := make(map[string]*testIfrit)
+
+	a := &testIfrit{id: binaryId("a"), addr: "addrA", peers: peers}
+	b := &testIfrit{id: binaryId("b"), addr: "addrB", peers: peers}
+	peers[a.addr] = a
+	peers[b.addr] = b
+
+	h := &recordingHandler{}
+
+	sa, err := rpc.NewServer(a)
+	require.NoError(t, err, "Failed to create server.")
+
+	sb, err := rpc.NewServer(b)
+	require.NoError(t, err, "Failed to create server.")
+
+	ta := NewTransport(a, sa, "meta")
+	tb := NewTransport(b, sb, "meta")
+	require.NoError(t, tb.Serve(h), "Serve failed.")
+
+	ctx := context.Background()
+
+	vote, err := ta.RequestVote(ctx, b.id, &VoteRequest{Term: 2, Candidate: a.id})
+	require.NoError(t, err, "Vote call failed.")
+	require.True(t, vote.Granted, "Invalid response.")
+	require.Equal(t, a.id, h.sender, "Sender should be the authenticated caller.")
+	require.Equal(t, a.id, h.claimed, "Candidate id was altered in transit.")
+
+	resp, err := ta.AppendEntries(ctx, b.id, &AppendRequest{Term: 2, Leader: a.id, Entries: []Entry{{Index: 1, Term: 2, Data: []byte("x")}}})
+	require.NoError(t, err, "Append call failed.")
+	require.Equal(t, uint64(1), resp.LastIndex, "Entries were not transferred.")
+	require.Equal(t, a.id, h.claimed, "Leader id was altered in transit.")
+
+	_, err = ta.InstallSnapshot(ctx, b.id, &SnapshotRequest{Term: 2, Leader: a.id, Data: []byte("snap")})
+	require.NoError(t, err, "Snapshot call failed.")
+	require.Equal(t, a.id, h.claimed, "Leader id was altered in transit.")
+
+	_, err = ta.RequestVote(ctx, "unknown", &VoteRequest{Term: 2, Candidate: a.id})
+	require.Error(t, err, "Call to unknown member should fail.")
+}
@@ -0,0 +1,296 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	stateFile    = "state"
+	logFile      = "log"
+	snapshotFile = "snapshot"
+)
+
+// Storage persists the state a member needs to rejoin its group after a restart.
+// The node saves changes before replying to the call that caused them, a save that
+// returns nil must therefore survive a crash.
+type Storage interface {
+	// Saves the current term and the member voted for in it, empty if none.
+	SaveState(term uint64, votedFor string) error
+
+	// Replaces all saved entries following index with the given entries.
+	SaveEntries(index uint64, entries []Entry) error
+
+	// Saves a snapshot covering all entries up to and including index, saved entries up to index are discarded.
+	SaveSnapshot(index, term uint64, data []byte) error
+
+	// Returns the saved state, the zero state if nothing was saved.
+	Load() (*State, error)
+}
+
+// State is the persistent state of a member, see Storage.
+type State struct {
+	Term     uint64
+	VotedFor string
+
+	// Index and term of the last entry covered by the snapshot, zero if there is none.
+	SnapshotIndex uint64
+	SnapshotTerm  uint64
+	Snapshot      []byte
+
+	// Entries following the snapshot.
+	Entries []Entry
+}
+
+type memoryStorage struct {
+	state State
+
+	mutex sync.Mutex
+}
+
+type fileStorage struct {
+	dir string
+
+	// Saved entries, the log file is rewritten on each save. The log is bounded by the snapshot threshold.
+	entries []Entry
+	loaded  bool
+
+	mutex sync.Mutex
+}
+
+type snapshotRecord struct {
+	Index uint64
+	Term  uint64
+	Data  []byte
+}
+
+type stateRecord struct {
+	Term     uint64
+	VotedFor string
+}
+
+// Returns a storage keeping the state in memory, it survives restarts of the node but not of the process.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{}
+}
+
+// Returns a storage writing to and reading from the given directory,
+// the directory is created on the first save.
+func NewFileStorage(dir string) Storage {
+	return &fileStorage{
+		dir: dir,
+	}
+}
+
+func (ms *memoryStorage) SaveState(term uint64, votedFor string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.state.Term = term
+	ms.state.VotedFor = votedFor
+
+	return nil
+}
+
+func (ms *memoryStorage) SaveEntries(index uint64, entries []Entry) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.state.Entries = replaceEntries(ms.state.Entries, index, entries)
+
+	return nil
+}
+
+func (ms *memoryStorage) SaveSnapshot(index, term uint64, data []byte) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.state.SnapshotIndex = index
+	ms.state.SnapshotTerm = term
+	ms.state.Snapshot = data
+	ms.state.Entries = discardEntries(ms.state.Entries, index)
+
+	return nil
+}
+
+func (ms *memoryStorage) Load() (*State, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ret := ms.state
+	ret.Entries = append([]Entry(nil), ms.state.Entries...)
+
+	return &ret, nil
+}
+
+func (fs *fileStorage) SaveState(term uint64, votedFor string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.write(stateFile, &stateRecord{Term: term, VotedFor: votedFor})
+}
+
+func (fs *fileStorage) SaveEntries(index uint64, entries []Entry) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := fs.loadEntries(); err != nil {
+		return err
+	}
+
+	updated := replaceEntries(fs.entries, index, entries)
+
+	if err := fs.write(logFile, updated); err != nil {
+		return err
+	}
+
+	fs.entries = updated
+
+	return nil
+}
+
+func (fs *fileStorage) SaveSnapshot(index, term uint64, data []byte) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := fs.loadEntries(); err != nil {
+		return err
+	}
+
+	if err := fs.write(snapshotFile, &snapshotRecord{Index: index, Term: term, Data: data}); err != nil {
+		return err
+	}
+
+	updated := discardEntries(fs.entries, index)
+
+	if err := fs.write(logFile, updated); err != nil {
+		return err
+	}
+
+	fs.entries = updated
+
+	return nil
+}
+
+func (fs *fileStorage) Load() (*State, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	ret := &State{}
+
+	s := &stateRecord{}
+	if err := fs.read(stateFile, s); err != nil {
+		return nil, err
+	}
+
+	snap := &snapshotRecord{}
+	if err := fs.read(snapshotFile, snap); err != nil {
+		return nil, err
+	}
+
+	if err := fs.loadEntries(); err != nil {
+		return nil, err
+	}
+
+	ret.Term = s.Term
+	ret.VotedFor = s.VotedFor
+	ret.SnapshotIndex = snap.Index
+	ret.SnapshotTerm = snap.Term
+	ret.Snapshot = snap.Data
+	ret.Entries = discardEntries(fs.entries, snap.Index)
+
+	return ret, nil
+}
+
+func (fs *fileStorage) loadEntries() error {
+	if fs.loaded {
+		return nil
+	}
+
+	if err := fs.read(logFile, &fs.entries); err != nil {
+		return err
+	}
+
+	fs.loaded = true
+
+	return nil
+}
+
+// Writes to a temporary file that is synced and renamed, a crash never leaves a partially written file behind.
+func (fs *fileStorage) write(name string, v interface{}) error {
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(fs.dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(fs.dir, name)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Decodes the given file into v, leaves v untouched if the file does not exist.
+func (fs *fileStorage) read(name string, v interface{}) error {
+	b, err := ioutil.ReadFile(filepath.Join(fs.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// Returns the entries up to and including index followed by the given entries.
+func replaceEntries(saved []Entry, index uint64, entries []Entry) []Entry {
+	var ret []Entry
+
+	for _, e := range saved {
+		if e.Index <= index {
+			ret = append(ret, e)
+		}
+	}
+
+	return append(ret, entries...)
+}
+
+// Returns the entries following index.
+func discardEntries(saved []Entry, index uint64) []Entry {
+	var ret []Entry
+
+	for _, e := range saved {
+		if e.Index > index {
+			ret = append(ret, e)
+		}
+	}
+
+	return ret
+}
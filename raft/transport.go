@@ -0,0 +1,210 @@
+package raft
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/joonnna/ifrit"
+	"github.com/joonnna/ifrit/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errNoSender  = errors.New("Call carries no sender")
+	errInvalidId = errors.New("Call carries an invalid member id")
+)
+
+type VoteRequest struct {
+	Term         uint64
+	Candidate    string
+	LastLogIndex uint64
+	LastLogTerm  uint64
+}
+
+type VoteResponse struct {
+	Term    uint64
+	Granted bool
+}
+
+type AppendRequest struct {
+	Term         uint64
+	Leader       string
+	PrevLogIndex uint64
+	PrevLogTerm  uint64
+	Entries      []Entry
+	LeaderCommit uint64
+}
+
+type AppendResponse struct {
+	Term    uint64
+	Success bool
+
+	// Index of the last entry held by the follower, lets the leader skip back on conflicts.
+	LastIndex uint64
+}
+
+type SnapshotRequest struct {
+	Term      uint64
+	Leader    string
+	LastIndex uint64
+	LastTerm  uint64
+	Data      []byte
+}
+
+type SnapshotResponse struct {
+	Term uint64
+}
+
+// Transport carries calls between the members of a group, members are identified by their Ifrit id.
+type Transport interface {
+	RequestVote(ctx context.Context, dest string, req *VoteRequest) (*VoteResponse, error)
+	AppendEntries(ctx context.Context, dest string, req *AppendRequest) (*AppendResponse, error)
+	InstallSnapshot(ctx context.Context, dest string, req *SnapshotRequest) (*SnapshotResponse, error)
+
+	// Registers the handler of incoming calls.
+	Serve(h Handler) error
+}
+
+// Handler serves incoming calls, sender is the authenticated Ifrit id of the calling member.
+type Handler interface {
+	HandleVote(sender string, req *VoteRequest) (*VoteResponse, error)
+	HandleAppend(sender string, req *AppendRequest) (*AppendResponse, error)
+	HandleSnapshot(sender string, req *SnapshotRequest) (*SnapshotResponse, error)
+}
+
+// TransportClient is the part of the Ifrit client used by the transport, satisfied by *ifrit.Client.
+type TransportClient interface {
+	Member(id string) (*ifrit.Member, error)
+	SendToTopic(ctx context.Context, dest, topic string, data []byte) ([]byte, error)
+}
+
+type ifritTransport struct {
+	c     TransportClient
+	s     *rpc.Server
+	rc    *rpc.Client
+	group string
+}
+
+// Creates a transport exchanging calls as rpc methods prefixed with the group name.
+// Senders are identified by the certificate of their connection, members can therefore
+// not impersonate each other. Several groups can share the same server.
+// Ifrit ids are binary, they are hex encoded on the wire as the JSON codec only carries valid UTF-8.
+func NewTransport(c TransportClient, s *rpc.Server, group string) Transport {
+	return &ifritTransport{
+		c:     c,
+		s:     s,
+		rc:    rpc.NewClient(c, rpc.JSON, time.Second*10),
+		group: group,
+	}
+}
+
+func (t *ifritTransport) RequestVote(ctx context.Context, dest string, req *VoteRequest) (*VoteResponse, error) {
+	addr, err := t.addr(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	wire := *req
+	wire.Candidate = hex.EncodeToString([]byte(req.Candidate))
+
+	return rpc.Call[VoteRequest, VoteResponse](ctx, t.rc, addr, t.method("vote"), &wire)
+}
+
+func (t *ifritTransport) AppendEntries(ctx context.Context, dest string, req *AppendRequest) (*AppendResponse, error) {
+	addr, err := t.addr(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	wire := *req
+	wire.Leader = hex.EncodeToString([]byte(req.Leader))
+
+	return rpc.Call[AppendRequest, AppendResponse](ctx, t.rc, addr, t.method("append"), &wire)
+}
+
+func (t *ifritTransport) InstallSnapshot(ctx context.Context, dest string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	addr, err := t.addr(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	wire := *req
+	wire.Leader = hex.EncodeToString([]byte(req.Leader))
+
+	return rpc.Call[SnapshotRequest, SnapshotResponse](ctx, t.rc, addr, t.method("snapshot"), &wire)
+}
+
+func (t *ifritTransport) Serve(h Handler) error {
+	err := rpc.Register(t.s, t.method("vote"), rpc.JSON, func(ctx context.Context, req *VoteRequest) (*VoteResponse, error) {
+		sender, err := senderId(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if req.Candidate, err = decodeId(req.Candidate); err != nil {
+			return nil, err
+		}
+		return h.HandleVote(sender, req)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = rpc.Register(t.s, t.method("append"), rpc.JSON, func(ctx context.Context, req *AppendRequest) (*AppendResponse, error) {
+		sender, err := senderId(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if req.Leader, err = decodeId(req.Leader); err != nil {
+			return nil, err
+		}
+		return h.HandleAppend(sender, req)
+	})
+	if err != nil {
+		return err
+	}
+
+	return rpc.Register(t.s, t.method("snapshot"), rpc.JSON, func(ctx context.Context, req *SnapshotRequest) (*SnapshotResponse, error) {
+		sender, err := senderId(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if req.Leader, err = decodeId(req.Leader); err != nil {
+			return nil, err
+		}
+		return h.HandleSnapshot(sender, req)
+	})
+}
+
+func (t *ifritTransport) method(name string) string {
+	return "raft." + t.group + "." + name
+}
+
+func (t *ifritTransport) addr(id string) (string, error) {
+	m, err := t.c.Member(id)
+	if err != nil {
+		return "", status.Error(codes.NotFound, err.Error())
+	}
+
+	return m.Addr, nil
+}
+
+func senderId(ctx context.Context) (string, error) {
+	r, ok := rpc.FromContext(ctx)
+	if !ok || r.SenderId == "" {
+		return "", status.Error(codes.Unauthenticated, errNoSender.Error())
+	}
+
+	return r.SenderId, nil
+}
+
+func decodeId(encoded string) (string, error) {
+	id, err := hex.DecodeString(encoded)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, errInvalidId.Error())
+	}
+
+	return string(id), nil
+}
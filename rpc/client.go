@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/joonnna/ifrit"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sender is the part of the Ifrit client used to issue calls, satisfied by *ifrit.Client.
+type Sender interface {
+	SendToTopic(ctx context.Context, dest, topic string, data []byte) ([]byte, error)
+}
+
+// Client issues calls to methods registered on the servers of other members.
+type Client struct {
+	s     Sender
+	codec Codec
+
+	// Applied to calls whose context has no deadline, zero disables it.
+	timeout time.Duration
+}
+
+// Creates a client marshaling requests and responses with codec.
+// Calls without a deadline are cancelled after timeout, unless timeout is zero.
+func NewClient(s Sender, codec Codec, timeout time.Duration) *Client {
+	return &Client{
+		s:       s,
+		codec:   codec,
+		timeout: timeout,
+	}
+}
+
+// Calls method on the member with the given address and returns its response.
+// All errors are statuses, status.Code(err) returns the code set by the remote handler,
+// codes.DeadlineExceeded or codes.Canceled if the context expired, codes.Unimplemented if
+// the destination does not serve the method and codes.Unavailable if it could not be reached.
+func Call[Req, Resp any](ctx context.Context, c *Client, dest, method string, req *Req) (*Resp, error) {
+	payload, err := c.codec.Marshal(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	out, err := c.invoke(ctx, dest, method, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(Resp)
+
+	if err := c.codec.Unmarshal(out, resp); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
+}
+
+func (c *Client) invoke(ctx context.Context, dest, method string, payload []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req := &pb.RpcRequest{
+		Method:  method,
+		Codec:   c.codec.Name(),
+		Payload: payload,
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	reply, err := c.s.SendToTopic(ctx, dest, Topic, data)
+	if err != nil {
+		return nil, transportStatus(err)
+	}
+
+	resp := &pb.RpcResponse{}
+
+	if err := proto.Unmarshal(reply, resp); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if code := codes.Code(resp.GetCode()); code != codes.OK {
+		return nil, status.Error(code, resp.GetMessage())
+	}
+
+	return resp.GetPayload(), nil
+}
+
+// Converts errors returned by the Ifrit client into statuses.
+func transportStatus(err error) error {
+	switch {
+	case errors.Is(err, ifrit.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, ifrit.ErrUnknownTopic):
+		return status.Error(codes.Unimplemented, "Destination serves no rpc methods")
+	case errors.Is(err, ifrit.ErrUnknownPeer):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
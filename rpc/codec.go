@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	errNotProtoMessage = errors.New("Value does not implement proto.Message")
+)
+
+// Codec marshals the requests and responses of a method.
+// Callers and servers must use the same codec for a method.
+type Codec interface {
+	// Identifies the codec on the wire.
+	Name() string
+
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// Marshals values implementing proto.Message.
+	Proto Codec = protoCodec{}
+
+	// Marshals values through encoding/json.
+	JSON Codec = jsonCodec{}
+)
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string {
+	return "proto"
+}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errNotProtoMessage
+	}
+
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errNotProtoMessage
+	}
+
+	return proto.Unmarshal(data, m)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
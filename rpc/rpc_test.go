@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joonnna/ifrit"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// In-memory transport delivering calls directly to the registered handler.
+type testTransport struct {
+	id      string
+	addr    string
+	handler func(*ifrit.Request) ([]byte, error)
+}
+
+func (t *testTransport) RegisterMsgHandlerFor(topic string, handler func(*ifrit.Request) ([]byte, error)) error {
+	t.handler = handler
+	return nil
+}
+
+func (t *testTransport) SendToTopic(ctx context.Context, dest, topic string, data []byte) ([]byte, error) {
+	type reply struct {
+		data []byte
+		err  error
+	}
+
+	if dest != t.addr {
+		return nil, ifrit.ErrTransport
+	}
+
+	if t.handler == nil {
+		return nil, ifrit.ErrUnknownTopic
+	}
+
+	ch := make(chan *reply, 1)
+
+	go func() {
+		data, err := t.handler(&ifrit.Request{
+			Context:    ctx,
+			SenderId:   t.id,
+			SenderAddr: t.addr,
+			Data:       data,
+		})
+		ch <- &reply{data: data, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ifrit.ErrDeadlineExceeded
+	}
+}
+
+type echo struct {
+	Text string
+}
+
+type RpcTestSuite struct {
+	suite.Suite
+
+	t *testTransport
+	s *Server
+}
+
+func TestRpcTestSuite(t *testing.T) {
+	suite.Run(t, new(RpcTestSuite))
+}
+
+func (suite *RpcTestSuite) SetupTest() {
+	suite.t = &testTransport{
+		id:   "id",
+		addr: "addr",
+	}
+
+	s, err := NewServer(suite.t)
+	require.NoError(suite.T(), err, "Failed to create server.")
+
+	suite.s = s
+}
+
+func (suite *RpcTestSuite) TestRegister() {
+	handler := func(ctx context.Context, req *echo) (*echo, error) {
+		return req, nil
+	}
+
+	require.Equal(suite.T(), errNoMethod, Register(suite.s, "", JSON, handler), "Should reject empty name.")
+	require.Equal(suite.T(), errNoCodec, Register(suite.s, "echo", nil, handler), "Should reject nil codec.")
+	require.Equal(suite.T(), errNoHandler, Register[echo, echo](suite.s, "echo", JSON, nil), "Should reject nil handler.")
+
+	require.NoError(suite.T(), Register(suite.s, "echo", JSON, handler), "Register failed.")
+	require.Equal(suite.T(), errMethodExists, Register(suite.s, "echo", JSON, handler), "Should reject duplicate method.")
+
+	suite.s.Unregister("echo")
+	require.NoError(suite.T(), Register(suite.s, "echo", JSON, handler), "Should register after unregister.")
+}
+
+func (suite *RpcTestSuite) TestProtoCall() {
+	handler := func(ctx context.Context, req *pb.Ping) (*pb.Ping, error) {
+		return &pb.Ping{Nonce: append(req.GetNonce(), 1)}, nil
+	}
+
+	require.NoError(suite.T(), Register(suite.s, "ping", Proto, handler), "Register failed.")
+
+	c := NewClient(suite.t, Proto, time.Second)
+
+	resp, err := Call[pb.Ping, pb.Ping](context.Background(), c, "addr", "ping", &pb.Ping{Nonce: []byte{0}})
+	require.NoError(suite.T(), err, "Call failed.")
+	require.Equal(suite.T(), []byte{0, 1}, resp.GetNonce(), "Wrong response.")
+}
+
+func (suite *RpcTestSuite) TestJSONCall() {
+	handler := func(ctx context.Context, req *echo) (*echo, error) {
+		r, ok := FromContext(ctx)
+		require.True(suite.T(), ok, "Request not set on context.")
+
+		return &echo{Text: req.Text + " from " + r.SenderId}, nil
+	}
+
+	require.NoError(suite.T(), Register(suite.s, "echo", JSON, handler), "Register failed.")
+
+	c := NewClient(suite.t, JSON, time.Second)
+
+	resp, err := Call[echo, echo](context.Background(), c, "addr", "echo", &echo{Text: "hello"})
+	require.NoError(suite.T(), err, "Call failed.")
+	require.Equal(suite.T(), "hello from id", resp.Text, "Wrong response.")
+
+	_, ok := FromContext(context.Background())
+	require.False(suite.T(), ok, "Request should only be set on handler contexts.")
+}
+
+func (suite *RpcTestSuite) TestErrors() {
+	notFound := func(ctx context.Context, req *echo) (*echo, error) {
+		return nil, status.Error(codes.NotFound, "No such thing")
+	}
+
+	plain := func(ctx context.Context, req *echo) (*echo, error) {
+		return nil, errors.New("Something broke")
+	}
+
+	require.NoError(suite.T(), Register(suite.s, "notFound", JSON, notFound), "Register failed.")
+	require.NoError(suite.T(), Register(suite.s, "plain", JSON, plain), "Register failed.")
+
+	c := NewClient(suite.t, JSON, time.Second)
+	ctx := context.Background()
+
+	_, err := Call[echo, echo](ctx, c, "addr", "notFound", &echo{})
+	require.Equal(suite.T(), codes.NotFound, status.Code(err), "Should keep handler status code.")
+	require.Equal(suite.T(), "No such thing", status.Convert(err).Message(), "Should keep handler message.")
+
+	_, err = Call[echo, echo](ctx, c, "addr", "plain", &echo{})
+	require.Equal(suite.T(), codes.Unknown, status.Code(err), "Plain errors should be unknown.")
+
+	_, err = Call[echo, echo](ctx, c, "addr", "missing", &echo{})
+	require.Equal(suite.T(), codes.Unimplemented, status.Code(err), "Unknown method should be unimplemented.")
+
+	_, err = Call[echo, echo](ctx, c, "unreachable", "plain", &echo{})
+	require.Equal(suite.T(), codes.Unavailable, status.Code(err), "Unreachable destination should be unavailable.")
+
+	protoClient := NewClient(suite.t, Proto, time.Second)
+
+	_, err = Call[pb.Ping, pb.Ping](ctx, protoClient, "addr", "plain", &pb.Ping{})
+	require.Equal(suite.T(), codes.InvalidArgument, status.Code(err), "Codec mismatch should be invalid.")
+
+	_, err = Call[echo, echo](ctx, protoClient, "addr", "plain", &echo{})
+	require.Equal(suite.T(), codes.InvalidArgument, status.Code(err), "Non-proto request should be invalid.")
+}
+
+func (suite *RpcTestSuite) TestDeadline() {
+	block := make(chan struct{})
+	defer close(block)
+
+	handler := func(ctx context.Context, req *echo) (*echo, error) {
+		_, ok := ctx.Deadline()
+		require.True(suite.T(), ok, "Handler context should carry the deadline.")
+
+		<-block
+		return req, nil
+	}
+
+	require.NoError(suite.T(), Register(suite.s, "block", JSON, handler), "Register failed.")
+
+	c := NewClient(suite.t, JSON, time.Millisecond*50)
+
+	_, err := Call[echo, echo](context.Background(), c, "addr", "block", &echo{})
+	require.Equal(suite.T(), codes.DeadlineExceeded, status.Code(err), "Default timeout should apply.")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err = Call[echo, echo](ctx, NewClient(suite.t, JSON, 0), "addr", "block", &echo{})
+	require.Equal(suite.T(), codes.DeadlineExceeded, status.Code(err), "Call deadline should apply.")
+
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+
+	_, err = suite.s.dispatch(&ifrit.Request{Context: expired}, &pb.RpcRequest{Method: "block", Codec: "json"})
+	require.Equal(suite.T(), codes.Canceled, status.Code(err), "Expired calls should not be dispatched.")
+}
@@ -0,0 +1,180 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Topic on which calls are exchanged.
+	Topic = "rpc"
+)
+
+var (
+	errNoMethod     = errors.New("Method name is empty")
+	errNoHandler    = errors.New("Method handler is nil")
+	errNoCodec      = errors.New("Codec is nil")
+	errMethodExists = errors.New("Method already registered")
+)
+
+// Registrar is the part of the Ifrit client used to serve calls, satisfied by *ifrit.Client.
+type Registrar interface {
+	RegisterMsgHandlerFor(topic string, handler func(*ifrit.Request) ([]byte, error)) error
+}
+
+// Server dispatches incoming calls to the methods registered on it.
+type Server struct {
+	methods map[string]*method
+	mutex   sync.RWMutex
+}
+
+type method struct {
+	codec  Codec
+	handle func(context.Context, []byte) ([]byte, error)
+}
+
+type requestKey struct{}
+
+// Creates a server and registers it as the handler of the rpc topic.
+func NewServer(r Registrar) (*Server, error) {
+	s := &Server{
+		methods: make(map[string]*method),
+	}
+
+	if err := r.RegisterMsgHandlerFor(Topic, s.handleMsg); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Registers handler as the method with the given name, its request and response are marshaled with codec.
+// The handler's error is returned to the caller as a status, errors created with status.Error
+// keep their code while all other errors are returned with codes.Unknown.
+// The context carries the deadline of the caller, the incoming request is available through FromContext.
+func Register[Req, Resp any](s *Server, name string, codec Codec, handler func(context.Context, *Req) (*Resp, error)) error {
+	if name == "" {
+		return errNoMethod
+	}
+
+	if handler == nil {
+		return errNoHandler
+	}
+
+	if codec == nil {
+		return errNoCodec
+	}
+
+	m := &method{
+		codec: codec,
+		handle: func(ctx context.Context, data []byte) ([]byte, error) {
+			req := new(Req)
+
+			if err := codec.Unmarshal(data, req); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+
+			resp, err := handler(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			out, err := codec.Marshal(resp)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			return out, nil
+		},
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.methods[name]; exists {
+		return errMethodExists
+	}
+
+	s.methods[name] = m
+
+	return nil
+}
+
+// Removes the method with the given name, subsequent calls fail with codes.Unimplemented.
+func (s *Server) Unregister(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.methods, name)
+}
+
+// Returns the incoming request of a call, only set on contexts passed to method handlers.
+func FromContext(ctx context.Context) (*ifrit.Request, bool) {
+	r, ok := ctx.Value(requestKey{}).(*ifrit.Request)
+	return r, ok
+}
+
+func (s *Server) handleMsg(r *ifrit.Request) ([]byte, error) {
+	req := &pb.RpcRequest{}
+
+	if err := proto.Unmarshal(r.Data, req); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.RpcResponse{}
+
+	payload, err := s.dispatch(r, req)
+	if err != nil {
+		st := status.Convert(err)
+
+		resp.Code = uint32(st.Code())
+		resp.Message = st.Message()
+	} else {
+		resp.Payload = payload
+	}
+
+	return proto.Marshal(resp)
+}
+
+func (s *Server) dispatch(r *ifrit.Request, req *pb.RpcRequest) ([]byte, error) {
+	s.mutex.RLock()
+	m, ok := s.methods[req.GetMethod()]
+	s.mutex.RUnlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Unknown method %s", req.GetMethod())
+	}
+
+	if name := m.codec.Name(); name != req.GetCodec() {
+		return nil, status.Errorf(codes.InvalidArgument, "Method %s expects codec %s", req.GetMethod(), name)
+	}
+
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// The caller gave up before the call was dispatched.
+	if err := ctx.Err(); err != nil {
+		log.Debug(err.Error(), "method", req.GetMethod())
+		return nil, contextStatus(err)
+	}
+
+	return m.handle(context.WithValue(ctx, requestKey{}, r), req.GetPayload())
+}
+
+func contextStatus(err error) error {
+	if err == context.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return status.Error(codes.Canceled, err.Error())
+}
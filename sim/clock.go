@@ -0,0 +1,79 @@
+package sim
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a virtual clock which only moves when advanced, it implements discovery.Clock.
+type Clock struct {
+	now     time.Time
+	waiters []*waiter
+
+	mutex sync.Mutex
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// Creates a virtual clock starting at the given time.
+func NewClock(start time.Time) *Clock {
+	return &Clock{
+		now: start,
+	}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Returns a channel receiving the time once the clock is advanced by at least d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ch := make(chan time.Time, 1)
+
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, &waiter{at: c.now.Add(d), ch: ch})
+
+	return ch
+}
+
+// Moves the clock forward to t and fires all channels due by then, earlier times are ignored.
+func (c *Clock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if t.Before(c.now) {
+		return
+	}
+
+	c.now = t
+
+	remaining := c.waiters[:0]
+
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			remaining = append(remaining, w)
+		} else {
+			w.ch <- t
+		}
+	}
+
+	c.waiters = remaining
+}
+
+// Moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
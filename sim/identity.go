@@ -0,0 +1,140 @@
+package sim
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+)
+
+// Certificate authority issuing the certificates of all simulated nodes.
+type authority struct {
+	cert *x509.Certificate
+	priv *ecdsa.PrivateKey
+
+	serial int64
+}
+
+// Key material and certificates of a single node, implements the certificate manager
+// and crypto service of core.
+type identity struct {
+	cert     *x509.Certificate
+	ca       *x509.Certificate
+	contacts []*x509.Certificate
+	priv     *ecdsa.PrivateKey
+	numRings uint32
+}
+
+func newAuthority(now time.Time) (*authority, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sim"},
+		NotBefore:             now.AddDate(-1, 0, 0),
+		NotAfter:              now.AddDate(10, 0, 0),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authority{
+		cert:   cert,
+		priv:   priv,
+		serial: 1,
+	}, nil
+}
+
+// Issues a certificate for the given id and addresses, the id determines ring placement.
+func (a *authority) issue(id []byte, addr, pingAddr string, now time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.serial++
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(a.serial),
+		SubjectKeyId: id,
+		Subject:      pkix.Name{Locality: []string{addr, pingAddr}},
+		NotBefore:    now.AddDate(-1, 0, 0),
+		NotAfter:     now.AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, a.cert, priv.Public(), a.priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, priv, nil
+}
+
+func (i *identity) Certificate() *x509.Certificate {
+	return i.cert
+}
+
+func (i *identity) CaCertificate() *x509.Certificate {
+	return i.ca
+}
+
+func (i *identity) ContactList() []*x509.Certificate {
+	return i.contacts
+}
+
+func (i *identity) NumRings() uint32 {
+	return i.numRings
+}
+
+func (i *identity) Trusted() bool {
+	return true
+}
+
+func (i *identity) Sign(data []byte) ([]byte, []byte, error) {
+	hash := sha256.Sum256(data)
+
+	r, s, err := ecdsa.Sign(rand.Reader, i.priv, hash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.Bytes(), s.Bytes(), nil
+}
+
+func (i *identity) Verify(data, r, s []byte, pub *ecdsa.PublicKey) bool {
+	var rInt, sInt big.Int
+
+	if pub == nil {
+		return false
+	}
+
+	hash := sha256.Sum256(data)
+
+	rInt.SetBytes(r)
+	sInt.SetBytes(s)
+
+	return ecdsa.Verify(pub, hash[:], &rInt, &sInt)
+}
@@ -0,0 +1,487 @@
+package sim
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/joonnna/ifrit/core"
+	"github.com/joonnna/ifrit/fault"
+)
+
+var (
+	errUnknownAddr = errors.New("No simulated node has the given address")
+	errCrashed     = errors.New("Node has crashed")
+	errPaused      = errors.New("Node does not reply to pings")
+)
+
+// Config holds the parameters of a simulated network.
+type Config struct {
+	// Seeds node ids, contact lists and the schedule, equal seeds yield equal runs.
+	Seed int64
+
+	NumRings uint32
+
+	// Number of existing nodes in the contact list of each new node.
+	Contacts int
+
+	GossipInterval     time.Duration
+	MonitorInterval    time.Duration
+	ViewUpdateInterval time.Duration
+	RemovalTimeout     time.Duration
+
+	PingLimit        uint32
+	PingsPerInterval int
+	IndirectProbes   int
+	PhiThreshold     float64
+
+	// Drops calls and pings between nodes, nodes are registered under their name.
+	// Calls are delivered synchronously, latency is therefore not applied.
+	Faults *fault.Injector
+}
+
+// Node is a core node driven by the network's scheduler instead of its own loops.
+type Node struct {
+	*core.Node
+
+	name     string
+	addr     string
+	pingAddr string
+
+	id *identity
+	t  *transport
+
+	crashed     bool
+	pausedUntil time.Time
+}
+
+// Network runs simulated nodes in a single process on a virtual clock.
+// Nodes communicate through in-memory transports and are stepped one at a time
+// in an order determined by the seed, no real sockets or goroutines are involved.
+type Network struct {
+	conf  *Config
+	rand  *rand.Rand
+	clock *Clock
+	ca    *authority
+
+	nodes     []*Node
+	addrs     map[string]*Node
+	pingAddrs map[string]*Node
+
+	queue eventQueue
+	seq   uint64
+
+	mutex sync.RWMutex
+}
+
+type eventKind int
+
+const (
+	gossipEvent eventKind = iota
+	monitorEvent
+	timeoutEvent
+)
+
+type event struct {
+	at   time.Time
+	seq  uint64
+	kind eventKind
+	node *Node
+}
+
+// Events ordered by time, ties are broken by scheduling order.
+type eventQueue []*event
+
+// Returns the default simulation parameters, intervals and limits equal the defaults of Ifrit clients.
+func DefaultConfig() *Config {
+	return &Config{
+		Seed:               1,
+		NumRings:           5,
+		Contacts:           3,
+		GossipInterval:     time.Second * 10,
+		MonitorInterval:    time.Second * 10,
+		ViewUpdateInterval: time.Second * 10,
+		RemovalTimeout:     time.Second * 60,
+		PingLimit:          3,
+		PingsPerInterval:   3,
+		IndirectProbes:     3,
+		PhiThreshold:       8,
+	}
+}
+
+// Creates an empty network, the virtual clock starts at a fixed time.
+func NewNetwork(conf *Config) (*Network, error) {
+	clock := NewClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	ca, err := newAuthority(clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Faults != nil {
+		conf.Faults.SetClock(clock)
+	}
+
+	return &Network{
+		conf:      conf,
+		rand:      rand.New(rand.NewSource(conf.Seed)),
+		clock:     clock,
+		ca:        ca,
+		addrs:     make(map[string]*Node),
+		pingAddrs: make(map[string]*Node),
+	}, nil
+}
+
+// Returns the virtual clock of the network.
+func (nw *Network) Clock() *Clock {
+	return nw.clock
+}
+
+// Returns the current virtual time.
+func (nw *Network) Now() time.Time {
+	return nw.clock.Now()
+}
+
+// Creates a node with a contact list drawn from the existing nodes and schedules its rounds.
+func (nw *Network) AddNode() (*Node, error) {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	idx := len(nw.nodes)
+
+	n := &Node{
+		name:     fmt.Sprintf("sim-%d", idx),
+		addr:     fmt.Sprintf("sim-%d:8000", idx),
+		pingAddr: fmt.Sprintf("sim-%d:8001", idx),
+	}
+
+	id := make([]byte, 32)
+	nw.rand.Read(id)
+
+	cert, priv, err := nw.ca.issue(id, n.addr, n.pingAddr, nw.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	n.id = &identity{
+		cert:     cert,
+		ca:       nw.ca.cert,
+		priv:     priv,
+		numRings: nw.conf.NumRings,
+	}
+
+	for _, i := range nw.rand.Perm(idx) {
+		if len(n.id.contacts) >= nw.conf.Contacts {
+			break
+		}
+		n.id.contacts = append(n.id.contacts, nw.nodes[i].id.cert)
+	}
+
+	n.t = &transport{nw: nw, node: n}
+
+	conf := &core.Config{
+		GossipInterval:        nw.conf.GossipInterval,
+		MonitorInterval:       nw.conf.MonitorInterval,
+		ViewUpdateInterval:    nw.conf.ViewUpdateInterval,
+		RemovalTimeout:        nw.conf.RemovalTimeout,
+		PingLimit:             nw.conf.PingLimit,
+		PingsPerInterval:      nw.conf.PingsPerInterval,
+		IndirectProbes:        nw.conf.IndirectProbes,
+		PhiThreshold:          nw.conf.PhiThreshold,
+		MaxConcurrentMessages: 5,
+		Clock:                 nw.clock,
+	}
+
+	cn, err := core.NewNode(n.t, &pinger{nw: nw, node: n}, n.id, n.id, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	n.Node = cn
+
+	nw.nodes = append(nw.nodes, n)
+	nw.addrs[n.addr] = n
+	nw.pingAddrs[n.pingAddr] = n
+
+	if nw.conf.Faults != nil {
+		nw.conf.Faults.AddNode(n.name, n.addr, n.pingAddr)
+	}
+
+	// Random offsets keep the rounds of different nodes apart.
+	now := nw.clock.Now()
+
+	nw.schedule(n, gossipEvent, now.Add(nw.offset(nw.conf.GossipInterval)))
+	nw.schedule(n, monitorEvent, now.Add(nw.offset(nw.conf.MonitorInterval)))
+	nw.schedule(n, timeoutEvent, now.Add(nw.offset(nw.conf.ViewUpdateInterval)))
+
+	return n, nil
+}
+
+// Returns the name of the node, unique within its network.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Returns all nodes in the order they were added, including crashed nodes.
+func (nw *Network) Nodes() []*Node {
+	nw.mutex.RLock()
+	defer nw.mutex.RUnlock()
+
+	ret := make([]*Node, len(nw.nodes))
+	copy(ret, nw.nodes)
+
+	return ret
+}
+
+// Returns all nodes that have not crashed.
+func (nw *Network) Running() []*Node {
+	nw.mutex.RLock()
+	defer nw.mutex.RUnlock()
+
+	ret := make([]*Node, 0, len(nw.nodes))
+
+	for _, n := range nw.nodes {
+		if !n.crashed {
+			ret = append(ret, n)
+		}
+	}
+
+	return ret
+}
+
+// Stops the node permanently, it no longer runs rounds or replies to calls and pings.
+func (nw *Network) Crash(n *Node) {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	n.crashed = true
+}
+
+// Lets the node ignore pings for d of virtual time while it keeps gossiping,
+// which gets it accused and forces it to rebut.
+func (nw *Network) PausePings(n *Node, d time.Duration) {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	n.pausedUntil = nw.clock.Now().Add(d)
+}
+
+// Runs the next scheduled round and advances the clock to its time.
+// Returns false if no rounds are left.
+func (nw *Network) Step() bool {
+	nw.mutex.Lock()
+
+	if len(nw.queue) == 0 {
+		nw.mutex.Unlock()
+		return false
+	}
+
+	e := heap.Pop(&nw.queue).(*event)
+	crashed := e.node.crashed
+
+	if !crashed {
+		nw.schedule(e.node, e.kind, e.at.Add(nw.interval(e.kind)))
+	}
+
+	nw.mutex.Unlock()
+
+	nw.clock.Set(e.at)
+
+	if crashed {
+		return true
+	}
+
+	switch e.kind {
+	case gossipEvent:
+		e.node.GossipRound()
+	case monitorEvent:
+		e.node.MonitorRound()
+	case timeoutEvent:
+		e.node.CheckTimeouts()
+	}
+
+	return true
+}
+
+// Runs all rounds scheduled within d of virtual time.
+func (nw *Network) RunFor(d time.Duration) {
+	end := nw.clock.Now().Add(d)
+
+	for nw.next().Before(end) || nw.next().Equal(end) {
+		if !nw.Step() {
+			break
+		}
+	}
+
+	nw.clock.Set(end)
+}
+
+// Runs rounds until cond returns true or max of virtual time has passed,
+// returns whether cond was satisfied.
+func (nw *Network) RunUntil(cond func() bool, max time.Duration) bool {
+	end := nw.clock.Now().Add(max)
+
+	for !cond() {
+		if next := nw.next(); next.IsZero() || next.After(end) {
+			nw.clock.Set(end)
+			return false
+		}
+
+		nw.Step()
+	}
+
+	return true
+}
+
+// Returns true if the live view of every running node contains exactly the other running nodes.
+func (nw *Network) Converged() bool {
+	running := nw.Running()
+
+	addrs := make(map[string]bool, len(running))
+
+	for _, n := range running {
+		addrs[n.addr] = true
+	}
+
+	for _, n := range running {
+		live := n.LiveMembers()
+		if len(live) != len(running)-1 {
+			return false
+		}
+
+		for _, addr := range live {
+			if !addrs[addr] || addr == n.addr {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Time of the next scheduled round, zero if none are left.
+func (nw *Network) next() time.Time {
+	nw.mutex.RLock()
+	defer nw.mutex.RUnlock()
+
+	if len(nw.queue) == 0 {
+		return time.Time{}
+	}
+
+	return nw.queue[0].at
+}
+
+// Returns the destination of a call if it is reachable.
+func (nw *Network) reach(src *Node, addr string) (*Node, error) {
+	nw.mutex.RLock()
+	defer nw.mutex.RUnlock()
+
+	dest, ok := nw.addrs[addr]
+	if !ok {
+		return nil, errUnknownAddr
+	}
+
+	if dest.crashed || src.crashed {
+		return nil, errCrashed
+	}
+
+	if err := nw.checkFaults(src, dest); err != nil {
+		return nil, err
+	}
+
+	return dest, nil
+}
+
+// Returns the destination of a ping if it is reachable and replies to pings.
+func (nw *Network) reachPing(src *Node, addr string) (*Node, error) {
+	nw.mutex.RLock()
+	defer nw.mutex.RUnlock()
+
+	dest, ok := nw.pingAddrs[addr]
+	if !ok {
+		return nil, errUnknownAddr
+	}
+
+	if dest.crashed || src.crashed {
+		return nil, errCrashed
+	}
+
+	if nw.clock.Now().Before(dest.pausedUntil) {
+		return nil, errPaused
+	}
+
+	if err := nw.checkFaults(src, dest); err != nil {
+		return nil, err
+	}
+
+	return dest, nil
+}
+
+func (nw *Network) checkFaults(src, dest *Node) error {
+	if nw.conf.Faults == nil {
+		return nil
+	}
+
+	_, err := nw.conf.Faults.Check(src.name, dest.name)
+
+	return err
+}
+
+// Must hold the network lock.
+func (nw *Network) schedule(n *Node, kind eventKind, at time.Time) {
+	nw.seq++
+
+	heap.Push(&nw.queue, &event{
+		at:   at,
+		seq:  nw.seq,
+		kind: kind,
+		node: n,
+	})
+}
+
+func (nw *Network) interval(kind eventKind) time.Duration {
+	switch kind {
+	case gossipEvent:
+		return nw.conf.GossipInterval
+	case monitorEvent:
+		return nw.conf.MonitorInterval
+	default:
+		return nw.conf.ViewUpdateInterval
+	}
+}
+
+// Must hold the network lock.
+func (nw *Network) offset(interval time.Duration) time.Duration {
+	return time.Duration(nw.rand.Int63n(int64(interval))) + 1
+}
+
+func (q eventQueue) Len() int {
+	return len(q)
+}
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+
+	return q[i].at.Before(q[j].at)
+}
+
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *eventQueue) Push(x interface{}) {
+	*q = append(*q, x.(*event))
+}
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return e
+}
@@ -0,0 +1,335 @@
+package sim
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/joonnna/ifrit/core"
+	"github.com/joonnna/ifrit/core/discovery"
+	"github.com/joonnna/ifrit/fault"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SimTestSuite struct {
+	suite.Suite
+
+	nw *Network
+}
+
+func TestMain(m *testing.M) {
+	log.Root().SetHandler(log.DiscardHandler())
+
+	os.Exit(m.Run())
+}
+
+func TestSimTestSuite(t *testing.T) {
+	suite.Run(t, new(SimTestSuite))
+}
+
+func (suite *SimTestSuite) SetupTest() {
+	suite.nw = newTestNetwork(suite.T(), 1, 30)
+}
+
+func (suite *SimTestSuite) TestConvergence() {
+	require.True(suite.T(), suite.nw.RunUntil(suite.nw.Converged, time.Minute*30), "Network did not converge.")
+
+	// Nodes joining a converged network are observed by everyone.
+	for i := 0; i < 5; i++ {
+		_, err := suite.nw.AddNode()
+		require.NoError(suite.T(), err, "Failed to add node.")
+	}
+
+	require.True(suite.T(), suite.nw.RunUntil(suite.nw.Converged, time.Minute*30), "Joining nodes were not observed.")
+}
+
+func (suite *SimTestSuite) TestCrash() {
+	nw := suite.nw
+
+	require.True(suite.T(), nw.RunUntil(nw.Converged, time.Minute*30), "Network did not converge.")
+
+	nodes := nw.Nodes()
+	nw.Crash(nodes[3])
+	nw.Crash(nodes[7])
+
+	require.False(suite.T(), nw.Converged(), "Crashed nodes should still be live.")
+
+	start := nw.Now()
+
+	require.True(suite.T(), nw.RunUntil(nw.Converged, time.Minute*30), "Crashed nodes were not removed.")
+	require.True(suite.T(), nw.Now().Sub(start) > nw.conf.RemovalTimeout, "Crashed nodes removed before timeout expired.")
+	require.Len(suite.T(), nw.Running(), 28, "Invalid number of running nodes.")
+}
+
+func (suite *SimTestSuite) TestRebuttal() {
+	nw := suite.nw
+
+	require.True(suite.T(), nw.RunUntil(nw.Converged, time.Minute*30), "Network did not converge.")
+
+	n := nw.Nodes()[5]
+
+	events, cancel := n.Subscribe()
+	defer cancel()
+
+	// Paused shorter than the removal timeout, the node rebuts before it is removed.
+	nw.PausePings(n, nw.conf.MonitorInterval*time.Duration(nw.conf.PingLimit+1))
+
+	rebutted := func() bool {
+		for {
+			select {
+			case e := <-events:
+				if e.Type == discovery.NoteReissued {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}
+
+	require.True(suite.T(), nw.RunUntil(rebutted, time.Minute*10), "Node did not rebut accusation.")
+
+	nw.RunFor(nw.conf.RemovalTimeout * 2)
+
+	require.True(suite.T(), nw.RunUntil(nw.Converged, time.Minute*30), "Rebutted node should remain live.")
+	require.Len(suite.T(), nw.Running(), 30, "Invalid number of running nodes.")
+}
+
+func (suite *SimTestSuite) TestNoRebuttal() {
+	nw := suite.nw
+
+	require.True(suite.T(), nw.RunUntil(nw.Converged, time.Minute*30), "Network did not converge.")
+
+	n := nw.Nodes()[5]
+	n.SetBehavior(core.NoRebuttal())
+
+	nw.PausePings(n, nw.conf.MonitorInterval*time.Duration(nw.conf.PingLimit+1))
+
+	removed := func() bool {
+		for _, other := range nw.Nodes() {
+			if other != n && contains(other.LiveMembers(), n.Addr()) {
+				return false
+			}
+		}
+		return true
+	}
+
+	require.True(suite.T(), nw.RunUntil(removed, time.Minute*30), "Node refusing to rebut was not removed.")
+}
+
+func TestAdversaries(t *testing.T) {
+	flood, err := core.CertificateFlood(10)
+	require.NoError(t, err, "Failed to create certificate flood.")
+
+	_, err = core.CertificateFlood(0)
+	require.Error(t, err, "Empty flood should be rejected.")
+
+	adversaries := map[string]func() core.Behavior{
+		"AccusationSpam":    core.AccusationSpam,
+		"EquivocatingNotes": core.EquivocatingNotes,
+		"WithholdGossip":    func() core.Behavior { return core.WithholdGossip() },
+		"CertificateFlood":  func() core.Behavior { return flood },
+		"NoRebuttal":        core.NoRebuttal,
+	}
+
+	for name, adversary := range adversaries {
+		t.Run(name, func(t *testing.T) {
+			var honest []*Node
+
+			nw := newTestNetwork(t, 1, 20)
+
+			// A hostile minority misbehaves from the start.
+			for i, n := range nw.Nodes() {
+				if i%10 == 0 {
+					n.SetBehavior(adversary())
+				} else {
+					honest = append(honest, n)
+				}
+			}
+
+			converged := func() bool {
+				for _, n := range honest {
+					live := n.LiveMembers()
+					for _, other := range honest {
+						if other != n && !contains(live, other.Addr()) {
+							return false
+						}
+					}
+				}
+				return true
+			}
+
+			require.True(t, nw.RunUntil(converged, time.Minute*30), "Correct nodes did not converge.")
+
+			nw.RunFor(nw.conf.RemovalTimeout * 5)
+
+			require.True(t, converged(), "Correct nodes removed each other.")
+		})
+	}
+}
+
+func contains(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSplit(t *testing.T) {
+	var a, b []string
+
+	conf := DefaultConfig()
+	conf.Faults = fault.NewInjector(1)
+
+	nw, err := NewNetwork(conf)
+	require.NoError(t, err, "Failed to create network.")
+
+	half := make(map[string]int)
+
+	for i := 0; i < 20; i++ {
+		n, err := nw.AddNode()
+		require.NoError(t, err, "Failed to add node.")
+
+		if i%2 == 0 {
+			a = append(a, n.Name())
+		} else {
+			b = append(b, n.Name())
+		}
+
+		half[n.Addr()] = i % 2
+	}
+
+	require.True(t, nw.RunUntil(nw.Converged, time.Minute*30), "Network did not converge.")
+
+	conf.Faults.Split(a, b)
+
+	// Each half removes the other from its live view.
+	split := func() bool {
+		for _, n := range nw.Nodes() {
+			if len(n.LiveMembers()) != len(a)-1 {
+				return false
+			}
+		}
+		return true
+	}
+
+	require.True(t, nw.RunUntil(split, time.Minute*30), "Halves did not remove each other.")
+
+	for _, n := range nw.Nodes() {
+		for _, addr := range n.LiveMembers() {
+			require.Equal(t, half[n.Addr()], half[addr], "Live view contains node of other half.")
+		}
+	}
+}
+
+func TestIndirectProbes(t *testing.T) {
+	accusations := func(probes int) int {
+		var from, to []string
+		var events []<-chan discovery.Event
+
+		conf := DefaultConfig()
+		conf.IndirectProbes = probes
+		conf.Faults = fault.NewInjector(1)
+
+		nw, err := NewNetwork(conf)
+		require.NoError(t, err, "Failed to create network.")
+
+		for i := 0; i < 30; i++ {
+			_, err := nw.AddNode()
+			require.NoError(t, err, "Failed to add node.")
+		}
+
+		require.True(t, nw.RunUntil(nw.Converged, time.Minute*30), "Network did not converge.")
+
+		for i, n := range nw.Nodes()[:10] {
+			if i < 5 {
+				ch, cancel := n.Subscribe()
+				defer cancel()
+
+				events = append(events, ch)
+				from = append(from, n.Name())
+			} else {
+				to = append(to, n.Name())
+			}
+		}
+
+		// Links between healthy nodes fail, all other nodes reach both ends.
+		conf.Faults.Partition(from, to)
+
+		nw.RunFor(time.Minute * 10)
+
+		count := 0
+
+		for _, ch := range events {
+			for len(ch) > 0 {
+				if e := <-ch; e.Type == discovery.PeerAccused {
+					count++
+				}
+			}
+		}
+
+		return count
+	}
+
+	require.Zero(t, accusations(3), "Peers reachable through relays should not be accused.")
+	require.NotZero(t, accusations(0), "Unreachable peers should be accused without indirect probes.")
+}
+
+func TestDeterminism(t *testing.T) {
+	run := func(seed int64) []string {
+		var ret []string
+
+		nw := newTestNetwork(t, seed, 20)
+
+		for i := 0; i < 10; i++ {
+			nw.RunFor(nw.conf.GossipInterval)
+
+			for _, n := range nw.Nodes() {
+				live := n.LiveMembers()
+				sort.Strings(live)
+				ret = append(ret, fmt.Sprint(n.Addr(), live))
+			}
+		}
+
+		return ret
+	}
+
+	require.Equal(t, run(3), run(3), "Equal seeds should yield equal runs.")
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+
+	ch := c.After(time.Second)
+
+	c.Advance(time.Millisecond * 500)
+	require.Empty(t, ch, "Channel fired early.")
+
+	c.Advance(time.Millisecond * 500)
+	require.Equal(t, start.Add(time.Second), <-ch, "Invalid fire time.")
+
+	c.Set(start)
+	require.Equal(t, start.Add(time.Second), c.Now(), "Clock should not move backwards.")
+}
+
+func newTestNetwork(t *testing.T, seed int64, size int) *Network {
+	conf := DefaultConfig()
+	conf.Seed = seed
+
+	nw, err := NewNetwork(conf)
+	require.NoError(t, err, "Failed to create network.")
+
+	for i := 0; i < size; i++ {
+		_, err := nw.AddNode()
+		require.NoError(t, err, "Failed to add node.")
+	}
+
+	return nw
+}
@@ -0,0 +1,135 @@
+package sim
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/joonnna/ifrit/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcPeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnreachable       = errors.New("Destination is unreachable")
+	errStreamUnsupported = errors.New("Streams are not supported in simulations")
+)
+
+// In-memory replacement of the gRPC transport of a single node.
+// Calls are delivered synchronously to the destination, messages are copied
+// to avoid sharing state between nodes.
+type transport struct {
+	nw   *Network
+	node *Node
+
+	server pb.GossipServer
+}
+
+// In-memory replacement of the UDP ping service of a single node.
+type pinger struct {
+	nw   *Network
+	node *Node
+}
+
+func (t *transport) Register(s pb.GossipServer) {
+	t.server = s
+}
+
+func (t *transport) CloseConn(addr string) {
+}
+
+func (t *transport) Addr() string {
+	return t.node.addr
+}
+
+func (t *transport) Start() {
+}
+
+func (t *transport) Stop() {
+}
+
+func (t *transport) Gossip(addr string, args *pb.State) (*pb.StateResponse, error) {
+	dest, err := t.nw.reach(t.node, addr)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	reply, err := dest.t.server.Spread(t.context(context.Background()), proto.Clone(args).(*pb.State))
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Clone(reply).(*pb.StateResponse), nil
+}
+
+func (t *transport) Send(ctx context.Context, addr string, args *pb.Msg) (*pb.MsgResponse, error) {
+	dest, err := t.nw.reach(t.node, addr)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	reply, err := dest.t.server.Messenger(t.context(ctx), proto.Clone(args).(*pb.Msg))
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Clone(reply).(*pb.MsgResponse), nil
+}
+
+func (t *transport) StreamMessenger(addr string, input, reply chan []byte) error {
+	return errStreamUnsupported
+}
+
+// Attaches the certificate of this node to ctx, as the gRPC transport does for TLS connections.
+func (t *transport) context(ctx context.Context) context.Context {
+	p := &grpcPeer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{t.node.id.cert},
+			},
+		},
+	}
+
+	return grpcPeer.NewContext(ctx, p)
+}
+
+// Replies with a pong signed over the ping, as the UDP ping service does.
+func (p *pinger) Ping(addr string, ping *pb.Ping) (*pb.Pong, error) {
+	dest, err := p.nw.reachPing(p.node, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := proto.Marshal(ping)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := dest.id.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Pong{
+		Signature: &pb.Signature{
+			R: r,
+			S: s,
+		},
+	}, nil
+}
+
+// Stops replying to pings for d of virtual time.
+func (p *pinger) Pause(d time.Duration) {
+	p.nw.PausePings(p.node, d)
+}
+
+func (p *pinger) Start() {
+}
+
+func (p *pinger) Stop() {
+}